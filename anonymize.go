@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 匿名化规则文件的格式
+//
+//	{
+//	  "replacements": [
+//	    {"literal": "Acme Corp", "replace": "Client"},
+//	    {"regex": "proj-[0-9]+", "replace": "proj-X"}
+//	  ],
+//	  "pseudonymize": [{"regex": "(?i)client-[a-z]+", "label": "CLIENT"}],
+//	  "homeDir": true,
+//	  "workspaceRoots": ["/Users/alice/work/client-x"],
+//	  "relativeToWorkspace": true,
+//	  "maskEmails": true,
+//	  "maskIPs": true
+//	}
+type AnonymizeRules struct {
+	Replacements []struct {
+		Literal string `json:"literal"`
+		Regex   string `json:"regex"`
+		Replace string `json:"replace"`
+	} `json:"replacements"`
+	Pseudonymize []struct {
+		Regex string `json:"regex"`
+		Label string `json:"label"`
+	} `json:"pseudonymize"`
+	HomeDir             bool     `json:"homeDir"`             // 将用户主目录替换为~
+	WorkspaceRoots      []string `json:"workspaceRoots"`      // 将这些目录下的路径改写为相对路径
+	RelativeToWorkspace bool     `json:"relativeToWorkspace"` // 将每个会话相关文件的公共目录下的路径改写为相对路径
+	MaskEmails          bool     `json:"maskEmails"`          // 将邮箱替换为一致的占位符
+	MaskIPs             bool     `json:"maskIPs"`             // 将IP地址替换为一致的占位符
+}
+
+// 单条替换规则
+type anonymizeReplacement struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// 需要一致化替换的规则，同一个值在一次运行中总是替换为同一个占位符
+type pseudonymRule struct {
+	re    *regexp.Regexp
+	label string
+}
+
+// 匿名化处理器，在一次运行中共享占位符映射
+type anonymizer struct {
+	rules        AnonymizeRules
+	replacements []anonymizeReplacement
+	pseudonyms   []pseudonymRule
+	roots        []string                     // 工作区根目录，按长度降序
+	placeholders map[string]map[string]string // 类型 -> 原值 -> 占位符
+	mu           sync.Mutex                   // serve并发处理请求时保护placeholders
+}
+
+var (
+	// Unix和macOS的用户主目录，以及Windows的C:\Users\name
+	homeDirPattern = regexp.MustCompile(`(?:/Users|/home)/[^/\s"'()\[\]]+|[A-Za-z]:[\\/]Users[\\/][^\\/\s"'()\[\]]+`)
+	emailPattern   = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	ipv4Pattern    = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// 从JSON文件加载匿名化规则，路径为空时返回nil
+func loadAnonymizer(path string) (*anonymizer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(tr("anonymize.read", err))
+	}
+	var rules AnonymizeRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, errors.New(tr("anonymize.parse", err))
+	}
+	return newAnonymizer(rules)
+}
+
+func newAnonymizer(rules AnonymizeRules) (*anonymizer, error) {
+	a := &anonymizer{
+		rules:        rules,
+		placeholders: make(map[string]map[string]string),
+	}
+	for i, r := range rules.Replacements {
+		var re *regexp.Regexp
+		var err error
+		switch {
+		case r.Regex != "":
+			re, err = regexp.Compile(r.Regex)
+		case r.Literal != "":
+			re, err = regexp.Compile(regexp.QuoteMeta(r.Literal))
+		default:
+			err = errors.New(tr("anonymize.pattern"))
+		}
+		if err != nil {
+			return nil, errors.New(tr("anonymize.replace", i+1, err))
+		}
+		a.replacements = append(a.replacements, anonymizeReplacement{re: re, replace: r.Replace})
+	}
+	for i, p := range rules.Pseudonymize {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil || p.Regex == "" {
+			return nil, errors.New(tr("anonymize.consistent", i+1, err))
+		}
+		label := strings.ToUpper(p.Label)
+		if label == "" {
+			label = "NAME"
+		}
+		a.pseudonyms = append(a.pseudonyms, pseudonymRule{re: re, label: label})
+	}
+	for _, root := range rules.WorkspaceRoots {
+		if root = strings.TrimRight(toSlashPath(root), "/"); root != "" {
+			a.roots = append(a.roots, root)
+		}
+	}
+	sort.Slice(a.roots, func(i, j int) bool { return len(a.roots[i]) > len(a.roots[j]) })
+	return a, nil
+}
+
+// 统一使用正斜杠，便于匹配不同平台的路径
+func toSlashPath(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
+
+// 获取值对应的占位符，同一个值总是返回同一个占位符
+func (a *anonymizer) placeholder(kind string, value string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.placeholders[kind]
+	if !ok {
+		m = make(map[string]string)
+		a.placeholders[kind] = m
+	}
+	if p, ok := m[value]; ok {
+		return p
+	}
+	p := fmt.Sprintf("<%s_%d>", kind, len(m)+1)
+	m[value] = p
+	return p
+}
+
+// 工作区根目录本身的占位符
+const workspacePlaceholder = "<workspace>"
+
+// 将工作区根目录下的路径改写为相对路径，根目录本身替换为占位符
+func rewriteRoot(text string, root string) string {
+	if root == "" || root == "/" || root == "." {
+		return text
+	}
+	text = strings.ReplaceAll(text, root+"/", "")
+	text = replacePathToken(text, root, workspacePlaceholder)
+	// 反斜杠形式的Windows路径
+	if winRoot := strings.ReplaceAll(root, "/", "\\"); winRoot != root {
+		text = strings.ReplaceAll(text, winRoot+"\\", "")
+		text = replacePathToken(text, winRoot, workspacePlaceholder)
+	}
+	return text
+}
+
+// 替换作为完整路径出现的path，后面紧跟其他文件名字符时（例如/work/app与/work/app2）不替换
+func replacePathToken(text string, path string, replace string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(text, path)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		end := i + len(path)
+		if continuesPath(text[end:]) {
+			b.WriteString(text[:end])
+		} else {
+			b.WriteString(text[:i])
+			b.WriteString(replace)
+		}
+		text = text[end:]
+	}
+}
+
+// 判断文本是否以文件名字符开头，句末的点号不算
+func continuesPath(rest string) bool {
+	isNameChar := func(c byte) bool {
+		return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+	}
+	if rest == "" {
+		return false
+	}
+	if rest[0] == '.' {
+		return len(rest) > 1 && isNameChar(rest[1])
+	}
+	return isNameChar(rest[0])
+}
+
+// 对文本应用所有规则，workspace为当前会话的工作区目录
+func (a *anonymizer) anonymizeString(text string, workspace string) string {
+	if text == "" {
+		return text
+	}
+	for _, root := range a.roots {
+		text = rewriteRoot(text, root)
+	}
+	if a.rules.RelativeToWorkspace {
+		text = rewriteRoot(text, workspace)
+	}
+	if a.rules.HomeDir {
+		text = homeDirPattern.ReplaceAllString(text, "~")
+	}
+	for _, r := range a.replacements {
+		text = r.re.ReplaceAllString(text, r.replace)
+	}
+	for _, p := range a.pseudonyms {
+		text = p.re.ReplaceAllStringFunc(text, func(s string) string {
+			return a.placeholder(p.label, s)
+		})
+	}
+	if a.rules.MaskEmails {
+		text = emailPattern.ReplaceAllStringFunc(text, func(s string) string {
+			return a.placeholder("EMAIL", strings.ToLower(s))
+		})
+	}
+	if a.rules.MaskIPs {
+		text = ipv4Pattern.ReplaceAllStringFunc(text, func(s string) string {
+			if net.ParseIP(s) == nil {
+				return s
+			}
+			return a.placeholder("IP", s)
+		})
+	}
+	return text
+}
+
+// 返回匿名化后的记录副本，处理convertToMarkdown用到的所有字段
+// 原始JSON和未解析的字段无法逐项处理，会被丢弃
+func (a *anonymizer) anonymizeRecord(record ChatRecord) ChatRecord {
+	record = record.StripRaw()
+	workspace := store.Workspace(record)
+	anon := func(s string) string { return a.anonymizeString(s, workspace) }
+
+	record.Name = anon(record.Name)
+	files := append(record.Context.FileSelections[:0:0], record.Context.FileSelections...)
+	for i := range files {
+		files[i].Uri.Path = anon(files[i].Uri.Path)
+	}
+	record.Context.FileSelections = files
+
+	conversation := make([]Message, len(record.Conversation))
+	for i, msg := range record.Conversation {
+		msg.Text = anon(msg.Text)
+
+		files := append(msg.Context.FileSelections[:0:0], msg.Context.FileSelections...)
+		for j := range files {
+			files[j].Uri.Path = anon(files[j].Uri.Path)
+		}
+		msg.Context.FileSelections = files
+
+		selections := append(msg.Context.Selections[:0:0], msg.Context.Selections...)
+		for j := range selections {
+			selections[j].Text = anon(selections[j].Text)
+			selections[j].Uri.Path = anon(selections[j].Uri.Path)
+		}
+		msg.Context.Selections = selections
+
+		codeBlocks := append(msg.CodeBlocks[:0:0], msg.CodeBlocks...)
+		for j := range codeBlocks {
+			codeBlocks[j].Content = anon(codeBlocks[j].Content)
+			codeBlocks[j].Uri.Path = anon(codeBlocks[j].Uri.Path)
+		}
+		msg.CodeBlocks = codeBlocks
+
+		conversation[i] = msg
+	}
+	record.Conversation = conversation
+
+	changes := append(record.AppliedChanges[:0:0], record.AppliedChanges...)
+	for i := range changes {
+		changes[i].Path = anon(changes[i].Path)
+		hunks := append(changes[i].Hunks[:0:0], changes[i].Hunks...)
+		for j := range hunks {
+			hunks[j].Removed = anonymizeLines(hunks[j].Removed, anon)
+			hunks[j].Added = anonymizeLines(hunks[j].Added, anon)
+		}
+		changes[i].Hunks = hunks
+	}
+	record.AppliedChanges = changes
+	return record
+}
+
+// 返回匿名化后的导出记录，关联提交的路径按会话的工作区改写
+func (a *anonymizer) anonymizeExportRecord(record exportRecord) exportRecord {
+	workspace := store.Workspace(record.ChatRecord)
+	anon := func(s string) string { return a.anonymizeString(s, workspace) }
+	record.ChatRecord = a.anonymizeRecord(record.ChatRecord)
+	commits := append(record.RelatedCommits[:0:0], record.RelatedCommits...)
+	for i := range commits {
+		commits[i].Subject = anon(commits[i].Subject)
+		commits[i].Author = anon(commits[i].Author)
+		commits[i].Files = anonymizeLines(commits[i].Files, anon)
+	}
+	record.RelatedCommits = commits
+	return record
+}
+
+// 返回匿名化后的行副本
+func anonymizeLines(lines []string, anon func(string) string) []string {
+	if lines == nil {
+		return nil
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = anon(line)
+	}
+	return result
+}
+
+// 匿名化会话列表中的标题、工作区、数据库路径和记录
+func (a *anonymizer) anonymizeSessions(sessions []SessionInfo) {
+	for i := range sessions {
+		s := &sessions[i]
+		workspace := s.Workspace
+		s.Title = a.anonymizeString(s.Title, workspace)
+		s.Workspace = a.anonymizeString(workspace, workspace)
+		s.Source = a.anonymizeString(s.Source, "")
+		if s.Record != nil {
+			record := a.anonymizeRecord(*s.Record)
+			s.Record = &record
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestAnonymizeWorkspaceRoot(t *testing.T) {
+	a, err := newAnonymizer(AnonymizeRules{RelativeToWorkspace: true, WorkspaceRoots: []string{"/srv/acme"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := "/Users/alice/work/client-x"
+	tests := []struct {
+		text, want string
+	}{
+		{workspace, "<workspace>"},
+		{workspace + "/src/main.go", "src/main.go"},
+		{"cd " + workspace + ". Then run make", "cd <workspace>. Then run make"},
+		{"see " + workspace + "-old and " + workspace + "2", "see " + workspace + "-old and " + workspace + "2"},
+		{"/srv/acme", "<workspace>"},
+		{"/srv/acme/deploy.sh", "deploy.sh"},
+	}
+	for _, tt := range tests {
+		if got := a.anonymizeString(tt.text, workspace); got != tt.want {
+			t.Errorf("anonymizeString(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	sessions := []SessionInfo{{Title: "t", Workspace: workspace, Source: "/home/alice/.config/Cursor/state.vscdb"}}
+	a.rules.HomeDir = true
+	a.anonymizeSessions(sessions)
+	if sessions[0].Workspace != "<workspace>" || sessions[0].Source != "~/.config/Cursor/state.vscdb" {
+		t.Errorf("workspace = %q, source = %q", sessions[0].Workspace, sessions[0].Source)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+type BackupResponse struct {
+	Archive  string                 `json:"archive"`
+	Sources  []store.BackupReport   `json:"sources,omitempty"`
+	Versions []store.ArchiveVersion `json:"versions,omitempty"`
+	Success  bool                   `json:"success"`
+	Error    *string                `json:"error,omitempty"`
+	Code     string                 `json:"code,omitempty"`
+}
+
+// 默认的归档文件路径: $XDG_DATA_HOME/cursor2md/archive.db，无法确定主目录时返回空字符串
+func defaultArchivePath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "cursor2md", "archive.db")
+}
+
+// 打开或创建归档，目录不存在时一并创建
+func openArchive(path string) (*store.Archive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, withCode(codeWriteError, errors.New(tr("err.mkdir", err)))
+	}
+	archive, err := store.OpenArchive(path)
+	if errors.Is(err, store.ErrNotArchive) {
+		return nil, withCode(codeInvalidArg, err)
+	}
+	return archive, err
+}
+
+// backup命令入口
+func runBackup(args []string) {
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+	var dbPath dbPaths
+	backupCmd.Var(&dbPath, "db", tr("flag.db"))
+	archivePath := backupCmd.String("archive", defaultArchivePath(), tr("flag.archive.backup"))
+	history := backupCmd.String("history", "", tr("flag.history"))
+	jsonOutput := backupCmd.Bool("json", false, tr("flag.json"))
+	parseFlags(backupCmd, args)
+
+	response := BackupResponse{Archive: *archivePath}
+	fail := func(err error) {
+		code := failed(err)
+		if *jsonOutput {
+			errMsg := err.Error()
+			response.Error = &errMsg
+			response.Code = code
+			response.Sources = nil
+			response.Versions = nil
+			jsonData, _ := json.MarshalIndent(response, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("backup.failed", err))
+	}
+
+	if *archivePath == "" {
+		fail(withCode(codeInvalidArg, errors.New(tr("err.defaultArchive"))))
+		return
+	}
+	ctx := context.Background()
+
+	if *history != "" {
+		// 只读取已有的归档，不创建新文件
+		if _, err := os.Stat(*archivePath); os.IsNotExist(err) {
+			fail(withCode(codeDBNotFound, errors.New(tr("err.dbNotExist", *archivePath))))
+			return
+		}
+		archive, err := openArchive(*archivePath)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer archive.Close()
+		printArchiveHistory(ctx, archive, *history, &response, *jsonOutput, fail)
+		return
+	}
+
+	if !dbPath.setDefault() {
+		fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		return
+	}
+	db, err := openReadOnlyDB(dbPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+	archive, err := openArchive(*archivePath)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer archive.Close()
+
+	// 多个数据库中的同一会话只备份合并时选中的版本
+	if response.Sources, err = archive.BackupMerged(ctx, db, time.Now()); err != nil {
+		fail(err)
+		return
+	}
+
+	response.Success = true
+	if *jsonOutput {
+		jsonData, _ := json.MarshalIndent(response, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	fmt.Println(tr("backup.archive", *archivePath))
+	for _, r := range response.Sources {
+		fmt.Println(tr("backup.report", r.Source, r.Sessions, r.Added, r.Updated, r.Unchanged))
+		if r.Shared > 0 {
+			fmt.Println(tr("backup.shared", r.Shared))
+		}
+	}
+}
+
+// 列出ID以prefix开头的会话在归档中的所有版本
+func printArchiveHistory(ctx context.Context, archive *store.Archive, prefix string, response *BackupResponse, jsonOutput bool, fail func(error)) {
+	versions, err := archive.Versions(ctx, "")
+	if err != nil {
+		fail(err)
+		return
+	}
+	for _, v := range versions {
+		if strings.HasPrefix(v.Unit, prefix) {
+			response.Versions = append(response.Versions, v)
+		}
+	}
+	if len(response.Versions) == 0 {
+		fail(withCode(codeNotFound, errors.New(tr("err.noArchiveVersions", prefix))))
+		return
+	}
+
+	response.Success = true
+	if jsonOutput {
+		jsonData, _ := json.MarshalIndent(response, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	fmt.Printf("%-36s  %7s  %-19s  %5s  %-12s  %s\n", tr("column.session"), tr("column.version"), tr("column.archived"), tr("column.rows"), tr("column.checksum"), tr("column.source"))
+	for _, v := range response.Versions {
+		fmt.Printf("%-36s  %7d  %-19s  %5d  %-12s  %s\n", v.Unit, v.Version, v.ArchivedAt.Format("2006-01-02 15:04:05"), v.Rows, v.Checksum[:12], v.Source)
+	}
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 终端中的按键
+type browseKey int
+
+const (
+	keyRune browseKey = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyTab
+	keyCtrlC
+)
+
+// 交互式会话浏览器
+type browser struct {
+	sessions []SessionInfo   // 全部会话
+	visible  []int           // 过滤后可见会话在sessions中的下标
+	selected map[string]bool // 已多选的会话hash
+	cursor   int             // 光标在visible中的位置
+	scroll   int             // 列表滚动偏移
+	filter   string          // 标题过滤关键词
+	editing  bool            // 是否正在输入过滤关键词
+	status   string          // 底部状态信息
+	width    int             // 终端宽度
+	height   int             // 终端高度
+
+	outputDir     string // 导出目录
+	clipboardFile string // 复制操作写入的文件
+
+	in       *bufio.Reader
+	out      io.Writer
+	pager    func(content string) error // 在分页器中打开内容
+	previews map[string][]string        // 已渲染的预览内容缓存
+}
+
+func newBrowser(sessions []SessionInfo, in io.Reader, out io.Writer) *browser {
+	b := &browser{
+		sessions:  sessions,
+		selected:  make(map[string]bool),
+		width:     100,
+		height:    30,
+		outputDir: "markdown_output",
+		in:        bufio.NewReader(in),
+		out:       out,
+		previews:  make(map[string][]string),
+	}
+	b.clipboardFile = filepath.Join(os.TempDir(), "cursor2md-clipboard.md")
+	b.pager = func(content string) error {
+		return errors.New(tr("browse.noPager"))
+	}
+	b.applyFilter()
+	return b
+}
+
+// 读取一个按键，输入结束时返回io.EOF
+func (b *browser) readKey() (browseKey, rune, error) {
+	r, _, err := b.in.ReadRune()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch r {
+	case 3:
+		return keyCtrlC, r, nil
+	case '\r', '\n':
+		return keyEnter, r, nil
+	case '\t':
+		return keyTab, r, nil
+	case 8, 127:
+		return keyBackspace, r, nil
+	case 27:
+		// 单独的ESC与方向键等转义序列的区分：序列的后续字节已在缓冲区中
+		if b.in.Buffered() == 0 {
+			return keyEscape, r, nil
+		}
+		next, _ := b.in.Peek(1)
+		if next[0] != '[' && next[0] != 'O' {
+			return keyEscape, r, nil
+		}
+		b.in.ReadByte()
+		code, err := b.in.ReadByte()
+		if err != nil {
+			return keyEscape, r, nil
+		}
+		switch code {
+		case 'A':
+			return keyUp, r, nil
+		case 'B':
+			return keyDown, r, nil
+		case 'H':
+			return keyHome, r, nil
+		case 'F':
+			return keyEnd, r, nil
+		case '5', '6', '1', '4':
+			// 形如 ESC [ 5 ~ 的序列
+			b.in.ReadByte()
+			switch code {
+			case '5':
+				return keyPageUp, r, nil
+			case '6':
+				return keyPageDown, r, nil
+			case '1':
+				return keyHome, r, nil
+			case '4':
+				return keyEnd, r, nil
+			}
+		}
+		return keyEscape, r, nil
+	}
+	return keyRune, r, nil
+}
+
+// 根据过滤关键词更新可见会话列表
+func (b *browser) applyFilter() {
+	words := strings.Fields(strings.ToLower(b.filter))
+	b.visible = b.visible[:0]
+	for i, s := range b.sessions {
+		title := strings.ToLower(s.Title)
+		matched := true
+		for _, w := range words {
+			if !strings.Contains(title, w) && !strings.HasPrefix(s.Hash, w) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			b.visible = append(b.visible, i)
+		}
+	}
+	if b.cursor >= len(b.visible) {
+		b.cursor = len(b.visible) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+// 当前光标所在的会话
+func (b *browser) current() (SessionInfo, bool) {
+	if len(b.visible) == 0 {
+		return SessionInfo{}, false
+	}
+	return b.sessions[b.visible[b.cursor]], true
+}
+
+// 操作目标：已多选的会话，没有多选时为当前会话
+func (b *browser) targets() []SessionInfo {
+	var targets []SessionInfo
+	for _, s := range b.sessions {
+		if b.selected[s.Hash] {
+			targets = append(targets, s)
+		}
+	}
+	if len(targets) == 0 {
+		if s, ok := b.current(); ok {
+			targets = append(targets, s)
+		}
+	}
+	return targets
+}
+
+// 列表区域可显示的行数
+func (b *browser) listHeight() int {
+	h := b.height - 4
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+func (b *browser) moveCursor(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.visible) {
+		b.cursor = len(b.visible) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+// 处理一个按键，返回false表示退出
+func (b *browser) handleKey(key browseKey, r rune) bool {
+	b.status = ""
+
+	if b.editing {
+		switch key {
+		case keyEnter, keyTab:
+			b.editing = false
+		case keyEscape:
+			b.editing = false
+			b.filter = ""
+			b.applyFilter()
+		case keyBackspace:
+			if runes := []rune(b.filter); len(runes) > 0 {
+				b.filter = string(runes[:len(runes)-1])
+				b.applyFilter()
+			}
+		case keyCtrlC:
+			return false
+		case keyUp:
+			b.moveCursor(-1)
+		case keyDown:
+			b.moveCursor(1)
+		case keyRune:
+			if unicode.IsPrint(r) {
+				b.filter += string(r)
+				b.cursor = 0
+				b.applyFilter()
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case keyCtrlC:
+		return false
+	case keyEscape:
+		if b.filter != "" {
+			b.filter = ""
+			b.applyFilter()
+		}
+	case keyUp:
+		b.moveCursor(-1)
+	case keyDown:
+		b.moveCursor(1)
+	case keyPageUp:
+		b.moveCursor(-b.listHeight())
+	case keyPageDown:
+		b.moveCursor(b.listHeight())
+	case keyHome:
+		b.cursor = 0
+	case keyEnd:
+		b.moveCursor(len(b.visible))
+	case keyEnter:
+		b.openPager()
+	case keyRune:
+		switch r {
+		case 'q':
+			return false
+		case 'k':
+			b.moveCursor(-1)
+		case 'j':
+			b.moveCursor(1)
+		case 'g':
+			b.cursor = 0
+		case 'G':
+			b.moveCursor(len(b.visible))
+		case '/':
+			b.editing = true
+		case ' ':
+			if s, ok := b.current(); ok {
+				if b.selected[s.Hash] {
+					delete(b.selected, s.Hash)
+				} else {
+					b.selected[s.Hash] = true
+				}
+				b.moveCursor(1)
+			}
+		case 'a':
+			// 全选可见会话，已全部选中时取消选择
+			allSelected := len(b.visible) > 0
+			for _, i := range b.visible {
+				if !b.selected[b.sessions[i].Hash] {
+					allSelected = false
+					break
+				}
+			}
+			for _, i := range b.visible {
+				if allSelected {
+					delete(b.selected, b.sessions[i].Hash)
+				} else {
+					b.selected[b.sessions[i].Hash] = true
+				}
+			}
+		case 'e':
+			b.exportTargets()
+		case 'c':
+			b.copyTargets()
+		case 'o':
+			b.openPager()
+		}
+	}
+	return true
+}
+
+// 导出选中的会话到输出目录
+func (b *browser) exportTargets() {
+	targets := b.targets()
+	if len(targets) == 0 {
+		return
+	}
+	if err := os.MkdirAll(b.outputDir, 0755); err != nil {
+		b.status = tr("err.mkdirOut", err)
+		return
+	}
+	for _, s := range targets {
+		mdFile := uniqueMarkdownPath(b.outputDir, s.Title, s.StartTime)
+		if err := os.WriteFile(mdFile, []byte(convertToMarkdown(*s.Record)), 0644); err != nil {
+			b.status = tr("err.writeMarkdown", err)
+			return
+		}
+	}
+	b.status = tr("browse.exported", len(targets), b.outputDir)
+}
+
+// 将选中会话的markdown写入剪贴板文件
+func (b *browser) copyTargets() {
+	targets := b.targets()
+	if len(targets) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(targets))
+	for _, s := range targets {
+		parts = append(parts, convertToMarkdown(*s.Record))
+	}
+	if err := os.WriteFile(b.clipboardFile, []byte(strings.Join(parts, "\n---\n\n")), 0644); err != nil {
+		b.status = tr("browse.clipboardFailed", err)
+		return
+	}
+	b.status = tr("browse.copied", len(targets), b.clipboardFile)
+}
+
+// 在分页器中打开当前会话
+func (b *browser) openPager() {
+	s, ok := b.current()
+	if !ok {
+		return
+	}
+	if err := b.pager(convertToMarkdown(*s.Record)); err != nil {
+		b.status = tr("browse.pagerFailed", err)
+	}
+}
+
+// 当前会话的预览行
+func (b *browser) preview(s SessionInfo) []string {
+	if lines, ok := b.previews[s.Hash]; ok {
+		return lines
+	}
+	content := strings.ReplaceAll(convertToMarkdown(*s.Record), "\t", "    ")
+	lines := strings.Split(content, "\n")
+	b.previews[s.Hash] = lines
+	return lines
+}
+
+// 字符在终端中占用的列数
+func runeWidth(r rune) int {
+	if r < 0x1100 {
+		return 1
+	}
+	if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hangul, r) ||
+		unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xff60) || (r >= 0x1f300 && r <= 0x1faff) {
+		return 2
+	}
+	return 1
+}
+
+// 将字符串截断并填充到指定的显示宽度
+func fitWidth(s string, width int) string {
+	var sb strings.Builder
+	w := 0
+	for _, r := range s {
+		if r < 32 {
+			r = ' '
+		}
+		rw := runeWidth(r)
+		if w+rw > width {
+			break
+		}
+		sb.WriteRune(r)
+		w += rw
+	}
+	for ; w < width; w++ {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+// 绘制整个界面
+func (b *browser) render() {
+	listWidth := b.width * 2 / 5
+	if listWidth < 30 {
+		listWidth = 30
+	}
+	previewWidth := b.width - listWidth - 3
+	height := b.listHeight()
+
+	if b.cursor < b.scroll {
+		b.scroll = b.cursor
+	}
+	if b.cursor >= b.scroll+height {
+		b.scroll = b.cursor - height + 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+
+	filterLine := "/ " + tr("browse.filter")
+	if b.editing || b.filter != "" {
+		filterLine = tr("browse.filter") + ": " + b.filter
+		if b.editing {
+			filterLine += "_"
+		}
+	}
+	header := "cursor2md browse  " + tr("browse.header", len(b.visible), len(b.sessions), len(b.selected)) + "  " + filterLine
+	sb.WriteString(fitWidth(header, b.width) + "\r\n")
+	sb.WriteString(strings.Repeat("-", b.width) + "\r\n")
+
+	var previewLines []string
+	if s, ok := b.current(); ok {
+		previewLines = b.preview(s)
+	}
+
+	for row := 0; row < height; row++ {
+		left := ""
+		if idx := b.scroll + row; idx < len(b.visible) {
+			s := b.sessions[b.visible[idx]]
+			cursor, mark := " ", "[ ]"
+			if idx == b.cursor {
+				cursor = ">"
+			}
+			if b.selected[s.Hash] {
+				mark = "[x]"
+			}
+			left = fmt.Sprintf("%s%s %s %s", cursor, mark, s.StartTime.Format("2006-01-02"), s.Title)
+		}
+		right := ""
+		if row < len(previewLines) {
+			right = previewLines[row]
+		}
+		sb.WriteString(fitWidth(left, listWidth) + " | " + strings.TrimRight(fitWidth(right, previewWidth), " ") + "\r\n")
+	}
+
+	footer := tr("browse.footer")
+	if b.status != "" {
+		footer = b.status
+	}
+	sb.WriteString(fitWidth(footer, b.width))
+	io.WriteString(b.out, sb.String())
+}
+
+// 运行事件循环，直到退出或输入结束
+func (b *browser) run() error {
+	b.render()
+	for {
+		key, r, err := b.readKey()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !b.handleKey(key, r) {
+			break
+		}
+		b.render()
+	}
+	io.WriteString(b.out, "\r\n")
+	return nil
+}
+
+// 执行stty命令，作用于当前终端
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// 将终端切换到原始模式，返回恢复函数
+func enableRawMode() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+// 获取终端大小，失败时使用环境变量或默认值
+func terminalSize() (int, int) {
+	width, height := 100, 30
+	if size, err := stty("size"); err == nil {
+		var rows, cols int
+		if n, _ := fmt.Sscanf(size, "%d %d", &rows, &cols); n == 2 && rows > 0 && cols > 0 {
+			return cols, rows
+		}
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		width = cols
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		height = lines
+	}
+	return width, height
+}
+
+// 分页器命令及参数，PAGER没有设置或只包含空白时使用less
+func pagerCommand() []string {
+	fields := strings.Fields(os.Getenv("PAGER"))
+	if len(fields) == 0 {
+		return []string{"less"}
+	}
+	return fields
+}
+
+// browse命令入口
+func runBrowse(args []string) {
+	var config Config
+	browseCmd := flag.NewFlagSet("browse", flag.ExitOnError)
+	registerDBFlags(browseCmd, &config.DBPaths)
+	browseCmd.StringVar(&config.OutputDir, "out", "markdown_output", tr("flag.out"))
+	browseCmd.StringVar(&config.SortBy, "sort", "start", tr("flag.sort"))
+	browseCmd.BoolVar(&config.SortDesc, "sort-desc", true, tr("flag.sort-desc"))
+	clipboardFile := browseCmd.String("clipboard", filepath.Join(os.TempDir(), "cursor2md-clipboard.md"), tr("flag.clipboard"))
+	inputFile := browseCmd.String("input", "", tr("flag.input"))
+	size := browseCmd.String("size", "", tr("flag.size"))
+	browseCmd.BoolVar(&config.Redact, "redact", false, tr("flag.redact.browse"))
+	parseTimeFilters := registerTimeFilterFlags(browseCmd, &config)
+	parseFlags(browseCmd, args)
+
+	if err := parseTimeFilters(); err != nil {
+		failed(withCode(codeInvalidArg, err))
+		fmt.Println(err)
+		return
+	}
+	if !config.DBPaths.setDefault() {
+		failed(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		fmt.Println(tr("err.defaultDB"))
+		return
+	}
+
+	db, err := openDB(config.DBPaths)
+	if err != nil {
+		failed(err)
+		fmt.Println(err)
+		return
+	}
+	sessions, _, err := loadSessions(db, config)
+	db.Close()
+	if err != nil {
+		failed(err)
+		fmt.Printf("%s: %v\n", tr("ls.failed"), err)
+		return
+	}
+	if len(sessions) == 0 {
+		fmt.Println(tr("ls.empty"))
+		return
+	}
+	if config.Redact {
+		redactSessions(sessions)
+	}
+
+	var in io.Reader = os.Stdin
+	restore := func() {}
+	raw := false
+	if *inputFile != "" {
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			failed(withCode(codeInvalidArg, err))
+			fmt.Println(tr("browse.inputFailed", err))
+			return
+		}
+		defer f.Close()
+		in = f
+	} else if r, err := enableRawMode(); err == nil {
+		restore, raw = r, true
+	}
+	// 分页器返回后会重新进入原始模式并替换restore
+	defer func() { restore() }()
+
+	b := newBrowser(sessions, in, os.Stdout)
+	b.outputDir = config.OutputDir
+	b.clipboardFile = *clipboardFile
+	b.width, b.height = terminalSize()
+	if *size != "" {
+		if _, err := fmt.Sscanf(*size, "%dx%d", &b.width, &b.height); err != nil {
+			failed(withCode(codeInvalidArg, err))
+			fmt.Println(tr("browse.size", *size))
+			return
+		}
+	}
+	b.pager = func(content string) error {
+		if *inputFile != "" {
+			// 脚本模式下不启动交互式分页器，直接输出内容
+			_, err := io.WriteString(os.Stdout, content)
+			return err
+		}
+		restore()
+		fields := pagerCommand()
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Stdin = strings.NewReader(content)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		runErr := cmd.Run()
+		if raw {
+			r, err := enableRawMode()
+			if err != nil {
+				restore = func() {}
+				return errors.New(tr("browse.rawMode", err))
+			}
+			restore = r
+		}
+		return runErr
+	}
+
+	if err := b.run(); err != nil {
+		restore()
+		failed(err)
+		fmt.Println(tr("browse.failed", err))
+	}
+}
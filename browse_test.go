@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 从测试数据库加载会话，按开始时间降序排列
+func fixtureSessions(t *testing.T) []SessionInfo {
+	t.Helper()
+	db, err := openDB([]string{newFixtureDB(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sessions, _, err := loadSessions(db, Config{SortDesc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sessions
+}
+
+// 用按键序列驱动浏览器，返回浏览器和界面输出
+func runScript(t *testing.T, sessions []SessionInfo, keys string) (*browser, string) {
+	t.Helper()
+	var out bytes.Buffer
+	b := newBrowser(sessions, strings.NewReader(keys), &out)
+	b.width, b.height = 100, 20
+	b.outputDir = filepath.Join(t.TempDir(), "out")
+	b.clipboardFile = filepath.Join(t.TempDir(), "clipboard.md")
+	if err := b.run(); err != nil {
+		t.Fatal(err)
+	}
+	return b, out.String()
+}
+
+func TestBrowseFilterAndExport(t *testing.T) {
+	b, out := runScript(t, fixtureSessions(t), "/parser\r e q")
+	if b.filter != "parser" || len(b.visible) != 1 {
+		t.Fatalf("filter = %q, visible = %d, want 1 session matching parser", b.filter, len(b.visible))
+	}
+	if !strings.Contains(out, "Parser refactor") {
+		t.Errorf("output does not list the filtered session:\n%s", out)
+	}
+	files, err := os.ReadDir(b.outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "Parser refactor.md" {
+		t.Fatalf("exported files = %v, want [Parser refactor.md]", files)
+	}
+}
+
+func TestBrowseSelectAllAndCopy(t *testing.T) {
+	b, _ := runScript(t, fixtureSessions(t), "acq")
+	if len(b.selected) != 3 {
+		t.Fatalf("selected %d sessions, want 3", len(b.selected))
+	}
+	data, err := os.ReadFile(b.clipboardFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Fix login bug", "Parser refactor", "<b>Escaping</b> & co"} {
+		if !strings.Contains(string(data), "# "+title) {
+			t.Errorf("clipboard is missing %q", title)
+		}
+	}
+}
+
+func TestBrowseNavigation(t *testing.T) {
+	sessions := fixtureSessions(t)
+	// 方向键和j/k移动光标，空格选择后光标下移；输入过滤关键词时光标回到第一行，ESC清除过滤
+	b, _ := runScript(t, sessions, "j\x1b[Bk \x1b[A/zzz\x1b")
+	if b.filter != "" || len(b.visible) != len(sessions) {
+		t.Errorf("filter = %q, visible = %d after ESC", b.filter, len(b.visible))
+	}
+	if !b.selected[sessions[1].Hash] || len(b.selected) != 1 {
+		t.Errorf("selected = %v, want only %s", b.selected, sessions[1].Hash)
+	}
+	if b.cursor != 0 {
+		t.Errorf("cursor = %d, want 0", b.cursor)
+	}
+}
+
+func TestBrowseInputFile(t *testing.T) {
+	isolateConfig(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "keys")
+	if err := os.WriteFile(input, []byte("/login\re"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	output := captureStdout(t, func() {
+		runBrowse([]string{"-db", newFixtureDB(t), "-input", input, "-out", out, "-size", "80x20"})
+	})
+	if !strings.Contains(output, "1/3") {
+		t.Errorf("header does not show the filtered count:\n%s", output)
+	}
+	if _, err := os.Stat(filepath.Join(out, "Fix login bug.md")); err != nil {
+		t.Fatalf("scripted export did not write the session: %v", err)
+	}
+}
+
+func TestPagerCommand(t *testing.T) {
+	tests := []struct {
+		pager string
+		want  []string
+	}{
+		{"", []string{"less"}},
+		{"   ", []string{"less"}},
+		{"more", []string{"more"}},
+		{"less -R", []string{"less", "-R"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("PAGER", tt.pager)
+		if got := pagerCommand(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PAGER=%q: pagerCommand() = %v, want %v", tt.pager, got, tt.want)
+		}
+	}
+}
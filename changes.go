@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 修改状态的显示名称在消息目录中的ID
+var changeStatusLabels = map[string]string{
+	"accepted": "md.accepted",
+	"rejected": "md.rejected",
+	"pending":  "md.pending",
+}
+
+// 将修改记录渲染为Markdown的“已应用的修改”部分
+func appliedChangesMarkdown(changes []AppliedChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var md strings.Builder
+	md.WriteString("## " + tr("md.appliedChanges") + "\n\n")
+	for _, c := range changes {
+		name := tr("md.unknownFile")
+		if c.Path != "" {
+			name = fmt.Sprintf("[%s](%s)", filepath.Base(c.Path), c.Path)
+		}
+		md.WriteString("### " + name)
+		if label, ok := changeStatusLabels[c.Status]; ok {
+			md.WriteString(" (" + tr(label) + ")")
+		}
+		md.WriteString("\n\n")
+		md.WriteString(tr("md.changeSource", c.Source))
+		if c.ID != "" {
+			fmt.Fprintf(&md, " `%s`", c.ID)
+		}
+		md.WriteString(tr("md.changeHunks", len(c.Hunks)))
+		if c.Note != "" {
+			md.WriteString(tr("md.changeNote", tr("note."+c.Note)))
+		}
+		md.WriteString("\n\n")
+
+		if len(c.Hunks) == 0 {
+			continue
+		}
+		md.WriteString("```diff\n")
+		// 前面的段增减的行数，修改后文件中的起始行需要加上它
+		delta := 0
+		for _, h := range c.Hunks {
+			oldStart, oldCount := h.OriginalStart, h.OriginalEnd-h.OriginalStart
+			newStart, newCount := h.OriginalStart+delta, h.NewCount
+			delta += newCount - oldCount
+			// 某一侧没有行时起始行为前一行，与diff -u一致
+			if oldCount == 0 {
+				oldStart--
+			}
+			if newCount == 0 {
+				newStart--
+			}
+			fmt.Fprintf(&md, "@@ -%s +%s @@\n", formatHunkRange(oldStart, oldCount), formatHunkRange(newStart, newCount))
+			for _, line := range h.Removed {
+				md.WriteString("-" + line + "\n")
+			}
+			for _, line := range h.Added {
+				md.WriteString("+" + line + "\n")
+			}
+		}
+		md.WriteString("```\n\n")
+	}
+	return md.String()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppliedChangesHunkRanges(t *testing.T) {
+	md := appliedChangesMarkdown([]AppliedChange{{
+		Source: "inlineDiff",
+		Path:   "/src/main.go",
+		Hunks: []ChangeHunk{
+			// 第2行替换为3行
+			{OriginalStart: 2, OriginalEnd: 3, Removed: []string{"b"}, Added: []string{"b1", "b2", "b3"}, NewCount: 3},
+			// 删除第5、6行
+			{OriginalStart: 5, OriginalEnd: 7, Removed: []string{"e", "f"}, NewCount: 0},
+			// 在第9行之前插入1行
+			{OriginalStart: 9, OriginalEnd: 9, Added: []string{"x"}, NewCount: 1},
+		},
+	}})
+	var headers []string
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			headers = append(headers, line)
+		}
+	}
+	want := []string{"@@ -2 +2,3 @@", "@@ -5,2 +6,0 @@", "@@ -8,0 +9 @@"}
+	if strings.Join(headers, "\n") != strings.Join(want, "\n") {
+		t.Errorf("hunk headers =\n%s\nwant\n%s", strings.Join(headers, "\n"), strings.Join(want, "\n"))
+	}
+}
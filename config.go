@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 环境变量前缀，CURSOR2MD_SORT_DESC对应-sort-desc参数
+const envPrefix = "CURSOR2MD_"
+
+// 配置文件中的一个表，键为命令行参数名
+type settings map[string]configValue
+
+// 已读取的配置文件
+type configFile struct {
+	Path   string
+	tables map[string]settings // 表名为空表示顶层，例如 export、profiles.work、profiles.work.export
+}
+
+// 配置中的一项设置
+type configValue struct {
+	Key    string   `json:"key"`
+	Value  string   `json:"value"`           // 参数的字符串形式，数组的各项以逗号连接
+	Items  []string `json:"items,omitempty"` // 数组的各项，值不是数组时为nil
+	Source string   `json:"source"`          // 配置文件路径和表名，或环境变量名
+	env    bool     // 来自环境变量
+}
+
+// 可以重复指定的参数，配置中的数组每一项设置一次，环境变量的值按系统的路径列表分隔符拆分
+// 其他参数的数组与-columns等参数的格式一致，以逗号连接后设置一次
+type repeatableValue interface {
+	flag.Value
+	repeatable()
+}
+
+// 某个命令的有效配置
+type resolvedConfig struct {
+	Searched []string      `json:"searched"` // 查找过的配置文件路径
+	Files    []string      `json:"files"`    // 存在并已读取的配置文件
+	Profile  string        `json:"profile,omitempty"`
+	Values   []configValue `json:"values"`
+}
+
+type ConfigResponse struct {
+	Command string          `json:"command,omitempty"`
+	Config  *resolvedConfig `json:"config,omitempty"`
+	Success bool            `json:"success"`
+	Error   *string         `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
+}
+
+// 配置文件的查找路径，后面的文件覆盖前面的文件
+// 设置了CURSOR2MD_CONFIG时只读取该文件
+func configPaths() []string {
+	if p := os.Getenv(envPrefix + "CONFIG"); p != "" {
+		return []string{p}
+	}
+	var paths []string
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		paths = append(paths, filepath.Join(dir, "cursor2md", "config.toml"))
+	}
+	return append(paths, ".cursor2md.toml")
+}
+
+// 读取存在的配置文件，CURSOR2MD_CONFIG指定的文件必须存在
+func loadConfigFiles() ([]configFile, error) {
+	var files []configFile
+	explicit := os.Getenv(envPrefix+"CONFIG") != ""
+	for _, path := range configPaths() {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && !explicit {
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(tr("config.read", err))
+		}
+		tables, err := parseTOML(data)
+		if err != nil {
+			return nil, errors.New(tr("config.parse", path, err))
+		}
+		files = append(files, configFile{Path: path, tables: tables})
+	}
+	return files, nil
+}
+
+// 计算命令的有效配置，后面的层覆盖前面的层:
+// 顶层 < [命令] < [profiles.方案] < [profiles.方案.命令] < CURSOR2MD_*环境变量
+// 方案由profile参数、CURSOR2MD_PROFILE或配置文件顶层的profile依次决定
+func resolveConfig(command string, profile string) (*resolvedConfig, error) {
+	files, err := loadConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &resolvedConfig{Searched: configPaths(), Files: []string{}}
+	for _, f := range files {
+		cfg.Files = append(cfg.Files, f.Path)
+	}
+
+	if profile == "" {
+		profile = os.Getenv(envPrefix + "PROFILE")
+	}
+	if profile == "" {
+		for _, f := range files {
+			if p := f.tables[""]["profile"].Value; p != "" {
+				profile = p
+			}
+		}
+	}
+	cfg.Profile = profile
+
+	values := make(map[string]configValue)
+	apply := func(table string) bool {
+		found := false
+		for _, f := range files {
+			t, ok := f.tables[table]
+			if !ok {
+				continue
+			}
+			found = true
+			source := f.Path
+			if table != "" {
+				source += " [" + table + "]"
+			}
+			for k, v := range t {
+				v.Source = source
+				values[k] = v
+			}
+		}
+		return found
+	}
+	apply("")
+	if command != "" {
+		apply(command)
+	}
+	if profile != "" {
+		found := apply("profiles." + profile)
+		if command != "" && apply("profiles."+profile+"."+command) {
+			found = true
+		}
+		if !found {
+			return nil, errors.New(tr("config.noProfile", profile))
+		}
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, envPrefix) || name == envPrefix+"CONFIG" || name == envPrefix+"PROFILE" {
+			continue
+		}
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "_", "-")
+		values[key] = configValue{Key: key, Value: value, Source: tr("config.env", name), env: true}
+	}
+	delete(values, "profile")
+
+	cfg.Values = []configValue{}
+	for _, v := range values {
+		cfg.Values = append(cfg.Values, v)
+	}
+	sort.Slice(cfg.Values, func(i, j int) bool { return cfg.Values[i].Key < cfg.Values[j].Key })
+	return cfg, nil
+}
+
+// 解析命令行参数，再用配置文件和环境变量设置命令行中没有指定的参数
+// 同时为命令添加-profile和-lang参数，语言已由initLang确定，这里只检查参数值；配置无效时与参数无效一样以invalid-arg的退出状态结束
+func parseFlags(fs *flag.FlagSet, args []string) {
+	profile := fs.String("profile", "", tr("flag.profile"))
+	fs.Func("lang", tr("flag.lang"), func(v string) error {
+		if _, ok := normalizeLang(v); !ok {
+			return errors.New(tr("err.lang", v, strings.Join(languages, ", ")))
+		}
+		return nil
+	})
+	fs.Parse(args)
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	cfg, err := resolveConfig(fs.Name(), *profile)
+	if err == nil {
+		for _, v := range cfg.Values {
+			if explicit[v.Key] || fs.Lookup(v.Key) == nil {
+				continue
+			}
+			if err = setFlag(fs, v); err != nil {
+				err = errors.New(tr("config.invalidValue", v.Source, v.Key, err))
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("err.config", err))
+		os.Exit(exitCodes[codeInvalidArg])
+	}
+}
+
+// 用配置中的值设置参数，可以重复指定的参数逐项设置
+func setFlag(fs *flag.FlagSet, v configValue) error {
+	if _, ok := fs.Lookup(v.Key).Value.(repeatableValue); !ok {
+		return fs.Set(v.Key, v.Value)
+	}
+	items := v.Items
+	if items == nil {
+		items = []string{v.Value}
+		if v.env {
+			items = filepath.SplitList(v.Value)
+		}
+	}
+	for _, item := range items {
+		if err := fs.Set(v.Key, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解析TOML的一个子集: 表头、键值对、字符串、布尔值、数字、日期和单行数组
+func parseTOML(data []byte) (map[string]settings, error) {
+	tables := map[string]settings{"": {}}
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if strings.HasPrefix(line, "[[") || end < 0 {
+				return nil, errors.New(tr("config.line", i+1, tr("config.tableHeader", line)))
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, errors.New(tr("config.line", i+1, tr("config.afterHeader", rest)))
+			}
+			var parts []string
+			for _, p := range strings.Split(line[1:end], ".") {
+				p = strings.Trim(strings.TrimSpace(p), `"'`)
+				if p == "" {
+					return nil, errors.New(tr("config.line", i+1, tr("config.emptyTable", line)))
+				}
+				parts = append(parts, p)
+			}
+			table = strings.Join(parts, ".")
+			if tables[table] == nil {
+				tables[table] = settings{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.New(tr("config.line", i+1, tr("config.noEquals", line)))
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if key == "" || strings.ContainsAny(key, ". \t") {
+			return nil, errors.New(tr("config.line", i+1, tr("config.key", key)))
+		}
+		v, items, rest, err := readTOMLValue(strings.TrimSpace(value))
+		if err == nil {
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				err = errors.New(tr("config.afterValue", rest))
+			}
+		}
+		if err != nil {
+			return nil, errors.New(tr("config.line", i+1, err))
+		}
+		tables[table][key] = configValue{Key: key, Value: v, Items: items}
+	}
+	return tables, nil
+}
+
+// 读取一个TOML值，返回值的字符串形式、数组的各项和之后剩余的内容
+// 数组的字符串形式为以逗号连接的各项，值不是数组时各项为nil
+func readTOMLValue(s string) (string, []string, string, error) {
+	switch {
+	case s == "":
+		return "", nil, "", errors.New(tr("config.noValue"))
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return "", nil, "", errors.New(tr("config.multiline"))
+
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", nil, "", errors.New(tr("config.string", s[:i+1]))
+				}
+				return v, nil, s[i+1:], nil
+			}
+		}
+		return "", nil, "", errors.New(tr("config.unterminated", s))
+
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", nil, "", errors.New(tr("config.unterminated", s))
+		}
+		return s[1 : end+1], nil, s[end+2:], nil
+
+	case s[0] == '[':
+		items := []string{}
+		rest := strings.TrimSpace(s[1:])
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return strings.Join(items, ","), items, rest[1:], nil
+			}
+			if rest == "" || strings.HasPrefix(rest, "#") {
+				return "", nil, "", errors.New(tr("config.array", s))
+			}
+			item, _, r, err := readTOMLValue(rest)
+			if err != nil {
+				return "", nil, "", err
+			}
+			items = append(items, item)
+			rest = strings.TrimSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", nil, "", errors.New(tr("config.comma", s))
+			}
+		}
+	}
+
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	word := s[:end]
+	if word == "true" || word == "false" {
+		return word, nil, s[end:], nil
+	}
+	if n := strings.ReplaceAll(word, "_", ""); n != "" {
+		if _, err := strconv.ParseFloat(n, 64); err == nil {
+			return n, nil, s[end:], nil
+		}
+	}
+	// 日期和时间，转换为时间参数接受的格式
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if _, err := time.Parse(layout, word); err == nil {
+			return strings.Replace(word, "T", " ", 1), nil, s[end:], nil
+		}
+	}
+	return "", nil, "", errors.New(tr("config.value", word))
+}
+
+// config命令入口
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		failed(withCode(codeInvalidArg, errors.New(tr("config.subcommand"))))
+		fmt.Println(tr("usage.config"))
+		return
+	}
+	args = args[1:]
+	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
+	jsonOutput := configCmd.Bool("json", false, tr("flag.json"))
+	profile := configCmd.String("profile", "", tr("flag.profile.config"))
+	// 语言已由initLang确定，这里只接受该参数
+	configCmd.String("lang", lang, tr("flag.lang"))
+
+	// 命令参数可以在选项之前或之后
+	var command string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	configCmd.Parse(args)
+	if command == "" && configCmd.NArg() > 0 {
+		command = configCmd.Arg(0)
+	}
+
+	cfg, err := resolveConfig(command, *profile)
+	if err != nil {
+		code := failed(withCode(codeInvalidArg, err))
+		if *jsonOutput {
+			errMsg := err.Error()
+			jsonData, _ := json.MarshalIndent(ConfigResponse{Command: command, Success: false, Error: &errMsg, Code: code}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("err.config", err))
+		return
+	}
+
+	if *jsonOutput {
+		jsonData, err := json.MarshalIndent(ConfigResponse{Command: command, Config: cfg, Success: true}, "", "  ")
+		if err != nil {
+			failed(err)
+			fmt.Println(tr("err.json", err))
+			return
+		}
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	fmt.Println(tr("config.files"))
+	loaded := make(map[string]bool)
+	for _, f := range cfg.Files {
+		loaded[f] = true
+	}
+	for _, path := range cfg.Searched {
+		if loaded[path] {
+			fmt.Printf("  %s\n", path)
+		} else {
+			fmt.Printf("  %s (%s)\n", path, tr("config.missing"))
+		}
+	}
+	if cfg.Profile != "" {
+		fmt.Println(tr("config.profile", cfg.Profile))
+	} else {
+		fmt.Println(tr("config.profile", tr("config.none")))
+	}
+
+	if command != "" {
+		fmt.Println("\n" + tr("config.commandValues", command))
+	} else {
+		fmt.Println("\n" + tr("config.values"))
+	}
+	if len(cfg.Values) == 0 {
+		fmt.Println("  " + tr("config.none"))
+		return
+	}
+	for _, v := range cfg.Values {
+		fmt.Printf("  %s = %s    # %s\n", v.Key, v.Value, v.Source)
+	}
+}
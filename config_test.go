@@ -0,0 +1,262 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	data := `# 注释
+db = "/data/state.vscdb"   # 行尾注释
+columns = ["hash", 'title' , "messages"]
+empty = []
+sort-desc = true
+limit = 1_000
+ratio = 0.5
+start-after = 2024-01-01
+end-before = 2024-01-31T12:30
+escaped = "tab\there \"quoted\" # not a comment"
+literal = 'C:\Users\me\state.vscdb'
+"quoted-key" = 1
+
+[export]
+out = "chats"
+
+[ profiles . "work" ]
+db = ["/a.vscdb", "/b.vscdb"]
+
+[profiles.work.export]
+redact = false
+`
+	tables, err := parseTOML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scalar := func(key, value string) configValue { return configValue{Key: key, Value: value} }
+	want := map[string]settings{
+		"": {
+			"db":          scalar("db", "/data/state.vscdb"),
+			"columns":     {Key: "columns", Value: "hash,title,messages", Items: []string{"hash", "title", "messages"}},
+			"empty":       {Key: "empty", Value: "", Items: []string{}},
+			"sort-desc":   scalar("sort-desc", "true"),
+			"limit":       scalar("limit", "1000"),
+			"ratio":       scalar("ratio", "0.5"),
+			"start-after": scalar("start-after", "2024-01-01"),
+			"end-before":  scalar("end-before", "2024-01-31 12:30"),
+			"escaped":     scalar("escaped", "tab\there \"quoted\" # not a comment"),
+			"literal":     scalar("literal", `C:\Users\me\state.vscdb`),
+			"quoted-key":  scalar("quoted-key", "1"),
+		},
+		"export":               {"out": scalar("out", "chats")},
+		"profiles.work":        {"db": {Key: "db", Value: "/a.vscdb,/b.vscdb", Items: []string{"/a.vscdb", "/b.vscdb"}}},
+		"profiles.work.export": {"redact": scalar("redact", "false")},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("parseTOML =\n%+v\nwant\n%+v", tables, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want string // 错误信息中应当包含的内容
+	}{
+		{"[[export]]", tr("config.line", 1, tr("config.tableHeader", "[[export]]"))},
+		{"[export", tr("config.tableHeader", "[export")},
+		{"[export] out = 1", tr("config.afterHeader", "out = 1")},
+		{"[profiles..work]", tr("config.emptyTable", "[profiles..work]")},
+		{"\n\nout", tr("config.line", 3, tr("config.noEquals", "out"))},
+		{"a.b = 1", tr("config.key", "a.b")},
+		{"out = ", tr("config.noValue")},
+		{`out = """x"""`, tr("config.multiline")},
+		{`db = "C:\Users"`, tr("config.string", `"C:\Users"`)},
+		{`out = "chats`, tr("config.unterminated", `"chats`)},
+		{`out = 'chats`, tr("config.unterminated", `'chats`)},
+		{`columns = ["hash",`, tr("config.array", `["hash",`)},
+		{`columns = ["hash" "title"]`, tr("config.comma", `["hash" "title"]`)},
+		{"out = chats", tr("config.value", "chats")},
+		{`out = "chats" extra`, tr("config.afterValue", "extra")},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTOML(%q) error = %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+// 写入配置文件并通过CURSOR2MD_CONFIG指定，返回文件路径
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	isolateConfig(t)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CURSOR2MD_CONFIG", path)
+	return path
+}
+
+const precedenceConfig = `
+out = "top"
+format = "top"
+template = "top"
+limit = 1
+redact = true
+profile = "work"
+
+[export]
+format = "export"
+template = "export"
+limit = 2
+
+[profiles.work]
+template = "work"
+limit = 3
+
+[profiles.work.export]
+limit = 4
+
+[profiles.home]
+out = "home"
+`
+
+// 顶层 < [命令] < [profiles.方案] < [profiles.方案.命令] < 环境变量
+func TestResolveConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, precedenceConfig)
+	t.Setenv("CURSOR2MD_REDACT", "false")
+
+	values := func(command, profile string) map[string][2]string {
+		t.Helper()
+		cfg, err := resolveConfig(command, profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make(map[string][2]string)
+		for _, v := range cfg.Values {
+			result[v.Key] = [2]string{v.Value, v.Source}
+		}
+		return result
+	}
+
+	got := values("export", "")
+	want := map[string][2]string{
+		"out":      {"top", path},
+		"format":   {"export", path + " [export]"},
+		"template": {"work", path + " [profiles.work]"},
+		"limit":    {"4", path + " [profiles.work.export]"},
+		"redact":   {"false", tr("config.env", "CURSOR2MD_REDACT")},
+	}
+	for key, w := range want {
+		if got[key] != w {
+			t.Errorf("export: %s = %v, want %v", key, got[key], w)
+		}
+	}
+	if _, ok := got["profile"]; ok {
+		t.Error("profile is returned as a value")
+	}
+
+	// 其他命令不使用[export]和[profiles.work.export]
+	if got := values("ls", ""); got["format"][0] != "top" || got["limit"][0] != "3" {
+		t.Errorf("ls: format %v, limit %v; want top and 3", got["format"], got["limit"])
+	}
+	// -profile优先于CURSOR2MD_PROFILE，CURSOR2MD_PROFILE优先于配置文件中的profile
+	t.Setenv("CURSOR2MD_PROFILE", "home")
+	if got := values("export", ""); got["out"][0] != "home" || got["limit"][0] != "2" {
+		t.Errorf("CURSOR2MD_PROFILE=home: out %v, limit %v; want home and 2", got["out"], got["limit"])
+	}
+	if got := values("export", "work"); got["limit"][0] != "4" {
+		t.Errorf("-profile work: limit %v, want 4", got["limit"])
+	}
+	if _, err := resolveConfig("export", "missing"); err == nil || !strings.Contains(err.Error(), tr("config.noProfile", "missing")) {
+		t.Errorf("missing profile: error = %v", err)
+	}
+}
+
+// 创建带有-db、-columns、-sort-desc和-limit参数的命令，用配置和args解析
+func parseTestFlags(t *testing.T, args ...string) (dbPaths, string, bool, int) {
+	t.Helper()
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	var paths dbPaths
+	registerDBFlags(fs, &paths)
+	columns := fs.String("columns", "", "")
+	sortDesc := fs.Bool("sort-desc", false, "")
+	limit := fs.Int("limit", 0, "")
+	parseFlags(fs, args)
+	return paths, *columns, *sortDesc, *limit
+}
+
+func TestParseFlagsConfig(t *testing.T) {
+	writeConfig(t, `
+db = ["/a.vscdb", "/b.vscdb"]
+columns = ["hash", "title"]
+sort-desc = true
+limit = 5
+unknown-key = "ignored"
+
+[ls]
+limit = 7
+`)
+	paths, columns, sortDesc, limit := parseTestFlags(t)
+	if !slices.Equal(paths, dbPaths{"/a.vscdb", "/b.vscdb"}) {
+		t.Errorf("db = %q, want each array item as a separate path", paths)
+	}
+	if columns != "hash,title" || !sortDesc || limit != 7 {
+		t.Errorf("columns, sort-desc, limit = %q, %v, %d; want hash,title, true, 7", columns, sortDesc, limit)
+	}
+
+	// 命令行参数优先，重复的-db不与配置中的数组合并
+	paths, columns, sortDesc, limit = parseTestFlags(t, "-db", "/c.vscdb", "-columns", "size", "-sort-desc=false", "-limit", "1")
+	if !slices.Equal(paths, dbPaths{"/c.vscdb"}) || columns != "size" || sortDesc || limit != 1 {
+		t.Errorf("with arguments: db %q, columns %q, sort-desc %v, limit %d", paths, columns, sortDesc, limit)
+	}
+
+	// 环境变量中的路径列表逐项设置，其他参数的值不拆分
+	list := strings.Join([]string{"/d.vscdb", "/e.vscdb"}, string(filepath.ListSeparator))
+	t.Setenv("CURSOR2MD_DB", list)
+	t.Setenv("CURSOR2MD_COLUMNS", "title"+string(filepath.ListSeparator)+"hash")
+	paths, columns, _, _ = parseTestFlags(t)
+	if !slices.Equal(paths, dbPaths{"/d.vscdb", "/e.vscdb"}) {
+		t.Errorf("CURSOR2MD_DB=%s: db = %q", list, paths)
+	}
+	if columns != "title"+string(filepath.ListSeparator)+"hash" {
+		t.Errorf("CURSOR2MD_COLUMNS: columns = %q", columns)
+	}
+}
+
+func TestSetFlag(t *testing.T) {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	var paths dbPaths
+	registerDBFlags(fs, &paths)
+	fs.Int("limit", 0, "")
+	fs.String("columns", "", "")
+
+	// 普通数据库不能作为-archive
+	archive := writeFixtureDB(t, nil)
+	tests := []struct {
+		value   configValue
+		wantErr bool
+	}{
+		{configValue{Key: "limit", Value: "ten"}, true},
+		{configValue{Key: "limit", Value: "1,2", Items: []string{"1", "2"}}, true},
+		{configValue{Key: "columns", Value: "hash,title", Items: []string{"hash", "title"}}, false},
+		{configValue{Key: "db", Value: "", Items: []string{}}, false},
+		{configValue{Key: "archive", Value: archive, Items: []string{archive}}, true},
+	}
+	for _, tt := range tests {
+		if err := setFlag(fs, tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("setFlag(%+v) error = %v, want error %v", tt.value, err, tt.wantErr)
+		}
+	}
+	if got := fs.Lookup("columns").Value.String(); got != "hash,title" {
+		t.Errorf("columns = %q, want hash,title", got)
+	}
+	if len(paths) != 0 {
+		t.Errorf("db = %q, want no paths from an empty array or an invalid archive", paths)
+	}
+}
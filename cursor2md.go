@@ -1,72 +1,41 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/M6ZeroG/cursor2md/store"
 )
 
-// 定义JSON结构体
-type ChatRecord struct {
-	Conversation []Message `json:"conversation"`
-	Name         string    `json:"name"`
-	Status       string    `json:"status"`
-	Context      struct {
-		FileSelections []struct {
-			Uri struct {
-				Path string `json:"path"`
-			} `json:"uri"`
-		} `json:"fileSelections"`
-	} `json:"context"`
-	CreatedAt int64 `json:"createdAt"`
-	EndedAt   int64
-}
+// 版本号
+const version = "0.0.2"
 
-type Message struct {
-	Type    int    `json:"type"`
-	Text    string `json:"text"`
-	Context struct {
-		FileSelections []struct {
-			Uri struct {
-				Path string `json:"path"`
-			} `json:"uri"`
-		} `json:"fileSelections"`
-		Selections []struct {
-			Text string `json:"text"`
-			Uri  struct {
-				Path string `json:"path"`
-			} `json:"uri"`
-		} `json:"selections"`
-	} `json:"context"`
-	TimingInfo struct {
-		ClientStartTime int64 `json:"clientStartTime"`
-		ClientEndTime   int64 `json:"clientEndTime"`
-	} `json:"timingInfo"`
-	CodeBlocks []struct {
-		Uri struct {
-			Path string `json:"path"`
-		} `json:"uri"`
-		Content    string `json:"content"`
-		LanguageId string `json:"languageId"`
-	} `json:"codeBlocks"`
-}
+// 会话记录的数据模型定义在store包中
+type (
+	ChatRecord    = store.ChatRecord
+	Message       = store.Message
+	AppliedChange = store.AppliedChange
+	ChangeHunk    = store.ChangeHunk
+)
 
 // 获取state.vscdb的默认路径
 func getDefaultDBPath() string {
 	var basePath string
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("获取用户主目录失败: %v\n", err)
+		fmt.Println(tr("err.homeDir", err))
 		return ""
 	}
 
@@ -81,7 +50,7 @@ func getDefaultDBPath() string {
 		// Linux: ~/.config/Cursor/User/workspaceStorage
 		basePath = filepath.Join(homeDir, ".config", "Cursor", "User", "workspaceStorage")
 	default:
-		fmt.Printf("不支持的操作系统: %s\n", runtime.GOOS)
+		fmt.Println(tr("err.unsupportedOS", runtime.GOOS))
 		return ""
 	}
 
@@ -90,27 +59,25 @@ func getDefaultDBPath() string {
 
 // 定义命令行参数配置
 type Config struct {
-	DBPath        string    // 数据库路径
-	OutputDir     string    // 输出目录路径
-	StartAfter    time.Time // 开始时间下限
-	StartBefore   time.Time // 开始时间上限
-	EndAfter      time.Time // 结束时间下限
-	EndBefore     time.Time // 结束时间上限
-	HasTimeFilter bool      // 是否启用时间过滤
-	JsonOutput    bool      // 是否输出JSON格式
-	SortDesc      bool      // 是否按时间降序排序（从新到旧）
-	ByName        bool      // 是否在文件名前添加序号
-}
-
-// 检查记录是否包含有效内容
-func hasValidContent(record ChatRecord) bool {
-	if strings.HasPrefix(record.Name, "composerData:") {
-		return false
-	}
-	if len(record.Conversation) == 0 {
-		return false
-	}
-	return true
+	DBPaths       dbPaths        // 数据库文件或目录，多个时合并其中的会话
+	OutputDir     string         // 输出目录路径
+	StartAfter    time.Time      // 开始时间下限
+	StartBefore   time.Time      // 开始时间上限
+	EndAfter      time.Time      // 结束时间下限
+	EndBefore     time.Time      // 结束时间上限
+	HasTimeFilter bool           // 是否启用时间过滤
+	JsonOutput    bool           // 是否输出JSON格式
+	SortDesc      bool           // 是否按时间降序排序（从新到旧）
+	ByName        bool           // 是否在文件名前添加序号
+	SortBy        string         // 排序字段: start, end, title, messages, size
+	Limit         int            // 最多返回的会话数量，0表示不限制
+	Offset        int            // 跳过的会话数量
+	Columns       []string       // ls命令输出的列
+	Redact        bool           // 是否在输出前替换敏感信息
+	Anonymizer    *anonymizer    // 路径和个人信息匿名化处理器，为nil时不处理
+	Verbose       bool           // 是否列出每条被跳过的记录
+	Strict        bool           // 有会话导出失败时以错误结束
+	GitLink       gitLinkOptions // 导出时关联git提交的设置
 }
 
 // 解析时间参数
@@ -128,23 +95,220 @@ func parseTimeArg(timeStr string) (time.Time, error) {
 			return t, nil
 		}
 	}
-	return time.Time{}, fmt.Errorf("无效的时间格式: %s", timeStr)
+	return time.Time{}, errors.New(tr("err.timeFormat", timeStr))
 }
 
 // 会话信息结构体
 type SessionInfo struct {
-	Hash      string    // 会话哈希值
-	Title     string    // 会话标题
-	StartTime time.Time // 开始时间
-	EndTime   time.Time // 结束时间
+	Hash         string      // 会话哈希值
+	Title        string      // 会话标题
+	StartTime    time.Time   // 开始时间
+	EndTime      time.Time   // 结束时间
+	MessageCount int         // 消息数量
+	Size         int         // 原始数据字节数
+	Workspace    string      // 相关文件的公共目录
+	Source       string      // 会话所在的数据库文件
+	Tokens       *TokenUsage `json:",omitempty"` // 估算的token数量和费用
+	Record       *ChatRecord `json:"-"`          // 解析后的完整记录
 }
 
 // 在SessionInfo结构体后添加新的结构体
 type SessionListResponse struct {
-	Sessions []SessionInfo `json:"sessions"`
-	Total    int           `json:"total"`
-	Success  bool          `json:"success"`
-	Error    *string       `json:"error,omitempty"`
+	Sessions []SessionInfo   `json:"sessions"`
+	Total    int             `json:"total"`
+	Matched  int             `json:"matched"`
+	Skipped  []store.Skipped `json:"skipped,omitempty"`
+	Success  bool            `json:"success"`
+	Error    *string         `json:"error,omitempty"`
+	Code     string          `json:"code,omitempty"`
+}
+
+// ls命令支持的列
+var sessionColumns = []string{"hash", "start", "end", "title", "messages", "size", "workspace", "source", "tokens", "cost"}
+
+// ls命令默认输出的列
+var defaultSessionColumns = []string{"hash", "start", "end", "title"}
+
+// 解析-columns参数
+func parseColumns(columnsStr string) ([]string, error) {
+	if strings.TrimSpace(columnsStr) == "" {
+		return defaultSessionColumns, nil
+	}
+	var columns []string
+	for _, col := range strings.Split(columnsStr, ",") {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if col == "" {
+			continue
+		}
+		valid := false
+		for _, c := range sessionColumns {
+			if c == col {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, errors.New(tr("err.column", col, strings.Join(sessionColumns, ",")))
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// 从数据库中加载符合条件的会话，按配置排序并分页
+// 返回分页后的会话以及分页前匹配的会话总数
+func loadSessions(st *store.Merged, config Config) ([]SessionInfo, int, error) {
+	sessions, matched, _, err := loadSessionsWithSkips(st, config)
+	return sessions, matched, err
+}
+
+// 将读取的会话转换为列表中的会话信息
+func newSessionInfo(s store.Session) SessionInfo {
+	return SessionInfo{
+		Hash:         s.Hash,
+		Title:        s.Title,
+		StartTime:    s.StartTime,
+		EndTime:      s.EndTime,
+		MessageCount: s.MessageCount,
+		Size:         s.Size,
+		Workspace:    s.Workspace,
+		Source:       s.Source,
+		Record:       s.Record,
+	}
+}
+
+// 与loadSessions相同，同时返回读取时被跳过的记录
+func loadSessionsWithSkips(st *store.Merged, config Config) ([]SessionInfo, int, []store.Skipped, error) {
+	var sessions []SessionInfo
+	var skipped []store.Skipped
+	onSkip := func(s store.Skipped) { skipped = append(skipped, s) }
+	for s, err := range st.Scan(context.Background(), config.filter(), onSkip) {
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		sessions = append(sessions, newSessionInfo(s))
+	}
+
+	if err := sortSessions(sessions, config.SortBy, config.SortDesc); err != nil {
+		return nil, 0, nil, err
+	}
+
+	matched := len(sessions)
+	if config.Offset > 0 {
+		if config.Offset >= len(sessions) {
+			sessions = nil
+		} else {
+			sessions = sessions[config.Offset:]
+		}
+	}
+	if config.Limit > 0 && len(sessions) > config.Limit {
+		sessions = sessions[:config.Limit]
+	}
+	return sessions, matched, skipped, nil
+}
+
+// 按原因统计被跳过的记录，verbose时列出每条记录的键
+func printSkipped(skipped []store.Skipped, verbose bool) {
+	if len(skipped) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	var reasons []string
+	for _, s := range skipped {
+		if counts[s.Reason] == 0 {
+			reasons = append(reasons, s.Reason)
+		}
+		counts[s.Reason]++
+	}
+	sort.Strings(reasons)
+	parts := make([]string, len(reasons))
+	for i, r := range reasons {
+		parts[i] = fmt.Sprintf("%s %d", r, counts[r])
+	}
+	fmt.Println(tr("skipped", len(skipped), strings.Join(parts, ", ")))
+	if !verbose {
+		return
+	}
+	for _, s := range skipped {
+		key := s.Key
+		// 合并多个数据库时在键前加上所在的数据库
+		if s.Source != "" {
+			key = s.Source + " " + key
+		}
+		if s.Error != "" {
+			fmt.Printf("  [%s] %s: %s\n", s.Reason, key, s.Error)
+		} else {
+			fmt.Printf("  [%s] %s\n", s.Reason, key)
+		}
+	}
+}
+
+// 按指定字段对会话排序，相同值时按开始时间和哈希值保证顺序稳定
+func sortSessions(sessions []SessionInfo, sortBy string, descending bool) error {
+	var less func(a, b SessionInfo) int
+	switch sortBy {
+	case "", "start":
+		less = func(a, b SessionInfo) int { return a.StartTime.Compare(b.StartTime) }
+	case "end":
+		less = func(a, b SessionInfo) int { return a.EndTime.Compare(b.EndTime) }
+	case "title":
+		less = func(a, b SessionInfo) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) }
+	case "messages":
+		less = func(a, b SessionInfo) int { return a.MessageCount - b.MessageCount }
+	case "size":
+		less = func(a, b SessionInfo) int { return a.Size - b.Size }
+	default:
+		return withCode(codeInvalidArg, errors.New(tr("err.sortField", sortBy)))
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		c := less(sessions[i], sessions[j])
+		if c == 0 {
+			c = sessions[i].StartTime.Compare(sessions[j].StartTime)
+		}
+		if c == 0 {
+			c = strings.Compare(sessions[i].Hash, sessions[j].Hash)
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+	return nil
+}
+
+// 格式化会话的某一列
+func formatSessionColumn(s SessionInfo, column string) string {
+	switch column {
+	case "hash":
+		return s.Hash
+	case "start":
+		return s.StartTime.Format("2006-01-02")
+	case "end":
+		if s.EndTime.IsZero() || s.EndTime.Unix() == 0 {
+			return tr("ls.notEnded")
+		}
+		return s.EndTime.Format("2006-01-02")
+	case "title":
+		return s.Title
+	case "messages":
+		return fmt.Sprintf("%d", s.MessageCount)
+	case "size":
+		return fmt.Sprintf("%d", s.Size)
+	case "workspace":
+		return s.Workspace
+	case "source":
+		return s.Source
+	case "tokens":
+		if s.Tokens != nil {
+			return fmt.Sprintf("%d", s.Tokens.Total)
+		}
+	case "cost":
+		if s.Tokens != nil {
+			return formatCost(s.Tokens.Cost)
+		}
+	}
+	return ""
 }
 
 // 在文件开头添加新的结构体定义
@@ -159,137 +323,152 @@ type ExportedSession struct {
 	OutputPath string    `json:"outputPath"`
 	StartTime  time.Time `json:"startTime"`
 	EndTime    time.Time `json:"endTime"`
+	Source     string    `json:"source"` // 会话所在的数据库文件
+	// 各类敏感信息的替换次数，仅在启用脱敏时输出
+	Redactions map[string]int `json:"redactions,omitempty"`
+	// 估算的token数量和费用
+	Tokens *TokenUsage `json:"tokens,omitempty"`
 }
 
 type ExportResponse struct {
 	Success  bool              `json:"success"`
 	Exported []ExportedSession `json:"exported"`
 	Total    int               `json:"total"`
+	Skipped  []store.Skipped   `json:"skipped,omitempty"`
 	Error    *string           `json:"error,omitempty"`
+	Code     string            `json:"code,omitempty"`
 }
 
-// 修改listSessions函数，添加json参数
-func listSessions(dbPath string, jsonOutput bool) error {
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		if jsonOutput {
-			errMsg := fmt.Sprintf("数据库文件不存在: %s", dbPath)
-			response := SessionListResponse{
-				Sessions: nil,
-				Total:    0,
-				Success:  false,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("数据库文件不存在: %s", dbPath)
-	}
-
-	db, err := sql.Open("sqlite3", dbPath)
+// 修改listSessions函数，使用Config进行过滤、排序和分页
+func listSessions(config Config) error {
+	db, err := openDB(config.DBPaths)
 	if err != nil {
-		if jsonOutput {
-			errMsg := fmt.Sprintf("打开数据库失败: %v", err)
-			response := SessionListResponse{
-				Sessions: nil,
-				Total:    0,
-				Success:  false,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("打开数据库失败: %v", err)
+		return err
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT key, value FROM cursorDiskKV")
+	sessions, matched, skipped, err := loadSessionsWithSkips(db, config)
 	if err != nil {
-		return fmt.Errorf("查询数据库失败: %v", err)
+		return err
 	}
-	defer rows.Close()
-
-	var sessions []SessionInfo
-	for rows.Next() {
-		var key, value string
-		if err := rows.Scan(&key, &value); err != nil {
-			continue
-		}
-		if value == "[]" || key == "inlineDiffsData" {
-			continue
-		}
-
-		var record ChatRecord
-		if err := json.Unmarshal([]byte(value), &record); err != nil {
-			continue
-		}
-		if !hasValidContent(record) {
-			continue
-		}
-
-		if len(record.Conversation) > 0 {
-			record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
-		}
-
-		session := SessionInfo{
-			Hash:      strings.TrimPrefix(key, "composerData:"),
-			Title:     record.Name,
-			StartTime: time.Unix(record.CreatedAt/1000, 0),
-			EndTime:   time.Unix(record.EndedAt/1000, 0),
-		}
-		sessions = append(sessions, session)
+	// 只在输出token或费用列时估算
+	if slices.Contains(config.Columns, "tokens") || slices.Contains(config.Columns, "cost") {
+		defaultTokenEstimator.estimateSessions(sessions)
+	}
+	if config.Anonymizer != nil {
+		config.Anonymizer.anonymizeSessions(sessions)
 	}
 
-	if jsonOutput {
+	if config.JsonOutput {
 		response := SessionListResponse{
 			Sessions: sessions,
 			Total:    len(sessions),
+			Matched:  matched,
+			Skipped:  skipped,
 			Success:  true,
 		}
 		jsonData, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
-			return fmt.Errorf("JSON序列化失败: %v", err)
+			return errors.New(tr("err.json", err))
 		}
 		fmt.Println(string(jsonData))
 		return nil
 	}
 
 	if len(sessions) == 0 {
-		fmt.Println("数据库中没有有效的会话记录")
+		fmt.Println(tr("ls.empty"))
+		printSkipped(skipped, config.Verbose)
 		return nil
 	}
 
-	maxTitleLen, maxHashLen := 0, 0
-	for _, s := range sessions {
-		if len(s.Title) > maxTitleLen {
-			maxTitleLen = len(s.Title)
-		}
-		if len(s.Hash) > maxHashLen {
-			maxHashLen = len(s.Hash)
+	columns := config.Columns
+	if len(columns) == 0 {
+		columns = defaultSessionColumns
+	}
+
+	// 排序完成后再计算每列宽度
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		widths[i] = len(col)
+		for _, s := range sessions {
+			if l := len(formatSessionColumn(s, col)); l > widths[i] {
+				widths[i] = l
+			}
 		}
 	}
 
-	format := fmt.Sprintf("%%-%ds  %%10s  %%10s  %%-%ds\n", maxHashLen, maxTitleLen)
-	fmt.Printf(format, "HASH", "START TIME", "END TIME", "TITLE")
-	fmt.Printf(strings.Repeat("-", maxHashLen+maxTitleLen+24) + "\n")
+	printRow := func(values []string) {
+		for i, v := range values {
+			if i > 0 {
+				fmt.Print("  ")
+			}
+			if i == len(values)-1 {
+				fmt.Print(v)
+			} else {
+				fmt.Printf("%-*s", widths[i], v)
+			}
+		}
+		fmt.Println()
+	}
 
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].StartTime.Before(sessions[j].StartTime)
-	})
+	header := make([]string, len(columns))
+	totalWidth := 0
+	for i, col := range columns {
+		header[i] = tr("column." + col)
+		totalWidth += widths[i] + 2
+	}
+	printRow(header)
+	fmt.Println(strings.Repeat("-", totalWidth-2))
 
 	for _, s := range sessions {
-		endTimeStr := s.EndTime.Format("2006-01-02")
-		if s.EndTime.IsZero() {
-			endTimeStr = "未结束"
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i] = formatSessionColumn(s, col)
 		}
-		fmt.Printf(format, s.Hash, s.StartTime.Format("2006-01-02"), endTimeStr, s.Title)
+		printRow(values)
 	}
 
-	fmt.Printf("\n共有 %d 个会话\n", len(sessions))
+	if len(sessions) < matched {
+		fmt.Println("\n" + tr("ls.shown", len(sessions), matched))
+	} else {
+		fmt.Println("\n" + tr("ls.total", len(sessions)))
+	}
+	printSkipped(skipped, config.Verbose)
 	return nil
 }
 
+// 输出ls命令的错误并记录退出状态，prefix为文本输出时错误信息的前缀
+func printListError(err error, jsonOutput bool, prefix string) {
+	code := failed(err)
+	if jsonOutput {
+		errMsg := err.Error()
+		jsonData, _ := json.MarshalIndent(SessionListResponse{Success: false, Error: &errMsg, Code: code}, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	if prefix == "" {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s: %v\n", prefix, err)
+}
+
+// 输出export命令的错误并记录退出状态，prefix为文本输出时错误信息的前缀
+func printExportError(err error, jsonOutput bool, prefix string) {
+	code := failed(err)
+	if jsonOutput {
+		errMsg := err.Error()
+		jsonData, _ := json.MarshalIndent(ExportResponse{Success: false, Error: &errMsg, Code: code}, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	if prefix == "" {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s: %v\n", prefix, err)
+}
+
 // 修改 generateNumberedFileName 函数
 func generateNumberedFileName(totalSessions int, index int, descending bool, name string) string {
 	// 计算需要的序号位数 (例如: 100条记录需要3位数)
@@ -330,212 +509,193 @@ func generateNumberedFileName(totalSessions int, index int, descending bool, nam
 	return result
 }
 
-// 修改exportSessions函数
-func exportSessions(config Config) error {
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		if config.JsonOutput {
-			errMsg := fmt.Sprintf("数据库文件不存在: %s", config.DBPath)
-			response := ExportResponse{
-				Success:  false,
-				Exported: nil,
-				Total:    0,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("数据库文件不存在: %s", config.DBPath)
+// 替换Windows文件系统不支持的字符，文件名为空时使用默认名称
+func sanitizeFileName(name string) string {
+	safeName := strings.NewReplacer(
+		"<", "_",
+		">", "_",
+		":", "_",
+		"\"", "_",
+		"/", "_",
+		"\\", "_",
+		"|", "_",
+		"?", "_",
+		"*", "_",
+	).Replace(name)
+	if strings.TrimSpace(safeName) == "" {
+		safeName = "untitled"
+	}
+	return safeName
+}
+
+// 生成markdown文件路径，文件已存在时添加开始时间作为后缀
+func uniqueMarkdownPath(outputDir string, name string, startTime time.Time) string {
+	safeName := sanitizeFileName(name)
+	mdFile := filepath.Join(outputDir, safeName+".md")
+	if _, err := os.Stat(mdFile); err == nil {
+		timestamp := startTime.Format("20060102-150405")
+		mdFile = filepath.Join(outputDir, safeName+"-"+timestamp+".md")
 	}
+	return mdFile
+}
 
-	db, err := sql.Open("sqlite3", config.DBPath)
+// 修改exportSessions函数
+func exportSessions(config Config) error {
+	db, err := openDB(config.DBPaths)
 	if err != nil {
-		return fmt.Errorf("打开数据库失败: %v", err)
+		return err
 	}
 	defer db.Close()
 
 	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
-		return fmt.Errorf("创建输出目录失败: %v", err)
+		return withCode(codeWriteError, errors.New(tr("err.mkdirOut", err)))
 	}
 
-	rows, err := db.Query("SELECT key, value FROM cursorDiskKV")
+	sessions, _, skipped, err := loadSessionsWithSkips(db, config)
 	if err != nil {
-		return fmt.Errorf("查询数据库失败: %v", err)
+		return err
+	}
+	commits, err := relatedCommits(sessions, config.GitLink)
+	if err != nil {
+		return err
 	}
-	defer rows.Close()
 
 	var exportedSessions []ExportedSession
-	for rows.Next() {
-		var key, value string
-		if err := rows.Scan(&key, &value); err != nil {
-			continue
-		}
-		if value == "[]" || key == "inlineDiffsData" {
-			continue
-		}
-
-		var record ChatRecord
-		if err := json.Unmarshal([]byte(value), &record); err != nil {
-			continue
-		}
-		if !hasValidContent(record) {
-			continue
-		}
-
-		if len(record.Conversation) > 0 {
-			record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
-		}
-
-		if !config.isInTimeRange(record) {
-			continue
-		}
-
+	records := make(map[string]*ChatRecord, len(sessions))
+	for _, s := range sessions {
 		exportedSession := ExportedSession{
-			Hash:       strings.TrimPrefix(key, "composerData:"),
-			Title:      record.Name,
-			StartTime:  time.Unix(record.CreatedAt/1000, 0),
-			EndTime:    time.Unix(record.EndedAt/1000, 0),
+			Hash:      s.Hash,
+			Title:     s.Title,
+			StartTime: s.StartTime,
+			EndTime:   s.EndTime,
+			Source:    s.Source,
 		}
 		exportedSessions = append(exportedSessions, exportedSession)
+		records[s.Hash] = s.Record
 	}
 
-	// 先对会话进行排序
-	sortExportedSessions(exportedSessions, config.SortDesc)
-	
-
-	// 然后生成文件
+	// 会话已由loadSessions按时间排序，按顺序生成文件
 	totalSessions := len(exportedSessions)
 	for i, session := range exportedSessions {
-		// 重新查询记录以获取完整内容
-		key := "composerData:" + session.Hash
-		var value string
-		if err := db.QueryRow("SELECT value FROM cursorDiskKV WHERE key = ?", key).Scan(&value); err != nil {
-			continue
+		record := exportRecord{ChatRecord: *records[session.Hash], RelatedCommits: commits[session.Hash]}
+		if config.Redact {
+			record, exportedSessions[i].Redactions = redactExportRecord(record)
+			exportedSessions[i].Title = record.Name
 		}
-
-		var record ChatRecord
-		if err := json.Unmarshal([]byte(value), &record); err != nil {
-			continue
+		if config.Anonymizer != nil {
+			record = config.Anonymizer.anonymizeExportRecord(record)
+			exportedSessions[i].Title = record.Name
+			exportedSessions[i].Source = config.Anonymizer.anonymizeString(session.Source, "")
 		}
+		usage := defaultTokenEstimator.recordUsage(record.ChatRecord)
+		exportedSessions[i].Tokens = &usage
 
-		mdContent := convertToMarkdown(record)
+		mdContent := record.markdown()
 		var mdFile string
 		if config.ByName {
 			// 使用当前索引生成序号
 			fileName := generateNumberedFileName(totalSessions, i, config.SortDesc, record.Name)
 			mdFile = filepath.Join(config.OutputDir, fileName)
 		} else {
-			// 替换Windows文件系统不支持的字符
-			safeName := strings.NewReplacer(
-				"<", "_",
-				">", "_",
-				":", "_",
-				"\"", "_",
-				"/", "_",
-				"\\", "_",
-				"|", "_",
-				"?", "_",
-				"*", "_",
-			).Replace(record.Name)
-			
-			// 如果文件名为空，使用默认名称
-			if strings.TrimSpace(safeName) == "" {
-				safeName = "untitled"
-			}
-			
-			// 检查文件是否已存在，如果存在则添加时间戳
-			baseFile := filepath.Join(config.OutputDir, safeName+".md")
-			mdFile = baseFile
-			if _, err := os.Stat(baseFile); err == nil {
-				// 文件已存在，添加时间戳
-				timestamp := session.StartTime.Format("20060102-150405")
-				mdFile = filepath.Join(config.OutputDir, safeName+"-"+timestamp+".md")
-			}
+			mdFile = uniqueMarkdownPath(config.OutputDir, record.Name, session.StartTime)
 		}
 		
 		if err := ioutil.WriteFile(mdFile, []byte(mdContent), 0644); err != nil {
+			skipped = append(skipped, store.Skipped{Key: mdFile, Reason: skipWriteError, Error: err.Error()})
 			continue
 		}
 		
 		// 更新输出路径
 		exportedSessions[i].OutputPath = mdFile
+		if config.Anonymizer != nil {
+			exportedSessions[i].OutputPath = config.Anonymizer.anonymizeString(mdFile, "")
+		}
+	}
+
+	// 写入失败的会话已记录在skipped中
+	written := exportedSessions[:0]
+	for _, session := range exportedSessions {
+		if session.OutputPath != "" {
+			written = append(written, session)
+		}
+	}
+	exportedSessions = written
+
+	// 严格模式下有会话导出失败时以错误结束，此时仍然输出已导出的会话
+	var strictErr error
+	if failures, code := failedSessions(skipped); config.Strict && len(failures) > 0 {
+		strictErr = withCode(code, errors.New(tr("export.failedCount", len(failures))))
 	}
 
 	if config.JsonOutput {
 		response := ExportResponse{
-			Success:  true,
+			Success:  strictErr == nil,
 			Exported: exportedSessions,
 			Total:    len(exportedSessions),
+			Skipped:  skipped,
+		}
+		if strictErr != nil {
+			errMsg := strictErr.Error()
+			response.Error = &errMsg
+			response.Code = failed(strictErr)
 		}
 		jsonData, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
-			return fmt.Errorf("JSON序列化失败: %v", err)
+			return errors.New(tr("err.json", err))
 		}
 		fmt.Println(string(jsonData))
 	} else {
 		// 按时间顺序打印导出信息
 		for _, session := range exportedSessions {
 			fileName := filepath.Base(session.OutputPath)
-			fmt.Printf("导出会话: %s (开始时间: %s)\n", 
-				fileName,
-				session.StartTime.Format("2006-01-02 15:04:05"))
+			fmt.Println(tr("export.session", fileName, session.StartTime.Format("2006-01-02 15:04:05")))
+			if len(session.Redactions) > 0 {
+				fmt.Println("  " + tr("export.redacted", formatRedactionCounts(session.Redactions)))
+			}
 		}
-		fmt.Printf("\n成功导出 %d 个会话到 %s\n", len(exportedSessions), config.OutputDir)
+		fmt.Println("\n" + tr("export.done", len(exportedSessions), config.OutputDir))
+		printSkipped(skipped, config.Verbose)
 	}
 
-	return nil
+	// JSON输出中已经包含了严格模式的错误
+	if config.JsonOutput {
+		return nil
+	}
+	return strictErr
 }
 
-// 检查时间范围
-func (c *Config) isInTimeRange(record ChatRecord) bool {
+// 转换为store的时间过滤条件
+func (c *Config) filter() store.Filter {
 	if !c.HasTimeFilter {
-		return true
+		return store.Filter{}
 	}
-
-	startTime := time.Unix(record.CreatedAt/1000, 0)
-	if len(record.Conversation) > 0 {
-		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
-	}
-	var endTime time.Time
-	if record.EndedAt > 0 {
-		endTime = time.Unix(record.EndedAt/1000, 0)
-	}
-
-	if !c.StartAfter.IsZero() && startTime.Before(c.StartAfter) {
-		return false
-	}
-	if !c.StartBefore.IsZero() && startTime.After(c.StartBefore) {
-		return false
+	return store.Filter{
+		StartAfter:  c.StartAfter,
+		StartBefore: c.StartBefore,
+		EndAfter:    c.EndAfter,
+		EndBefore:   c.EndBefore,
 	}
-	if record.EndedAt > 0 {
-		if !c.EndAfter.IsZero() && endTime.Before(c.EndAfter) {
-			return false
-		}
-		if !c.EndBefore.IsZero() && endTime.After(c.EndBefore) {
-			return false
-		}
-	}
-
-	return true
 }
 
 // 转换为Markdown
 func convertToMarkdown(record ChatRecord) string {
 	var md strings.Builder
-	md.WriteString(fmt.Sprintf("# %s\n\n", record.Name))
-
 	if len(record.Conversation) > 0 {
 		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
 	}
+	if markdownFrontMatter {
+		md.WriteString(frontMatter(record))
+	}
+	md.WriteString(fmt.Sprintf("# %s\n\n", record.Name))
 
-	md.WriteString("## 会话信息\n\n")
-	md.WriteString(fmt.Sprintf("- 开始时间: \t%s\n", time.Unix(record.CreatedAt/1000, 0).Format("2006-01-02 15:04:05")))
+	md.WriteString("## " + tr("md.sessionInfo") + "\n\n")
+	md.WriteString(fmt.Sprintf("- %s: \t%s\n", tr("md.startTime"), time.Unix(record.CreatedAt/1000, 0).Format("2006-01-02 15:04:05")))
 	if record.EndedAt > 0 {
-		md.WriteString(fmt.Sprintf("- 结束时间:\t%s\n", time.Unix(record.EndedAt/1000, 0).Format("2006-01-02 15:04:05")))
+		md.WriteString(fmt.Sprintf("- %s:\t%s\n", tr("md.endTime"), time.Unix(record.EndedAt/1000, 0).Format("2006-01-02 15:04:05")))
 	}
 
 	if len(record.Context.FileSelections) > 0 {
-		md.WriteString("- 相关文件:\t")
+		md.WriteString("- " + tr("md.files") + ":\t")
 		files := make([]string, 0, len(record.Context.FileSelections))
 		for _, file := range record.Context.FileSelections {
 			filename := filepath.Base(file.Uri.Path)
@@ -551,7 +711,7 @@ func convertToMarkdown(record ChatRecord) string {
 		case 1:
 			md.WriteString("## User\n\n")
 			if len(msg.Context.FileSelections) > 0 {
-				md.WriteString("引用的文件:\t")
+				md.WriteString(tr("md.fileSelections") + ":\t")
 				files := make([]string, 0, len(msg.Context.FileSelections))
 				for _, file := range msg.Context.FileSelections {
 					filename := filepath.Base(file.Uri.Path)
@@ -561,11 +721,11 @@ func convertToMarkdown(record ChatRecord) string {
 				md.WriteString("\n\n")
 			}
 			if len(msg.Context.Selections) > 0 {
-				md.WriteString("引用的代码片段:\n")
+				md.WriteString(tr("md.selections") + ":\n")
 				for _, sel := range msg.Context.Selections {
 					if sel.Uri.Path != "" {
 						filename := filepath.Base(sel.Uri.Path)
-						md.WriteString(fmt.Sprintf("From [%s](%s):\n", filename, sel.Uri.Path))
+						md.WriteString(tr("md.selectionFrom", filename, sel.Uri.Path) + "\n")
 					}
 					md.WriteString(sel.Text)
 					md.WriteString("\n")
@@ -577,7 +737,12 @@ func convertToMarkdown(record ChatRecord) string {
 			md.WriteString("## Cursor\n\n")
 			md.WriteString(msg.Text + "\n\n")
 			for _, block := range msg.CodeBlocks {
-				if block.Content != "" {
+				if block.Content == "" {
+					continue
+				}
+				// 启用-diff且原始文件存在时显示差异，replace模式下不再显示完整代码块
+				diff, hasDiff := codeBlockDiff(block.Uri.Path, block.Content)
+				if !hasDiff || defaultCodeDiffOptions.Mode == "both" {
 					if block.Uri.Path != "" {
 						filename := filepath.Base(block.Uri.Path)
 						md.WriteString(fmt.Sprintf("```%s:[%s](%s)\n", block.LanguageId, filename, block.Uri.Path))
@@ -587,166 +752,195 @@ func convertToMarkdown(record ChatRecord) string {
 					md.WriteString(block.Content + "\n")
 					md.WriteString("```\n\n")
 				}
+				if hasDiff {
+					filename := filepath.Base(block.Uri.Path)
+					if diff == "" {
+						md.WriteString(tr("md.unchanged", filename, block.Uri.Path) + "\n\n")
+					} else {
+						md.WriteString(fmt.Sprintf("```diff:[%s](%s)\n", filename, block.Uri.Path))
+						md.WriteString(diff)
+						md.WriteString("```\n\n")
+					}
+				}
 			}
 		}
 	}
 
+	md.WriteString(appliedChangesMarkdown(record.AppliedChanges))
+
 	return md.String()
 }
 
-// 修改exportSingleSession函数
-func exportSingleSession(dbPath string, outputDir string, hash string, jsonOutput bool, sortDesc bool, byName bool) error {
-	// 检查文件是否存在
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		if jsonOutput {
-			errMsg := fmt.Sprintf("数据库文件不存在: %s", dbPath)
-			response := ExportResponse{
-				Success:  false,
-				Exported: nil,
-				Total:    0,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("数据库文件不存在: %s", dbPath)
+// 是否在Markdown开头输出包含会话信息和token估算的YAML front matter，由-front-matter参数设置
+var markdownFrontMatter bool
+
+// 生成YAML front matter，字符串使用JSON格式的双引号字符串，同时也是合法的YAML
+// 只有会话记录了模型或指定了-model、-prices时才输出模型和费用
+func frontMatter(record ChatRecord) string {
+	quote := func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
 	}
+	var fm strings.Builder
+	fm.WriteString("---\n")
+	fmt.Fprintf(&fm, "title: %s\n", quote(record.Name))
+	fmt.Fprintf(&fm, "created: %s\n", time.UnixMilli(record.CreatedAt).Format(time.RFC3339))
+	if record.EndedAt > 0 {
+		fmt.Fprintf(&fm, "ended: %s\n", time.UnixMilli(record.EndedAt).Format(time.RFC3339))
+	}
+	fmt.Fprintf(&fm, "messages: %d\n", len(record.Conversation))
+	usage := defaultTokenEstimator.recordUsage(record)
+	fmt.Fprintf(&fm, "tokens:\n  input: %d\n  output: %d\n  total: %d\n", usage.Input, usage.Output, usage.Total)
+	if defaultTokenEstimator.hasCost(record) {
+		fmt.Fprintf(&fm, "model: %s\n", quote(usage.Model))
+		fmt.Fprintf(&fm, "cost: %s\n", strconv.FormatFloat(usage.Cost, 'f', -1, 64))
+	}
+	fm.WriteString("---\n\n")
+	return fm.String()
+}
+
+// 修改exportSingleSession函数
+// 严格模式与批量导出相同：合并多个数据库时其他数据库中的版本无法解析也算导出失败
+func exportSingleSession(config Config, hash string) error {
+	outputDir, anon := config.OutputDir, config.Anonymizer
 
 	// 打开SQLite数据库
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := openDB(config.DBPaths)
 	if err != nil {
-		return fmt.Errorf("打开数据库失败: %v", err)
+		return err
 	}
 	defer db.Close()
 
 	// 创建输出目录
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("创建输出目录失败: %v", err)
+		return withCode(codeWriteError, errors.New(tr("err.mkdirOut", err)))
 	}
 
-	// 查询指定的会话记录
-	key := "composerData:" + hash
-	var value string
-	err = db.QueryRow("SELECT value FROM cursorDiskKV WHERE key = ?", key).Scan(&value)
-	if err == sql.ErrNoRows {
-		if jsonOutput {
-			errMsg := fmt.Sprintf("未找到哈希值为 %s 的会话", hash)
-			response := ExportResponse{
-				Success:  false,
-				Exported: nil,
-				Total:    0,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("未找到哈希值为 %s 的会话", hash)
+	// 查询指定的会话记录，同时关联Cursor实际应用的修改
+	var skipped []store.Skipped
+	found, err := db.ScanSession(context.Background(), hash, func(s store.Skipped) { skipped = append(skipped, s) })
+	if errors.Is(err, store.ErrNotFound) {
+		err = withCode(codeNotFound, errors.New(tr("err.sessionHash", hash)))
 	}
 	if err != nil {
-		if jsonOutput {
-			errMsg := fmt.Sprintf("查询数据库失败: %v", err)
-			response := ExportResponse{
-				Success:  false,
-				Exported: nil,
-				Total:    0,
-				Error:    &errMsg,
-			}
-			jsonData, _ := json.MarshalIndent(response, "", "  ")
-			fmt.Println(string(jsonData))
-			return nil
-		}
-		return fmt.Errorf("查询数据库失败: %v", err)
+		return err
 	}
-
-	// 解析JSON
-	var record ChatRecord
-	if err := json.Unmarshal([]byte(value), &record); err != nil {
-		return fmt.Errorf("解析JSON失败: %v", err)
+	// 关联git提交
+	commits, err := relatedCommits([]SessionInfo{newSessionInfo(found)}, config.GitLink)
+	if err != nil {
+		return err
 	}
+	record := exportRecord{ChatRecord: *found.Record, RelatedCommits: commits[found.Hash]}
 
-	// 检查是否有效
-	if !hasValidContent(record) {
-		return fmt.Errorf("会话内容无效")
+	// 替换敏感信息
+	var redactions map[string]int
+	if config.Redact {
+		record, redactions = redactExportRecord(record)
 	}
-
-	// 获取结束时间
-	if len(record.Conversation) > 0 {
-		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
+	if anon != nil {
+		record = anon.anonymizeExportRecord(record)
 	}
 
 	// 生成markdown内容
-	mdContent := convertToMarkdown(record)
+	mdContent := record.markdown()
 	var mdFile string
-	if byName {
+	if config.ByName {
 		// 创建一个只包含当前会话的切片用于生成序号
-		fileName := generateNumberedFileName(1, 0, sortDesc, record.Name)
+		fileName := generateNumberedFileName(1, 0, config.SortDesc, record.Name)
 		mdFile = filepath.Join(outputDir, fileName)
 	} else {
-		// 替换Windows文件系统不支持的字符
-		safeName := strings.NewReplacer(
-			"<", "_",
-			">", "_",
-			":", "_",
-			"\"", "_",
-			"/", "_",
-			"\\", "_",
-			"|", "_",
-			"?", "_",
-			"*", "_",
-		).Replace(record.Name)
-		
-		// 如果文件名为空，使用默认名称
-		if strings.TrimSpace(safeName) == "" {
-			safeName = "untitled"
-		}
-		
-		mdFile = filepath.Join(outputDir, safeName+".md")
+		mdFile = filepath.Join(outputDir, sanitizeFileName(record.Name)+".md")
 	}
 
 	if err := ioutil.WriteFile(mdFile, []byte(mdContent), 0644); err != nil {
-		return fmt.Errorf("写入markdown文件失败: %v", err)
+		return withCode(codeWriteError, errors.New(tr("err.writeMarkdown", err)))
 	}
 
-	if jsonOutput {
+	var strictErr error
+	if failures, code := failedSessions(skipped); config.Strict && len(failures) > 0 {
+		strictErr = withCode(code, errors.New(tr("export.failedCount", len(failures))))
+	}
+
+	if config.JsonOutput {
 		exportedSession := ExportedSession{
 			Hash:       hash,
 			Title:      record.Name,
 			OutputPath: mdFile,
 			StartTime:  time.Unix(record.CreatedAt/1000, 0),
 			EndTime:    time.Unix(record.EndedAt/1000, 0),
+			Source:     found.Source,
+			Redactions: redactions,
+		}
+		if anon != nil {
+			exportedSession.OutputPath = anon.anonymizeString(mdFile, "")
+			exportedSession.Source = anon.anonymizeString(found.Source, "")
 		}
+		usage := defaultTokenEstimator.recordUsage(record.ChatRecord)
+		exportedSession.Tokens = &usage
 		response := ExportResponse{
-			Success:  true,
+			Success:  strictErr == nil,
 			Exported: []ExportedSession{exportedSession},
 			Total:    1,
+			Skipped:  skipped,
+		}
+		if strictErr != nil {
+			errMsg := strictErr.Error()
+			response.Error = &errMsg
+			response.Code = failed(strictErr)
 		}
 		jsonData, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
-			return fmt.Errorf("JSON序列化失败: %v", err)
+			return errors.New(tr("err.json", err))
 		}
 		fmt.Println(string(jsonData))
-	} else {
-		fmt.Printf("成功导出会话: %s\n", record.Name)
+		// JSON输出中已经包含了严格模式的错误
+		return nil
 	}
 
-	return nil
+	fmt.Println(tr("export.single", record.Name))
+	if len(redactions) > 0 {
+		fmt.Println(tr("export.redacted", formatRedactionCounts(redactions)))
+	}
+	printSkipped(skipped, false)
+	return strictErr
 }
 
-// 添加排序函数
-func sortExportedSessions(sessions []ExportedSession, descending bool) {
-	sort.Slice(sessions, func(i, j int) bool {
-		if descending {
-			// 降序：新的在前（从新到旧）
-			return sessions[i].StartTime.After(sessions[j].StartTime)
+// 注册ls和export共用的时间过滤参数，返回在Parse之后调用的解析函数
+func registerTimeFilterFlags(fs *flag.FlagSet, config *Config) func() error {
+	var startAfterStr, startBeforeStr, endAfterStr, endBeforeStr string
+	fs.StringVar(&startAfterStr, "start-after", "", tr("flag.start-after"))
+	fs.StringVar(&startBeforeStr, "start-before", "", tr("flag.start-before"))
+	fs.StringVar(&endAfterStr, "end-after", "", tr("flag.end-after"))
+	fs.StringVar(&endBeforeStr, "end-before", "", tr("flag.end-before"))
+
+	return func() error {
+		var err error
+		if config.StartAfter, err = parseTimeArg(startAfterStr); err != nil {
+			return errors.New(tr("err.parseFlag", "start-after", err))
 		}
-		// 升序：旧的在前（从旧到新）
-		return sessions[i].StartTime.Before(sessions[j].StartTime)
-	})
+		if config.StartBefore, err = parseTimeArg(startBeforeStr); err != nil {
+			return errors.New(tr("err.parseFlag", "start-before", err))
+		}
+		if config.EndAfter, err = parseTimeArg(endAfterStr); err != nil {
+			return errors.New(tr("err.parseFlag", "end-after", err))
+		}
+		if config.EndBefore, err = parseTimeArg(endBeforeStr); err != nil {
+			return errors.New(tr("err.parseFlag", "end-before", err))
+		}
+		config.HasTimeFilter = !config.StartAfter.IsZero() || !config.StartBefore.IsZero() ||
+			!config.EndAfter.IsZero() || !config.EndBefore.IsZero()
+		return nil
+	}
 }
 
 func main() {
+	initLang(os.Args[1:])
+	runCommand()
+	os.Exit(exitStatus)
+}
+
+// 执行命令，失败时通过failed记录退出状态
+func runCommand() {
 	if len(os.Args) < 2 {
 		printHelp()
 		return
@@ -754,20 +948,46 @@ func main() {
 
 	switch os.Args[1] {
 	case "ls":
+		var config Config
+		var columnsStr string
 		lsCmd := flag.NewFlagSet("ls", flag.ExitOnError)
-		lsDBPath := lsCmd.String("db", "", "数据库文件路径 (默认: 系统默认路径)")
-		jsonOutput := lsCmd.Bool("json", false, "以JSON格式输出")
-		lsCmd.Parse(os.Args[2:])
-		dbPath := *lsDBPath
-		if dbPath == "" {
-			dbPath = getDefaultDBPath()
-			if dbPath == "" {
-				fmt.Println("无法确定默认数据库路径")
-				return
-			}
+		registerDBFlags(lsCmd, &config.DBPaths)
+		lsCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json"))
+		lsCmd.BoolVar(&config.SortDesc, "sort-desc", false, tr("flag.sort-desc"))
+		lsCmd.StringVar(&config.SortBy, "sort", "start", tr("flag.sort"))
+		lsCmd.IntVar(&config.Limit, "limit", 0, tr("flag.limit"))
+		lsCmd.IntVar(&config.Offset, "offset", 0, tr("flag.offset"))
+		lsCmd.BoolVar(&config.Verbose, "verbose", false, tr("flag.verbose"))
+		lsCmd.StringVar(&columnsStr, "columns", "", tr("flag.columns", strings.Join(sessionColumns, ",")))
+		anonymizeRules := lsCmd.String("anonymize", "", tr("flag.anonymize.ls"))
+		parseTimeFilters := registerTimeFilterFlags(lsCmd, &config)
+		parseTokenFlags := registerTokenFlags(lsCmd)
+		parseFlags(lsCmd, os.Args[2:])
+
+		var err error
+		if err = parseTimeFilters(); err == nil {
+			config.Columns, err = parseColumns(columnsStr)
 		}
-		if err := listSessions(dbPath, *jsonOutput); err != nil {
-			fmt.Printf("列出会话失败: %v\n", err)
+		if err == nil {
+			err = parseTokenFlags()
+		}
+		if err == nil {
+			config.Anonymizer, err = loadAnonymizer(*anonymizeRules)
+		}
+		if err == nil && (config.Limit < 0 || config.Offset < 0) {
+			err = errors.New(tr("err.negative"))
+		}
+		if err != nil {
+			printListError(withCode(codeInvalidArg, err), config.JsonOutput, "")
+			return
+		}
+
+		if !config.DBPaths.setDefault() {
+			printListError(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))), config.JsonOutput, "")
+			return
+		}
+		if err := listSessions(config); err != nil {
+			printListError(err, config.JsonOutput, tr("ls.failed"))
 		}
 
 	case "export":
@@ -776,48 +996,44 @@ func main() {
 			// 导出单个会话
 			hash := os.Args[2]
 			exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
-			dbPath := exportCmd.String("db", "", "数据库文件路径 (默认: 系统默认路径)")
-			outputDir := exportCmd.String("out", "markdown_output", "markdown文件输出目录")
-			jsonOutput := exportCmd.Bool("json", false, "以JSON格式输出")
-			sortDesc := exportCmd.Bool("sort-desc", true, "按时间降序排序（从新到旧）")
-			byName := exportCmd.Bool("byname", false, "在文件名前添加序号")
-			exportCmd.Parse(os.Args[3:])
+			var config Config
+			registerDBFlags(exportCmd, &config.DBPaths)
+			exportCmd.StringVar(&config.OutputDir, "out", "markdown_output", tr("flag.out"))
+			exportCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json"))
+			exportCmd.BoolVar(&config.ByName, "byname", false, tr("flag.byname"))
+			exportCmd.BoolVar(&config.Redact, "redact", false, tr("flag.redact"))
+			anonymizeRules := exportCmd.String("anonymize", "", tr("flag.anonymize"))
+			exportCmd.BoolVar(&config.Strict, "strict", false, tr("flag.strict.single"))
+			exportCmd.BoolVar(&markdownFrontMatter, "front-matter", false, tr("flag.front-matter"))
+			parseTokenFlags := registerTokenFlags(exportCmd)
+			parseDiffFlags := registerDiffFlags(exportCmd)
+			parseGitLinkFlags := registerGitLinkFlags(exportCmd, &config)
+			parseFlags(exportCmd, os.Args[3:])
+
+			var err error
+			config.Anonymizer, err = loadAnonymizer(*anonymizeRules)
+			if err == nil {
+				err = parseTokenFlags()
+			}
+			if err == nil {
+				err = parseDiffFlags()
+			}
+			if err == nil {
+				err = parseGitLinkFlags()
+			}
+			if err != nil {
+				printExportError(withCode(codeInvalidArg, err), config.JsonOutput, "")
+				return
+			}
 
 			// 获取数据库路径
-			if *dbPath == "" {
-				*dbPath = getDefaultDBPath()
-				if *dbPath == "" {
-					if *jsonOutput {
-						errMsg := "无法确定默认数据库路径"
-						response := ExportResponse{
-							Success:  false,
-							Exported: nil,
-							Total:    0,
-							Error:    &errMsg,
-						}
-						jsonData, _ := json.MarshalIndent(response, "", "  ")
-						fmt.Println(string(jsonData))
-						return
-					}
-					fmt.Println("无法确定默认数据库路径")
-					return
-				}
+			if !config.DBPaths.setDefault() {
+				printExportError(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))), config.JsonOutput, "")
+				return
 			}
 
-			if err := exportSingleSession(*dbPath, *outputDir, hash, *jsonOutput, *sortDesc, *byName); err != nil {
-				if *jsonOutput {
-					errMsg := err.Error()
-					response := ExportResponse{
-						Success:  false,
-						Exported: nil,
-						Total:    0,
-						Error:    &errMsg,
-					}
-					jsonData, _ := json.MarshalIndent(response, "", "  ")
-					fmt.Println(string(jsonData))
-					return
-				}
-				fmt.Printf("导出会话失败: %v\n", err)
+			if err := exportSingleSession(config, hash); err != nil {
+				printExportError(err, config.JsonOutput, tr("export.failed"))
 			}
 			return
 		}
@@ -825,129 +1041,103 @@ func main() {
 		// 原有的批量导出逻辑
 		var config Config
 		exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
-		exportCmd.StringVar(&config.DBPath, "db", "", "数据库文件路径 (默认: 系统默认路径)")
-		exportCmd.StringVar(&config.OutputDir, "out", "markdown_output", "markdown文件输出目录")
-		exportCmd.BoolVar(&config.JsonOutput, "json", false, "以JSON格式输出")
-		exportCmd.BoolVar(&config.SortDesc, "sort-desc", true, "按时间降序排序（从新到旧）")
-		exportCmd.BoolVar(&config.ByName, "byname", false, "在文件名前添加序号")
-		var startAfterStr, startBeforeStr, endAfterStr, endBeforeStr string
-		exportCmd.StringVar(&startAfterStr, "start-after", "", "仅包含在此时间之后开始的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)")
-		exportCmd.StringVar(&startBeforeStr, "start-before", "", "仅包含在此时间之前开始的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)")
-		exportCmd.StringVar(&endAfterStr, "end-after", "", "仅包含在此时间之后结束的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)")
-		exportCmd.StringVar(&endBeforeStr, "end-before", "", "仅包含在此时间之前结束的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)")
-
-		exportCmd.Parse(os.Args[2:])
-
-		var err error
-		if config.StartAfter, err = parseTimeArg(startAfterStr); err != nil {
-			if config.JsonOutput {
-				errMsg := fmt.Sprintf("解析start-after参数失败: %v", err)
-				response := ExportResponse{
-					Success:  false,
-					Exported: nil,
-					Total:    0,
-					Error:    &errMsg,
-				}
-				jsonData, _ := json.MarshalIndent(response, "", "  ")
-				fmt.Println(string(jsonData))
-				return
-			}
-			fmt.Printf("解析start-after参数失败: %v\n", err)
-			return
+		registerDBFlags(exportCmd, &config.DBPaths)
+		exportCmd.StringVar(&config.OutputDir, "out", "markdown_output", tr("flag.out"))
+		exportCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json"))
+		exportCmd.BoolVar(&config.SortDesc, "sort-desc", true, tr("flag.sort-desc.export"))
+		exportCmd.BoolVar(&config.ByName, "byname", false, tr("flag.byname"))
+		exportCmd.BoolVar(&config.Redact, "redact", false, tr("flag.redact"))
+		exportCmd.BoolVar(&config.Verbose, "verbose", false, tr("flag.verbose"))
+		exportCmd.BoolVar(&config.Strict, "strict", false, tr("flag.strict"))
+		exportCmd.BoolVar(&markdownFrontMatter, "front-matter", false, tr("flag.front-matter"))
+		anonymizeRules := exportCmd.String("anonymize", "", tr("flag.anonymize"))
+		parseTimeFilters := registerTimeFilterFlags(exportCmd, &config)
+		parseTokenFlags := registerTokenFlags(exportCmd)
+		parseDiffFlags := registerDiffFlags(exportCmd)
+		parseGitLinkFlags := registerGitLinkFlags(exportCmd, &config)
+
+		parseFlags(exportCmd, os.Args[2:])
+
+		err := parseTimeFilters()
+		if err == nil {
+			config.Anonymizer, err = loadAnonymizer(*anonymizeRules)
 		}
-		if config.StartBefore, err = parseTimeArg(startBeforeStr); err != nil {
-			if config.JsonOutput {
-				errMsg := fmt.Sprintf("解析start-before参数失败: %v", err)
-				response := ExportResponse{
-					Success:  false,
-					Exported: nil,
-					Total:    0,
-					Error:    &errMsg,
-				}
-				jsonData, _ := json.MarshalIndent(response, "", "  ")
-				fmt.Println(string(jsonData))
-				return
-			}
-			fmt.Printf("解析start-before参数失败: %v\n", err)
-			return
+		if err == nil {
+			err = parseTokenFlags()
 		}
-		if config.EndAfter, err = parseTimeArg(endAfterStr); err != nil {
-			if config.JsonOutput {
-				errMsg := fmt.Sprintf("解析end-after参数失败: %v", err)
-				response := ExportResponse{
-					Success:  false,
-					Exported: nil,
-					Total:    0,
-					Error:    &errMsg,
-				}
-				jsonData, _ := json.MarshalIndent(response, "", "  ")
-				fmt.Println(string(jsonData))
-				return
-			}
-			fmt.Printf("解析end-after参数失败: %v\n", err)
-			return
+		if err == nil {
+			err = parseDiffFlags()
 		}
-		if config.EndBefore, err = parseTimeArg(endBeforeStr); err != nil {
-			if config.JsonOutput {
-				errMsg := fmt.Sprintf("解析end-before参数失败: %v", err)
-				response := ExportResponse{
-					Success:  false,
-					Exported: nil,
-					Total:    0,
-					Error:    &errMsg,
-				}
-				jsonData, _ := json.MarshalIndent(response, "", "  ")
-				fmt.Println(string(jsonData))
-				return
-			}
-			fmt.Printf("解析end-before参数失败: %v\n", err)
+		if err == nil {
+			err = parseGitLinkFlags()
+		}
+		if err != nil {
+			printExportError(withCode(codeInvalidArg, err), config.JsonOutput, "")
 			return
 		}
 
-		config.HasTimeFilter = !config.StartAfter.IsZero() || !config.StartBefore.IsZero() ||
-			!config.EndAfter.IsZero() || !config.EndBefore.IsZero()
-
-		if config.DBPath == "" {
-			config.DBPath = getDefaultDBPath()
-			if config.DBPath == "" {
-				fmt.Println("无法确定默认数据库路径")
-				return
-			}
+		if !config.DBPaths.setDefault() {
+			printExportError(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))), config.JsonOutput, "")
+			return
 		}
 
 		if err := exportSessions(config); err != nil {
-			if config.JsonOutput {
-				errMsg := err.Error()
-				response := ExportResponse{
-					Success:  false,
-					Exported: nil,
-					Total:    0,
-					Error:    &errMsg,
-				}
-				jsonData, _ := json.MarshalIndent(response, "", "  ")
-				fmt.Println(string(jsonData))
-				return
-			}
-			fmt.Printf("导出会话失败: %v\n", err)
+			printExportError(err, config.JsonOutput, tr("export.failed"))
 		} else if !config.JsonOutput {
-			fmt.Println("导出完成!")
+			fmt.Println(tr("export.complete"))
 		}
 
+	case "show", "cat":
+		runShow(os.Args[2:])
+
+	case "browse":
+		runBrowse(os.Args[2:])
+
+	case "serve":
+		runServe(os.Args[2:])
+
+	case "mcp":
+		runMCP(os.Args[2:])
+
+	case "stats":
+		runStats(os.Args[2:])
+
+	case "extract-code":
+		runExtractCode(os.Args[2:])
+
+	case "git-link":
+		runGitLink(os.Args[2:])
+
+	case "file-history":
+		runFileHistory(os.Args[2:])
+
+	case "doctor":
+		runDoctor(os.Args[2:])
+
+	case "backup":
+		runBackup(os.Args[2:])
+
+	case "import":
+		runImport(os.Args[2:])
+
+	case "config":
+		runConfig(os.Args[2:])
+
 	case "version":
 		jsonOutput := false
 		versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
-		versionCmd.BoolVar(&jsonOutput, "json", false, "以JSON格式输出")
-		versionCmd.Parse(os.Args[2:])
+		versionCmd.BoolVar(&jsonOutput, "json", false, tr("flag.json"))
+		parseFlags(versionCmd, os.Args[2:])
 
 		if jsonOutput {
 			response := VersionResponse{
-				Version: "0.0.2",
+				Version: version,
 				Success: true,
 			}
 			jsonData, _ := json.MarshalIndent(response, "", "  ")
 			fmt.Println(string(jsonData))
 		} else {
-			fmt.Println("cursor2md version 0.0.2")
+			fmt.Println("cursor2md version " + version)
 		}
 
 	case "help":
@@ -955,17 +1145,10 @@ func main() {
 
 	default:
 		printHelp()
+		exitStatus = exitCodes[codeInvalidArg]
 	}
 }
 
 func printHelp() {
-	fmt.Println("使用说明:")
-	fmt.Println("  cursor2md ls [-db <数据库路径>] [-json]  列出所有会话信息")
-	fmt.Println("  cursor2md export [<hash>] [-db <数据库路径>] [-out <输出目录>] [-sort-desc] [-byname]  导出指定hash的会话")
-	fmt.Println("  cursor2md export [-db <数据库路径>] [-out <输出目录>] [-sort-desc] [-byname] [-start-after <时间>] [-start-before <时间>] [-end-after <时间>] [-end-before <时间>]  导出会话记录")
-	fmt.Println("  cursor2md version  显示版本信息")
-	fmt.Println("  cursor2md help  显示此帮助信息")
-	fmt.Println("\n排序参数说明:")
-	fmt.Println("                使用-sort-desc=false可改为升序排序（从旧到新）")
-	fmt.Println("  -byname      在文件名前添加序号（例如：001-文件名.md）")
+	fmt.Print(tr("help"))
 }
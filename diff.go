@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 行级差异的操作类型
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+// 单行差异，OldLine和NewLine为从1开始的行号，不存在时为0
+type diffOp struct {
+	Kind    diffKind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// 将文本拆分为行，忽略末尾的换行符
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// 使用Myers算法计算两组行之间的最短编辑序列
+// 采用线性空间的变体：找到最短路径中间的蛇形段后递归处理两侧，内存与行数成正比
+func diffLines(a, b []string) []diffOp {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+	d := &differ{a: a, b: b}
+	d.compare(0, len(a), 0, len(b))
+	return groupChanges(d.ops)
+}
+
+// 计算差异的状态，ops按顺序收集编辑序列
+type differ struct {
+	a, b []string
+	ops  []diffOp
+}
+
+func (d *differ) equal(x, y int) {
+	d.ops = append(d.ops, diffOp{Kind: diffEqual, Text: d.a[x], OldLine: x + 1, NewLine: y + 1})
+}
+
+// 计算a[a0:a1]和b[b0:b1]之间的编辑序列
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.equal(a0, b0)
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && d.a[a1-suffix-1] == d.b[b1-suffix-1] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		for y := b0; y < b1; y++ {
+			d.ops = append(d.ops, diffOp{Kind: diffInsert, Text: d.b[y], NewLine: y + 1})
+		}
+	case b0 == b1:
+		for x := a0; x < a1; x++ {
+			d.ops = append(d.ops, diffOp{Kind: diffDelete, Text: d.a[x], OldLine: x + 1})
+		}
+	default:
+		// 去掉相同的首尾后两侧都不为空，编辑距离至少为2，两侧的子问题都更小
+		x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+		d.compare(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.equal(x, y)
+		}
+		d.compare(u, a1, v, b1)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.equal(a1+i, b1+i)
+	}
+}
+
+// 同时从两端搜索，返回最短编辑路径中间的蛇形段的起点(x, y)和终点(u, v)
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	// vf[k]为前向搜索在对角线k上到达的最远x，vb为从末尾反向搜索的对应值
+	vf := make([]int, 2*maxD+3)
+	vb := make([]int, 2*maxD+3)
+
+	for step := 0; step <= maxD; step++ {
+		for k := -step; k <= step; k += 2 {
+			var px int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				px = vf[offset+k+1]
+			} else {
+				px = vf[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && d.a[a0+px] == d.b[b0+py] {
+				px++
+				py++
+			}
+			vf[offset+k] = px
+			// 反向对角线为delta-k，奇数时与上一轮的反向搜索比较
+			if c := delta - k; odd && c >= -(step-1) && c <= step-1 && px+vb[offset+c] >= n {
+				return a0 + sx, b0 + sy, a0 + px, b0 + py
+			}
+		}
+		for k := -step; k <= step; k += 2 {
+			var px int
+			if k == -step || (k != step && vb[offset+k-1] < vb[offset+k+1]) {
+				px = vb[offset+k+1]
+			} else {
+				px = vb[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && d.a[a1-px-1] == d.b[b1-py-1] {
+				px++
+				py++
+			}
+			vb[offset+k] = px
+			if c := delta - k; !odd && c >= -step && c <= step && px+vf[offset+c] >= n {
+				return a1 - px, b1 - py, a1 - sx, b1 - sy
+			}
+		}
+	}
+	// 不会到达：编辑距离不超过n+m
+	return a0, b0, a0, b0
+}
+
+// 在每段连续的修改中将删除行排在插入行之前，与diff -u的输出一致
+func groupChanges(ops []diffOp) []diffOp {
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == diffEqual {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].Kind != diffEqual {
+			j++
+		}
+		sort.SliceStable(ops[i:j], func(p, q int) bool {
+			return ops[i+p].Kind == diffDelete && ops[i+q].Kind == diffInsert
+		})
+		i = j
+	}
+	return ops
+}
+
+// 差异中的一段连续修改及其上下文
+type diffHunk struct {
+	ops    []diffOp
+	oldPos int // 段之前旧文件的行数
+	newPos int // 段之前新文件的行数
+}
+
+// 将编辑序列按上下文行数分组，返回包含修改的段
+func groupHunks(ops []diffOp, context int) []diffHunk {
+	var hunks []diffHunk
+	start, end := -1, -1
+	flush := func() {
+		h := diffHunk{ops: ops[start:end]}
+		for _, op := range ops[:start] {
+			if op.Kind != diffInsert {
+				h.oldPos++
+			}
+			if op.Kind != diffDelete {
+				h.newPos++
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	for i, op := range ops {
+		if op.Kind == diffEqual {
+			continue
+		}
+		from, to := i-context, i+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ops) {
+			to = len(ops)
+		}
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		if start >= 0 {
+			flush()
+		}
+		start, end = from, to
+	}
+	if start >= 0 {
+		flush()
+	}
+	return hunks
+}
+
+// 计算段在旧文件和新文件中的起始行和行数，用于@@头
+// 某一侧没有行时起始行为前一行，与diff -u一致
+func (h diffHunk) ranges() (oldStart, oldCount, newStart, newCount int) {
+	for _, op := range h.ops {
+		if op.Kind != diffInsert {
+			oldCount++
+		}
+		if op.Kind != diffDelete {
+			newCount++
+		}
+	}
+	oldStart, newStart = h.oldPos, h.newPos
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	return
+}
+
+// 格式化@@头中的行范围，只有一行时省略行数
+func formatHunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// 生成统一格式的差异，两个文本相同时返回空字符串
+func unifiedDiff(oldName, newName, oldText, newText string, context int) string {
+	hunks := groupHunks(diffLines(splitLines(oldText), splitLines(newText)), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		oldStart, oldCount, newStart, newCount := h.ranges()
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", formatHunkRange(oldStart, oldCount), formatHunkRange(newStart, newCount))
+		for _, op := range h.ops {
+			switch op.Kind {
+			case diffEqual:
+				sb.WriteString(" ")
+			case diffDelete:
+				sb.WriteString("-")
+			case diffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.Text)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// 并排显示的一行，Old或New为nil表示该侧没有内容
+type sideBySideRow struct {
+	Old *diffOp
+	New *diffOp
+	Gap bool // 省略的相同行
+}
+
+// 将差异转换为并排显示的行：相邻的删除和插入配对显示，段之间插入省略行
+func sideBySideRows(oldText, newText string, context int) []sideBySideRow {
+	var rows []sideBySideRow
+	for i, h := range groupHunks(diffLines(splitLines(oldText), splitLines(newText)), context) {
+		if i > 0 || h.oldPos > 0 || h.newPos > 0 {
+			rows = append(rows, sideBySideRow{Gap: true})
+		}
+		ops := h.ops
+		for j := 0; j < len(ops); {
+			if ops[j].Kind == diffEqual {
+				rows = append(rows, sideBySideRow{Old: &ops[j], New: &ops[j]})
+				j++
+				continue
+			}
+			var dels, ins []*diffOp
+			for ; j < len(ops) && ops[j].Kind == diffDelete; j++ {
+				dels = append(dels, &ops[j])
+			}
+			for ; j < len(ops) && ops[j].Kind == diffInsert; j++ {
+				ins = append(ins, &ops[j])
+			}
+			for k := 0; k < len(dels) || k < len(ins); k++ {
+				var row sideBySideRow
+				if k < len(dels) {
+					row.Old = dels[k]
+				}
+				if k < len(ins) {
+					row.New = ins[k]
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// 代码块差异的设置
+type codeDiffOptions struct {
+	Mode    string // 为空时不生成差异；replace: 只显示差异；both: 同时显示代码块和差异
+	Rev     string // 与指定的git版本比较，为空时与磁盘上的当前文件比较
+	Context int    // 差异的上下文行数
+}
+
+// 默认不生成差异；命令行参数会替换它，Markdown和HTML输出使用这里的设置
+var defaultCodeDiffOptions = codeDiffOptions{Context: 3}
+
+// 代码块对应的原始文件内容，按git版本和路径缓存，serve并发处理请求时需要加锁
+var codeDiffBaseCache = struct {
+	sync.Mutex
+	m map[string]*string
+}{m: make(map[string]*string)}
+
+// 查找路径所在的git仓库根目录，路径的目录不存在时向上查找
+func gitTopLevel(path string) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New(tr("diff.noDir", filepath.Dir(path)))
+		}
+		dir = parent
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", errors.New(tr("diff.notInRepo", path))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// 读取代码块对应文件的原始内容：指定了git版本时从仓库读取，否则读取磁盘上的文件
+// 文件不存在时返回false
+func loadCodeDiffBase(path string, rev string) (string, bool) {
+	key := rev + "\x00" + path
+	codeDiffBaseCache.Lock()
+	cached, ok := codeDiffBaseCache.m[key]
+	codeDiffBaseCache.Unlock()
+	if ok {
+		if cached == nil {
+			return "", false
+		}
+		return *cached, true
+	}
+
+	var content *string
+	if rev == "" {
+		if data, err := os.ReadFile(path); err == nil {
+			s := string(data)
+			content = &s
+		}
+	} else if top, err := gitTopLevel(path); err == nil {
+		if rel, err := filepath.Rel(top, path); err == nil && !strings.HasPrefix(rel, "..") {
+			out, err := exec.Command("git", "-C", top, "show", rev+":"+filepath.ToSlash(rel)).Output()
+			if err == nil {
+				s := string(out)
+				content = &s
+			}
+		}
+	}
+	codeDiffBaseCache.Lock()
+	codeDiffBaseCache.m[key] = content
+	codeDiffBaseCache.Unlock()
+	if content == nil {
+		return "", false
+	}
+	return *content, true
+}
+
+// 生成代码块与原始文件之间的统一差异
+// 没有启用差异、代码块没有路径或原始文件不存在时返回false
+func codeBlockDiff(path string, content string) (string, bool) {
+	opts := defaultCodeDiffOptions
+	if opts.Mode == "" || path == "" {
+		return "", false
+	}
+	base, ok := loadCodeDiffBase(path, opts.Rev)
+	if !ok {
+		return "", false
+	}
+	oldName := "a/" + strings.TrimPrefix(filepath.ToSlash(path), "/")
+	if opts.Rev != "" {
+		oldName += "@" + opts.Rev
+	}
+	newName := "b/" + strings.TrimPrefix(filepath.ToSlash(path), "/")
+	return unifiedDiff(oldName, newName, base, content, opts.Context), true
+}
+
+// 注册代码块差异相关的命令行参数，返回的函数在解析参数后调用，用于替换默认设置
+func registerDiffFlags(fs *flag.FlagSet) func() error {
+	mode := fs.String("diff", "", tr("flag.diff"))
+	rev := fs.String("diff-rev", "", tr("flag.diff-rev"))
+	context := fs.Int("diff-context", 3, tr("flag.diff-context"))
+	return func() error {
+		switch *mode {
+		case "", "replace", "both":
+		default:
+			return errors.New(tr("diff.mode", *mode))
+		}
+		if *rev != "" && *mode == "" {
+			*mode = "replace"
+		}
+		if *context < 0 {
+			return errors.New(tr("diff.context"))
+		}
+		defaultCodeDiffOptions = codeDiffOptions{Mode: *mode, Rev: *rev, Context: *context}
+		return nil
+	}
+}
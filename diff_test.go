@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成testdata中的golden文件")
+
+// testdata/diff中的每组<名称>.old和<名称>.new与<名称>.golden中的统一差异比较
+func TestUnifiedDiffGolden(t *testing.T) {
+	olds, err := filepath.Glob(filepath.Join("testdata", "diff", "*.old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(olds) == 0 {
+		t.Fatal("no golden cases in testdata/diff")
+	}
+	for _, oldPath := range olds {
+		base := strings.TrimSuffix(oldPath, ".old")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			oldText, err := os.ReadFile(oldPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newText, err := os.ReadFile(base + ".new")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := unifiedDiff("a/file", "b/file", string(oldText), string(newText), 3)
+			if *update {
+				if err := os.WriteFile(base+".golden", []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(base + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("diff mismatch\n--- got ---\n%s--- want ---\n%s", got, want)
+			}
+		})
+	}
+}
+
+// 通过动态规划计算最长公共子序列的长度，用于检查编辑序列是否最短
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		// 编辑序列必须能从a还原出b，行号与位置一致
+		var oldLines, newLines []string
+		equal := 0
+		for _, op := range ops {
+			if op.Kind != diffInsert {
+				if op.OldLine != len(oldLines)+1 || a[op.OldLine-1] != op.Text {
+					t.Fatalf("case %d: bad old line %+v", i, op)
+				}
+				oldLines = append(oldLines, op.Text)
+			}
+			if op.Kind != diffDelete {
+				if op.NewLine != len(newLines)+1 || b[op.NewLine-1] != op.Text {
+					t.Fatalf("case %d: bad new line %+v", i, op)
+				}
+				newLines = append(newLines, op.Text)
+			}
+			if op.Kind == diffEqual {
+				equal++
+			}
+		}
+		if len(oldLines) != len(a) || len(newLines) != len(b) {
+			t.Fatalf("case %d: ops do not cover both inputs", i)
+		}
+		if want := lcsLength(a, b); equal != want {
+			t.Fatalf("case %d: %d equal lines, want %d (a=%v b=%v)", i, equal, want, a, b)
+		}
+	}
+}
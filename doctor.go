@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 导出时写入文件失败的跳过原因
+const skipWriteError = "write-error"
+
+type DoctorResponse struct {
+	DBPath    string              `json:"dbPath"`
+	Archive   bool                `json:"archive,omitempty"` // 检查的是backup创建的归档
+	Diagnosis *store.Diagnosis    `json:"diagnosis,omitempty"`
+	Formats   *store.FormatReport `json:"formats,omitempty"`
+	Sessions  int                 `json:"sessions"`
+	Skipped   []store.Skipped     `json:"skipped"`
+	Success   bool                `json:"success"`
+	Error     *string             `json:"error,omitempty"`
+	Code      string              `json:"code,omitempty"`
+}
+
+// 输出数据库完整性、表和键前缀的检查结果
+func printDiagnosis(d *store.Diagnosis) {
+	if d.IntegrityOK() {
+		fmt.Println(tr("doctor.integrity", "ok"))
+	} else {
+		fmt.Println(tr("doctor.integrity", tr("doctor.integrityFailed")))
+		for _, line := range d.Integrity {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	fmt.Println("\n" + tr("doctor.tables"))
+	for _, t := range d.Tables {
+		if t.Exists {
+			fmt.Printf("  %-14s %s\n", t.Name, tr("doctor.rows", t.Rows))
+		} else {
+			fmt.Printf("  %-14s %s\n", t.Name, tr("doctor.missing"))
+		}
+	}
+
+	if len(d.Prefixes) > 0 {
+		fmt.Println("\n" + tr("doctor.prefixes"))
+		for _, p := range d.Prefixes {
+			fmt.Printf("  %-14s %-40s %d\n", p.Table, p.Prefix, p.Rows)
+		}
+	}
+}
+
+// 输出存储格式的检测结果
+func printFormatReport(report *store.FormatReport) {
+	fmt.Println(tr("doctor.formats"))
+	found := false
+	for _, f := range report.Formats {
+		line := fmt.Sprintf("  %-18s %s", f.Name, tr("doctor.formatSessions", f.Sessions))
+		if f.Invalid > 0 {
+			line += tr("doctor.formatInvalid", f.Invalid)
+		}
+		if len(f.Versions) > 0 {
+			versions := make([]string, len(f.Versions))
+			for i, v := range f.Versions {
+				versions[i] = fmt.Sprintf("%d", v)
+			}
+			line += fmt.Sprintf(" (_v: %s)", strings.Join(versions, ", "))
+		}
+		fmt.Println(line)
+		fmt.Printf("  %-18s %s\n", "", formatDescription(f))
+		if f.Sessions > 0 {
+			found = true
+		}
+	}
+	fmt.Println(tr("doctor.unknown", report.Unknown))
+	fmt.Println(tr("doctor.bubbleKeys", report.BubbleKeys))
+	if report.LegacyChat {
+		fmt.Println(tr("doctor.legacyChat", tr("doctor.yes")))
+	} else {
+		fmt.Println(tr("doctor.legacyChat", tr("doctor.no")))
+	}
+	if !found {
+		fmt.Println("\n" + tr("doctor.noFormats"))
+	}
+}
+
+// 内置适配器的说明在消息目录中，其他适配器使用注册时的说明
+func formatDescription(f store.FormatCount) string {
+	key := "format." + f.Name
+	if _, ok := catalogs[defaultLang][key]; ok {
+		return tr(key)
+	}
+	return f.Description
+}
+
+// 检查cursorDiskKV是否存在，不存在时无法读取会话
+func hasSessionTable(d *store.Diagnosis) bool {
+	for _, t := range d.Tables {
+		if t.Name == "cursorDiskKV" {
+			return t.Exists
+		}
+	}
+	return false
+}
+
+// doctor命令入口
+func runDoctor(args []string) {
+	doctorCmd := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dbPath := doctorCmd.String("db", "", tr("flag.db.doctor"))
+	archivePath := doctorCmd.String("archive", "", tr("flag.archive.doctor"))
+	jsonOutput := doctorCmd.Bool("json", false, tr("flag.json"))
+	verbose := doctorCmd.Bool("verbose", false, tr("flag.verbose"))
+	parseFlags(doctorCmd, args)
+
+	fail := func(err error) {
+		code := failed(err)
+		if *jsonOutput {
+			errMsg := err.Error()
+			jsonData, _ := json.MarshalIndent(DoctorResponse{DBPath: *dbPath, Success: false, Error: &errMsg, Code: code}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("doctor.failed", err))
+	}
+
+	archive := *archivePath != ""
+	if archive {
+		if *dbPath != "" {
+			fail(withCode(codeInvalidArg, errors.New(tr("doctor.dbAndArchive"))))
+			return
+		}
+		// 文件不存在时由打开数据库时报告
+		if ok, err := store.IsArchive(*archivePath); err == nil && !ok {
+			fail(withCode(codeInvalidArg, errors.New(tr("err.notArchive", *archivePath))))
+			return
+		}
+		*dbPath = *archivePath
+	}
+	if *dbPath == "" {
+		*dbPath = getDefaultDBPath()
+		if *dbPath == "" {
+			fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+			return
+		}
+	}
+	db, err := store.Open(*dbPath, store.Options{ReadOnly: true})
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	diagnosis, err := db.Diagnose(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	response := DoctorResponse{DBPath: *dbPath, Archive: archive, Diagnosis: diagnosis, Skipped: []store.Skipped{}, Success: true}
+
+	// 缺少cursorDiskKV时只输出完整性检查的结果
+	if hasSessionTable(diagnosis) {
+		if response.Formats, err = db.DetectFormats(ctx); err != nil {
+			fail(err)
+			return
+		}
+		onSkip := func(s store.Skipped) { response.Skipped = append(response.Skipped, s) }
+		for _, err := range db.Scan(ctx, store.Filter{}, onSkip) {
+			if err != nil {
+				fail(err)
+				return
+			}
+			response.Sessions++
+		}
+	}
+
+	// 数据库损坏或有无法读取的会话时以非零状态退出，报告照常输出
+	problem := doctorProblem(diagnosis, response.Skipped)
+	if problem != nil {
+		errMsg := problem.Error()
+		response.Success = false
+		response.Error = &errMsg
+		response.Code = failed(problem)
+	}
+
+	if *jsonOutput {
+		jsonData, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			fail(errors.New(tr("err.json", err)))
+			return
+		}
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	if archive {
+		fmt.Printf("%s\n\n", tr("backup.archive", *dbPath))
+	} else {
+		fmt.Printf("%s\n\n", tr("doctor.db", *dbPath))
+	}
+	printDoctorReport(response, *verbose)
+	if problem != nil {
+		fmt.Println("\n" + tr("doctor.failed", problem))
+	}
+}
+
+// 输出doctor的文本报告
+func printDoctorReport(response DoctorResponse, verbose bool) {
+	printDiagnosis(response.Diagnosis)
+	if response.Formats == nil {
+		fmt.Println("\n" + tr("doctor.noSessionTable"))
+		return
+	}
+	fmt.Println()
+	printFormatReport(response.Formats)
+	fmt.Printf("\n%s\n", tr("doctor.sessions", response.Sessions))
+	if len(response.Skipped) == 0 {
+		fmt.Println(tr("doctor.noSkipped"))
+		return
+	}
+	printSkipped(response.Skipped, verbose)
+	if !verbose {
+		fmt.Println(tr("doctor.verbose"))
+	}
+}
+
+// 完整性检查失败或有会话无法读取时返回错误
+// 与export一致，空会话和无法关联到会话的修改记录不算失败
+func doctorProblem(diagnosis *store.Diagnosis, skipped []store.Skipped) error {
+	if !diagnosis.IntegrityOK() {
+		return withCode(codeParseError, errors.New(tr("doctor.corrupt")))
+	}
+	if failures, code := failedSessions(skipped); len(failures) > 0 {
+		return withCode(code, errors.New(tr("doctor.unreadable", len(failures))))
+	}
+	return nil
+}
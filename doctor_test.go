@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 执行doctor -json，返回解析后的响应和退出状态
+func runDoctorJSON(t *testing.T, path string) (DoctorResponse, int) {
+	t.Helper()
+	isolateConfig(t)
+	saved := exitStatus
+	exitStatus = 0
+	defer func() { exitStatus = saved }()
+	out := captureStdout(t, func() { runDoctor([]string{"-db", path, "-json"}) })
+	var response DoctorResponse
+	if err := json.Unmarshal([]byte(out), &response); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	return response, exitStatus
+}
+
+func TestDoctorJSON(t *testing.T) {
+	response, status := runDoctorJSON(t, newFixtureDB(t))
+	if !response.Success || status != 0 || response.Code != "" || response.Sessions != 3 {
+		t.Errorf("healthy database: success %v, status %d, code %q, %d sessions; want true, 0, none, 3",
+			response.Success, status, response.Code, response.Sessions)
+	}
+	if response.Skipped == nil || len(response.Skipped) != 0 {
+		t.Errorf("skipped = %#v, want an empty array", response.Skipped)
+	}
+
+	// 空会话不算失败，无效的JSON算失败
+	path := writeFixtureDB(t, map[string]string{
+		fixtureLogin:  fixtureComposer(fixtureLogin, "Fix login bug", 1704099600000, "hello"),
+		fixtureParser: `{"composerId":"` + fixtureParser + `","conversation":[]}`,
+	})
+	response, status = runDoctorJSON(t, path)
+	if !response.Success || status != 0 || len(response.Skipped) != 1 || response.Skipped[0].Reason != store.SkipNoMessages {
+		t.Errorf("empty session: success %v, status %d, skipped %+v; want success with one no-messages record", response.Success, status, response.Skipped)
+	}
+
+	path = writeFixtureDB(t, map[string]string{
+		fixtureLogin:  fixtureComposer(fixtureLogin, "Fix login bug", 1704099600000, "hello"),
+		fixtureParser: "{not json",
+	})
+	response, status = runDoctorJSON(t, path)
+	if response.Success || status != exitCodes[codeParseError] || response.Code != codeParseError || response.Error == nil {
+		t.Errorf("invalid JSON: success %v, status %d, code %q; want failure with parse-error", response.Success, status, response.Code)
+	}
+	if len(response.Skipped) != 1 || response.Skipped[0].Reason != store.SkipInvalidJSON || response.Sessions != 1 || response.Diagnosis == nil {
+		t.Errorf("invalid JSON: skipped %+v, %d sessions; want the full report with one invalid-json record", response.Skipped, response.Sessions)
+	}
+}
+
+func TestDoctorProblem(t *testing.T) {
+	ok := &store.Diagnosis{Integrity: []string{"ok"}}
+	corrupt := &store.Diagnosis{Integrity: []string{"*** in database main ***", "row 1 missing from index kv_value"}}
+	tests := []struct {
+		diagnosis *store.Diagnosis
+		skipped   []store.Skipped
+		want      string
+	}{
+		{ok, nil, ""},
+		{ok, []store.Skipped{{Reason: store.SkipNoMessages}, {Reason: store.SkipNoComposer}}, ""},
+		{ok, []store.Skipped{{Reason: store.SkipConvertError}}, codeParseError},
+		{corrupt, nil, codeParseError},
+	}
+	for _, tt := range tests {
+		err := doctorProblem(tt.diagnosis, tt.skipped)
+		if err == nil {
+			if tt.want != "" {
+				t.Errorf("doctorProblem(%v, %v) = nil, want %s", tt.diagnosis.Integrity, tt.skipped, tt.want)
+			}
+			continue
+		}
+		if got := errorCode(err); got != tt.want {
+			t.Errorf("doctorProblem(%v, %v) = %v (%s), want %q", tt.diagnosis.Integrity, tt.skipped, err, got, tt.want)
+		}
+	}
+}
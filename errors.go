@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 错误类型，对应JSON输出中的code字段
+const (
+	codeInternal   = "internal"     // 其他错误
+	codeInvalidArg = "invalid-arg"  // 命令行参数或参数指定的文件无效
+	codeDBNotFound = "db-not-found" // 数据库文件不存在或无法确定默认路径
+	codeDBLocked   = "db-locked"    // 数据库被其他进程锁定
+	codeParseError = "parse-error"  // 会话数据无法解析
+	codeNotFound   = "not-found"    // 没有找到指定的会话
+	codeWriteError = "write-error"  // 写入输出文件失败
+)
+
+// 各类错误的退出状态，invalid-arg与flag包解析参数失败时的退出状态一致
+var exitCodes = map[string]int{
+	codeInternal:   1,
+	codeInvalidArg: 2,
+	codeDBNotFound: 3,
+	codeDBLocked:   4,
+	codeParseError: 5,
+	codeNotFound:   6,
+	codeWriteError: 7,
+}
+
+// 带有错误类型的错误
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// 为错误指定类型，err为nil时返回nil
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// 判断错误的类型，没有指定类型时根据store返回的错误推断
+func errorCode(err error) string {
+	var ce *codedError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, store.ErrDBNotFound):
+		return codeDBNotFound
+	case errors.Is(err, store.ErrNotFound):
+		return codeNotFound
+	case errors.Is(err, store.ErrInvalidSession):
+		return codeParseError
+	case errors.Is(err, store.ErrLocked), isLockedError(err):
+		return codeDBLocked
+	}
+	return codeInternal
+}
+
+// store用%v包装了sqlite的错误，只能通过错误信息判断数据库是否被锁定
+func isLockedError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// 程序的退出状态，为第一个失败的错误对应的状态
+var exitStatus int
+
+// 记录失败的错误，返回错误类型用于JSON输出
+func failed(err error) string {
+	code := errorCode(err)
+	if exitStatus == 0 {
+		exitStatus = exitCodes[code]
+	}
+	return code
+}
+
+// 导出失败的会话，只有写入失败和以下解析失败的原因算失败
+// 空会话、修改记录的问题和合并时没有选中的版本不算失败
+func failedSessions(skipped []store.Skipped) (failures []store.Skipped, code string) {
+	for _, s := range skipped {
+		switch s.Reason {
+		case skipWriteError:
+			code = codeWriteError
+		case store.SkipScanError, store.SkipInvalidJSON, store.SkipUnknownFormat, store.SkipConvertError, store.SkipLoadError:
+			if code == "" {
+				code = codeParseError
+			}
+		default:
+			continue
+		}
+		failures = append(failures, s)
+	}
+	return failures, code
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// 合并两个数据库，其中一个数据库中的会话版本无法解析
+func TestExportSingleStrict(t *testing.T) {
+	isolateConfig(t)
+	broken := filepath.Join(t.TempDir(), "state.vscdb")
+	db, err := sql.Open("sqlite3", broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)",
+		"CREATE TABLE cursorDiskKV (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)",
+		"INSERT INTO cursorDiskKV (key, value) VALUES ('composerData:" + fixtureLogin + "', '{not json')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	for _, strict := range []bool{false, true} {
+		config := Config{
+			DBPaths:   dbPaths{newFixtureDB(t), broken},
+			OutputDir: t.TempDir(),
+			Strict:    strict,
+		}
+		var err error
+		out := captureStdout(t, func() { err = exportSingleSession(config, fixtureLogin) })
+		if !strict {
+			if err != nil {
+				t.Fatalf("without -strict: %v", err)
+			}
+			continue
+		}
+		if err == nil || errorCode(err) != codeParseError {
+			t.Fatalf("with -strict: got %v, want parse-error\n%s", err, out)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(config.OutputDir, "*.md")); len(matches) != 1 {
+			t.Errorf("with -strict: exported %d files, want 1", len(matches))
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 代码块语言对应的文件扩展名，用于没有路径的代码片段和历史版本文件
+var languageExtensions = map[string]string{
+	"go":              ".go",
+	"python":          ".py",
+	"javascript":      ".js",
+	"javascriptreact": ".jsx",
+	"typescript":      ".ts",
+	"typescriptreact": ".tsx",
+	"rust":            ".rs",
+	"java":            ".java",
+	"kotlin":          ".kt",
+	"swift":           ".swift",
+	"c":               ".c",
+	"cpp":             ".cpp",
+	"csharp":          ".cs",
+	"ruby":            ".rb",
+	"php":             ".php",
+	"shell":           ".sh",
+	"shellscript":     ".sh",
+	"bash":            ".sh",
+	"sh":              ".sh",
+	"powershell":      ".ps1",
+	"json":            ".json",
+	"yaml":            ".yaml",
+	"toml":            ".toml",
+	"xml":             ".xml",
+	"html":            ".html",
+	"css":             ".css",
+	"scss":            ".scss",
+	"sql":             ".sql",
+	"markdown":        ".md",
+	"dockerfile":      ".dockerfile",
+	"makefile":        ".mk",
+}
+
+// 清单中的单个代码块
+type ExtractedBlock struct {
+	Index        int       `json:"index"`        // 在会话中的序号，从1开始
+	MessageIndex int       `json:"messageIndex"` // 所在消息的序号，从1开始
+	Time         time.Time `json:"time"`
+	Language     string    `json:"language,omitempty"`
+	SourcePath   string    `json:"sourcePath,omitempty"` // 代码块原始的文件路径
+	OutputPath   string    `json:"outputPath"`           // 相对于输出目录的路径
+	Version      int       `json:"version"`              // 同一文件的第几个版本，从1开始
+	Lines        int       `json:"lines"`
+	SHA256       string    `json:"sha256"`
+}
+
+// 清单中的单个文件，OutputPath为最后一个版本
+type ExtractedFile struct {
+	SourcePath string   `json:"sourcePath"`
+	OutputPath string   `json:"outputPath"`
+	Versions   []string `json:"versions,omitempty"` // 按时间顺序的所有版本，只有多个版本时输出
+}
+
+// 写入输出目录的manifest.json
+type ExtractManifest struct {
+	Hash      string           `json:"hash"`
+	Title     string           `json:"title"`
+	Workspace string           `json:"workspace,omitempty"`
+	Blocks    []ExtractedBlock `json:"blocks"`
+	Files     []ExtractedFile  `json:"files"`
+	Snippets  int              `json:"snippets"` // 没有路径的代码片段数量
+}
+
+type ExtractResponse struct {
+	Success      bool             `json:"success"`
+	OutputDir    string           `json:"outputDir,omitempty"`
+	ManifestPath string           `json:"manifestPath,omitempty"`
+	Manifest     *ExtractManifest `json:"manifest,omitempty"`
+	Error        *string          `json:"error,omitempty"`
+	Code         string           `json:"code,omitempty"`
+}
+
+// 代码块语言对应的扩展名，未知语言使用.txt
+func languageExtension(language string) string {
+	if ext, ok := languageExtensions[strings.ToLower(language)]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// 将代码块路径转换为输出目录下的相对路径：去掉工作区前缀、盘符和开头的斜杠，丢弃..等不安全的部分
+func extractRelativePath(sourcePath string, workspace string) string {
+	p := path.Clean(toSlashPath(sourcePath))
+	if ws := strings.TrimRight(toSlashPath(workspace), "/"); ws != "" && ws != "." && strings.HasPrefix(p, ws+"/") {
+		p = strings.TrimPrefix(p, ws+"/")
+	}
+	if len(p) >= 2 && p[1] == ':' {
+		p = p[2:]
+	}
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		parts = append(parts, sanitizeFileName(part))
+	}
+	return path.Join(parts...)
+}
+
+// 输出目录中的子目录，代码块的路径只出现在files下，不会与清单、代码片段或历史版本冲突
+const (
+	extractFilesDir    = "files"
+	extractHistoryDir  = "history"
+	extractSnippetsDir = "snippets"
+	extractManifest    = "manifest.json"
+)
+
+// 历史版本的文件名，例如 src/login.go 的第2个版本为 history/src/login.v2.go
+func historyPath(rel string, version int) string {
+	ext := path.Ext(rel)
+	return path.Join(extractHistoryDir, fmt.Sprintf("%s.v%d%s", strings.TrimSuffix(rel, ext), version, ext))
+}
+
+// 统计文本行数
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// 将会话中AI回复的代码块写入输出目录，返回清单
+func extractCode(session SessionInfo, record ChatRecord, outputDir string) (*ExtractManifest, error) {
+	workspace := store.Workspace(record)
+	manifest := &ExtractManifest{
+		Hash:      session.Hash,
+		Title:     record.Name,
+		Workspace: workspace,
+		Blocks:    []ExtractedBlock{},
+		Files:     []ExtractedFile{},
+	}
+
+	// 先收集所有代码块，确定每个文件的版本数量后再写入
+	type pendingBlock struct {
+		block   ExtractedBlock
+		rel     string
+		content string
+	}
+	var pending []pendingBlock
+	versions := make(map[string]int)
+	var fileOrder []string
+	for i, msg := range record.Conversation {
+		if msg.Type != 2 {
+			continue
+		}
+		for _, block := range msg.CodeBlocks {
+			if block.Content == "" {
+				continue
+			}
+			sum := sha256.Sum256([]byte(block.Content))
+			b := ExtractedBlock{
+				Index:        len(pending) + 1,
+				MessageIndex: i + 1,
+				Time:         messageTime(msg, session),
+				Language:     block.LanguageId,
+				SourcePath:   block.Uri.Path,
+				Lines:        countLines(block.Content),
+				SHA256:       hex.EncodeToString(sum[:]),
+			}
+			rel := ""
+			if block.Uri.Path != "" {
+				rel = extractRelativePath(block.Uri.Path, workspace)
+			}
+			if rel == "" {
+				manifest.Snippets++
+				b.SourcePath = ""
+				b.OutputPath = path.Join(extractSnippetsDir, fmt.Sprintf("snippet-%03d%s", manifest.Snippets, languageExtension(block.LanguageId)))
+			} else {
+				if versions[rel] == 0 {
+					fileOrder = append(fileOrder, rel)
+				}
+				versions[rel]++
+				b.Version = versions[rel]
+			}
+			pending = append(pending, pendingBlock{block: b, rel: rel, content: block.Content})
+		}
+	}
+
+	write := func(rel string, content string) error {
+		target := filepath.Join(outputDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return withCode(codeWriteError, errors.New(tr("err.mkdir", err)))
+		}
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return withCode(codeWriteError, errors.New(tr("err.writeFile", err)))
+		}
+		return nil
+	}
+
+	files := make(map[string]*ExtractedFile)
+	for _, p := range pending {
+		b := p.block
+		filePath := path.Join(extractFilesDir, p.rel)
+		switch {
+		case b.Version == 0:
+			b.Version = 1
+		case versions[p.rel] == 1:
+			b.OutputPath = filePath
+		default:
+			// 同一文件有多个版本：每个版本写入history，最后一个版本同时写入files下的路径
+			b.OutputPath = historyPath(p.rel, b.Version)
+			if b.Version == versions[p.rel] {
+				if err := write(filePath, p.content); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := write(b.OutputPath, p.content); err != nil {
+			return nil, err
+		}
+		manifest.Blocks = append(manifest.Blocks, b)
+
+		if b.SourcePath != "" {
+			f, ok := files[p.rel]
+			if !ok {
+				f = &ExtractedFile{SourcePath: b.SourcePath, OutputPath: filePath}
+				files[p.rel] = f
+			}
+			if versions[p.rel] > 1 {
+				f.Versions = append(f.Versions, b.OutputPath)
+			}
+		}
+	}
+	for _, rel := range fileOrder {
+		manifest.Files = append(manifest.Files, *files[rel])
+	}
+
+	jsonData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.New(tr("err.json", err))
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, extractManifest), append(jsonData, '\n'), 0644); err != nil {
+		return nil, withCode(codeWriteError, errors.New(tr("extract.manifestFailed", err)))
+	}
+	return manifest, nil
+}
+
+// extract-code命令入口
+func runExtractCode(args []string) {
+	extractCmd := flag.NewFlagSet("extract-code", flag.ExitOnError)
+	var dbPath dbPaths
+	registerDBFlags(extractCmd, &dbPath)
+	outputDir := extractCmd.String("out", "code_output", tr("flag.out.extract-code"))
+	jsonOutput := extractCmd.Bool("json", false, tr("flag.json"))
+	redact := extractCmd.Bool("redact", false, tr("flag.redact.extract-code"))
+	anonymizeRules := extractCmd.String("anonymize", "", tr("flag.anonymize"))
+
+	// hash参数可以在选项之前或之后
+	var query string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		query, args = args[0], args[1:]
+	}
+	parseFlags(extractCmd, args)
+	if query == "" {
+		query = strings.Join(extractCmd.Args(), " ")
+	}
+
+	fail := func(err error) {
+		code := failed(err)
+		if *jsonOutput {
+			errMsg := err.Error()
+			jsonData, _ := json.MarshalIndent(ExtractResponse{Success: false, Error: &errMsg, Code: code}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("extract.failed", err))
+	}
+
+	if query == "" {
+		fail(withCode(codeInvalidArg, errors.New(tr("usage.extract-code"))))
+		return
+	}
+	if !dbPath.setDefault() {
+		fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		return
+	}
+	anon, err := loadAnonymizer(*anonymizeRules)
+	if err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+
+	sessions, _, err := loadSessions(db, Config{})
+	if err != nil {
+		fail(err)
+		return
+	}
+	session, err := resolveSession(sessions, query)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	record := *session.Record
+	if *redact {
+		record, _ = redactRecord(record)
+	}
+	if anon != nil {
+		record = anon.anonymizeRecord(record)
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fail(withCode(codeWriteError, errors.New(tr("err.mkdirOut", err))))
+		return
+	}
+	manifest, err := extractCode(session, record, *outputDir)
+	if err != nil {
+		fail(err)
+		return
+	}
+	manifestPath := filepath.Join(*outputDir, extractManifest)
+
+	if *jsonOutput {
+		jsonData, err := json.MarshalIndent(ExtractResponse{
+			Success:      true,
+			OutputDir:    *outputDir,
+			ManifestPath: manifestPath,
+			Manifest:     manifest,
+		}, "", "  ")
+		if err != nil {
+			fail(errors.New(tr("err.json", err)))
+			return
+		}
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	for _, f := range manifest.Files {
+		if len(f.Versions) > 0 {
+			fmt.Println(tr("extract.fileVersions", f.OutputPath, len(f.Versions)))
+		} else {
+			fmt.Println(tr("extract.file", f.OutputPath))
+		}
+	}
+	if manifest.Snippets > 0 {
+		fmt.Println(tr("extract.snippets", manifest.Snippets))
+	}
+	fmt.Printf("\n%s\n", tr("extract.done", manifest.Title, len(manifest.Blocks), *outputDir, manifestPath))
+}
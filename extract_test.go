@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 工作区中名为manifest.json、snippets/和.history/的文件不能覆盖清单、代码片段和历史版本
+func TestExtractCodeReservedPaths(t *testing.T) {
+	block := func(path, language, content string) store.CodeBlock {
+		return store.CodeBlock{Uri: store.Uri{Path: path}, LanguageId: language, Content: content}
+	}
+	record := ChatRecord{Name: "Collisions", Conversation: []Message{
+		{Type: 1, Text: "write the files"},
+		{Type: 2, Text: "here", CodeBlocks: []store.CodeBlock{
+			block("/ws/manifest.json", "json", `{"user": true}`),
+			block("/ws/snippets/snippet-001.txt", "", "user snippet file"),
+			block("/ws/.history/main.v1.go", "go", "user history file"),
+			block("/ws/main.go", "go", "package main // v1"),
+			block("", "", "generated snippet"),
+		}},
+		{Type: 2, Text: "updated", CodeBlocks: []store.CodeBlock{
+			block("/ws/main.go", "go", "package main // v2"),
+		}},
+	}}
+	out := t.TempDir()
+	manifest, err := extractCode(SessionInfo{Hash: "abc"}, record, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// 清单中的每个文件都指向写入的内容
+	want := map[string]string{
+		"/ws/manifest.json":            `{"user": true}` + "\n",
+		"/ws/snippets/snippet-001.txt": "user snippet file\n",
+		"/ws/.history/main.v1.go":      "user history file\n",
+		"/ws/main.go":                  "package main // v2\n",
+	}
+	if len(manifest.Files) != len(want) {
+		t.Fatalf("manifest files = %+v, want %d files", manifest.Files, len(want))
+	}
+	for _, f := range manifest.Files {
+		if got := read(f.OutputPath); got != want[f.SourcePath] {
+			t.Errorf("%s (%s) = %q, want %q", f.SourcePath, f.OutputPath, got, want[f.SourcePath])
+		}
+	}
+	for _, b := range manifest.Blocks {
+		if b.SourcePath == "" && read(b.OutputPath) != "generated snippet\n" {
+			t.Errorf("snippet %s = %q, want the generated snippet", b.OutputPath, read(b.OutputPath))
+		}
+	}
+	if got := read("history/main.v1.go"); got != "package main // v1\n" {
+		t.Errorf("history/main.v1.go = %q, want the first version of main.go", got)
+	}
+
+	// 清单没有被代码块覆盖
+	var written ExtractManifest
+	if err := json.Unmarshal([]byte(read(extractManifest)), &written); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if written.Hash != "abc" || len(written.Blocks) != 6 || written.Snippets != 1 {
+		t.Errorf("manifest.json = %+v, want 6 blocks and 1 snippet of abc", written)
+	}
+}
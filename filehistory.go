@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type FileHistoryResponse struct {
+	Path     string        `json:"path"`
+	Mentions []FileMention `json:"mentions"`
+	Sessions int           `json:"sessions"` // 提及该文件的会话数量
+	Total    int           `json:"total"`
+	Success  bool          `json:"success"`
+	Error    *string       `json:"error,omitempty"`
+	Code     string        `json:"code,omitempty"`
+}
+
+// 提及方式的显示名称在消息目录中的ID
+var mentionSourceLabels = map[string]string{
+	"fileSelection": "md.fileSelections",
+	"selection":     "md.selections",
+	"codeBlock":     "md.codeBlock",
+}
+
+// 将文件的提及记录渲染为按时间顺序的Markdown报告
+func fileHistoryMarkdown(path string, mentions []FileMention, sessionCount int) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# %s\n\n", tr("history.title", path))
+	fmt.Fprintf(&md, "%s\n\n", tr("history.summary", len(mentions), sessionCount))
+
+	lastHash := ""
+	for _, m := range mentions {
+		// 连续的同一会话的提及放在同一个标题下
+		if m.Hash != lastHash {
+			fmt.Fprintf(&md, "## %s\n\n", m.Title)
+			fmt.Fprintf(&md, "%s\n\n", tr("history.session", m.Hash))
+			lastHash = m.Hash
+		}
+		role := "User"
+		if m.Role == "assistant" {
+			role = "Cursor"
+		}
+		fmt.Fprintf(&md, "### %s · %s · %s\n\n", m.Time.Format("2006-01-02 15:04:05"), tr("history.message", m.MessageIndex, role), tr(mentionSourceLabels[m.Source]))
+		fmt.Fprintf(&md, "%s\n\n", tr("history.file", filepath.Base(m.Path), m.Path))
+
+		excerpt := strings.TrimRight(m.Excerpt, "\n")
+		if excerpt == "" {
+			continue
+		}
+		if m.Source == "fileSelection" {
+			// 引用文件时摘录的是消息文本
+			md.WriteString("> " + strings.ReplaceAll(excerpt, "\n", "\n> ") + "\n\n")
+		} else {
+			md.WriteString("```\n" + excerpt + "\n```\n\n")
+		}
+	}
+	return md.String()
+}
+
+// file-history命令入口
+func runFileHistory(args []string) {
+	var config Config
+	historyCmd := flag.NewFlagSet("file-history", flag.ExitOnError)
+	registerDBFlags(historyCmd, &config.DBPaths)
+	historyCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json"))
+	outputFile := historyCmd.String("out", "", tr("flag.out.file-history"))
+	historyCmd.BoolVar(&config.Redact, "redact", false, tr("flag.redact"))
+	anonymizeRules := historyCmd.String("anonymize", "", tr("flag.anonymize"))
+	parseTimeFilters := registerTimeFilterFlags(historyCmd, &config)
+
+	// 路径参数可以在选项之前或之后
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	parseFlags(historyCmd, args)
+	if path == "" && historyCmd.NArg() > 0 {
+		path = historyCmd.Arg(0)
+	}
+
+	fail := func(err error) {
+		code := failed(err)
+		if config.JsonOutput {
+			errMsg := err.Error()
+			jsonData, _ := json.MarshalIndent(FileHistoryResponse{Path: path, Success: false, Error: &errMsg, Code: code}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("history.failed", err))
+	}
+
+	if strings.TrimSpace(path) == "" {
+		fail(withCode(codeInvalidArg, errors.New(tr("usage.file-history"))))
+		return
+	}
+	err := parseTimeFilters()
+	if err == nil {
+		config.Anonymizer, err = loadAnonymizer(*anonymizeRules)
+	}
+	if err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+	if !config.DBPaths.setDefault() {
+		fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		return
+	}
+
+	db, err := openDB(config.DBPaths)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+	sessions, _, err := loadSessions(db, config)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if config.Redact {
+		redactSessions(sessions)
+	}
+	if config.Anonymizer != nil {
+		config.Anonymizer.anonymizeSessions(sessions)
+	}
+
+	mentions := findFileMentions(sessions, path)
+	hashes := make(map[string]bool)
+	for _, m := range mentions {
+		hashes[m.Hash] = true
+	}
+
+	var output string
+	if config.JsonOutput {
+		response := FileHistoryResponse{
+			Path:     path,
+			Mentions: mentions,
+			Sessions: len(hashes),
+			Total:    len(mentions),
+			Success:  true,
+		}
+		if response.Mentions == nil {
+			response.Mentions = []FileMention{}
+		}
+		jsonData, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			fail(errors.New(tr("err.json", err)))
+			return
+		}
+		output = string(jsonData) + "\n"
+	} else {
+		if len(mentions) == 0 {
+			fmt.Println(tr("history.none", path))
+			return
+		}
+		output = fileHistoryMarkdown(path, mentions, len(hashes))
+	}
+
+	if *outputFile == "" {
+		fmt.Print(output)
+		return
+	}
+	if err := os.WriteFile(*outputFile, []byte(output), 0644); err != nil {
+		fail(withCode(codeWriteError, errors.New(tr("err.writeFile", err))))
+		return
+	}
+	if !config.JsonOutput {
+		fmt.Println(tr("history.written", *outputFile, len(mentions)))
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+func TestPathHasSuffix(t *testing.T) {
+	tests := []struct {
+		path, suffix string
+		want         bool
+	}{
+		{"/home/u/proj/bar/foo.go", "bar/foo.go", true},
+		{"/home/u/proj/bar/foo.go", "foo.go", true},
+		{"/home/u/proj/bar/foo.go", "/home/u/proj/bar/foo.go", true},
+		{"/home/u/proj/bar/foo.go", "./bar/foo.go", true},
+		{"/home/u/proj/bar/foo.go", `.\bar\foo.go`, true},
+		{`C:\Users\u\proj\bar\foo.go`, "bar/foo.go", true},
+		{`C:\Users\u\proj\bar\foo.go`, `proj\BAR\Foo.go`, true},
+		{"c:/users/u/proj/bar/foo.go", `C:\Users\u\proj\bar\foo.go`, true},
+		{"/home/u/proj/Bar/FOO.go", "bar/foo.go", true},
+		{"/home/u/proj/bar/", "bar", true},
+		// 按路径段匹配，不能只匹配文件名的一部分或目录名的一部分
+		{"/home/u/proj/xbar/foo.go", "bar/foo.go", false},
+		{"/home/u/proj/bar/xfoo.go", "foo.go", false},
+		{"/home/u/proj/bar/foo.go", "bar/foo", false},
+		{"/home/u/proj/bar/foo.go", "baz/foo.go", false},
+		{"", "foo.go", false},
+		{"/home/u/proj/foo.go", "", false},
+		{"/home/u/proj/foo.go", "./", false},
+	}
+	for _, tt := range tests {
+		if got := pathHasSuffix(tt.path, tt.suffix); got != tt.want {
+			t.Errorf("pathHasSuffix(%q, %q) = %v, want %v", tt.path, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+// 两个会话交替提及同一文件，结果按时间排序；没有计时信息的消息使用会话的开始时间
+func fileHistorySessions() []SessionInfo {
+	at := func(hour int) time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.Local) }
+	uri := func(path string) store.Uri { return store.Uri{Path: path} }
+	first := ChatRecord{Name: "Login", Conversation: []Message{
+		{Type: 1, Text: "look at this", TimingInfo: store.TimingInfo{ClientStartTime: at(9).UnixMilli()},
+			Context: store.MessageContext{FileSelections: []store.FileSelection{{Uri: uri("/home/u/proj/bar/foo.go")}}}},
+		{Type: 2, Text: "fixed", TimingInfo: store.TimingInfo{ClientStartTime: at(12).UnixMilli()},
+			CodeBlocks: []store.CodeBlock{{Uri: uri("/home/u/proj/bar/foo.go"), Content: "package bar\n"}, {Uri: uri("/home/u/proj/xbar/foo.go"), Content: "package xbar"}}},
+	}}
+	second := ChatRecord{Name: "Refactor", Conversation: []Message{
+		{Type: 1, Text: "refactor", Context: store.MessageContext{
+			Selections: []store.Selection{{Text: "func Foo() {}", Uri: uri(`C:\work\proj\Bar\foo.go`)}},
+		}},
+		{Type: 2, Text: "unrelated", TimingInfo: store.TimingInfo{ClientStartTime: at(11).UnixMilli()},
+			CodeBlocks: []store.CodeBlock{{Uri: uri("/home/u/proj/baz.go"), Content: "package baz"}}},
+	}}
+	return []SessionInfo{
+		{Hash: "h1", Title: "Login", StartTime: at(9), Record: &first},
+		{Hash: "h2", Title: "Refactor", StartTime: at(10), Record: &second},
+	}
+}
+
+func TestFindFileMentions(t *testing.T) {
+	mentions := findFileMentions(fileHistorySessions(), "./bar/foo.go")
+	type key struct {
+		hash   string
+		index  int
+		source string
+		hour   int
+	}
+	var got []key
+	for _, m := range mentions {
+		got = append(got, key{m.Hash, m.MessageIndex, m.Source, m.Time.Hour()})
+	}
+	want := []key{
+		{"h1", 1, "fileSelection", 9},
+		{"h2", 1, "selection", 10},
+		{"h1", 2, "codeBlock", 12},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("mentions = %v, want %v", got, want)
+	}
+	if len(mentions) == 3 && (mentions[1].Path != `C:\work\proj\Bar\foo.go` || mentions[1].Excerpt != "func Foo() {}" || mentions[1].Role != "user") {
+		t.Errorf("selection mention = %+v", mentions[1])
+	}
+	if got := findFileMentions(fileHistorySessions(), "qux.go"); len(got) != 0 {
+		t.Errorf("mentions of an unknown file = %v, want none", got)
+	}
+}
+
+func TestFileHistoryMarkdown(t *testing.T) {
+	mentions := findFileMentions(fileHistorySessions(), "bar/foo.go")
+	md := fileHistoryMarkdown("bar/foo.go", mentions, 2)
+
+	// 会话交替出现时每次切换都输出会话标题
+	var headings []string
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "#") {
+			headings = append(headings, line)
+		}
+	}
+	want := []string{
+		"# " + tr("history.title", "bar/foo.go"),
+		"## Login",
+		"### 2024-01-01 09:00:00 · " + tr("history.message", 1, "User") + " · " + tr("md.fileSelections"),
+		"## Refactor",
+		"### 2024-01-01 10:00:00 · " + tr("history.message", 1, "User") + " · " + tr("md.selections"),
+		"## Login",
+		"### 2024-01-01 12:00:00 · " + tr("history.message", 2, "Cursor") + " · " + tr("md.codeBlock"),
+	}
+	if !slices.Equal(headings, want) {
+		t.Errorf("headings:\n%s\nwant:\n%s", strings.Join(headings, "\n"), strings.Join(want, "\n"))
+	}
+	for _, part := range []string{
+		tr("history.summary", 3, 2),
+		"> look at this\n",
+		"```\nfunc Foo() {}\n```\n",
+		"```\npackage bar\n```\n",
+	} {
+		if !strings.Contains(md, part) {
+			t.Errorf("markdown does not contain %q:\n%s", part, md)
+		}
+	}
+	if strings.Contains(md, "package xbar") {
+		t.Errorf("markdown contains the code block of xbar/foo.go:\n%s", md)
+	}
+}
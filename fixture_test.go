@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试用会话的composer ID
+const (
+	fixtureLogin  = "11111111-aaaa-4000-8000-000000000001"
+	fixtureParser = "22222222-bbbb-4000-8000-000000000002"
+	fixtureHTML   = "33333333-cccc-4000-8000-000000000003"
+)
+
+// 测试用的composerData，消息直接保存在conversation中
+func fixtureComposer(id, name string, createdAt int64, texts ...string) string {
+	var conversation []map[string]any
+	for i, text := range texts {
+		conversation = append(conversation, map[string]any{
+			"type":     1 + i%2,
+			"text":     text,
+			"bubbleId": id[:8] + "-b" + string(rune('0'+i)),
+			"timingInfo": map[string]any{
+				"clientStartTime": createdAt + int64(i)*60000,
+				"clientEndTime":   createdAt + int64(i)*60000 + 30000,
+			},
+		})
+	}
+	data, _ := json.Marshal(map[string]any{
+		"composerId":   id,
+		"name":         name,
+		"createdAt":    createdAt,
+		"conversation": conversation,
+	})
+	return string(data)
+}
+
+// 在临时目录中创建包含三个会话的state.vscdb，返回文件路径
+// 其中一个会话的标题和消息包含HTML特殊字符，用于检查转义
+func newFixtureDB(t *testing.T) string {
+	t.Helper()
+	return writeFixtureDB(t, map[string]string{
+		fixtureLogin:  fixtureComposer(fixtureLogin, "Fix login bug", 1704099600000, "Why does login fail?", "Check the session cookie."),
+		fixtureParser: fixtureComposer(fixtureParser, "Parser refactor", 1704186000000, "Split the parser", "Done, see parser.go", "Thanks"),
+		fixtureHTML:   fixtureComposer(fixtureHTML, "<b>Escaping</b> & co", 1704272400000, "Render <script>alert(1)</script>", "Use html.EscapeString & friends"),
+	})
+}
+
+// 在临时目录中创建state.vscdb，rows为composer ID到composerData的映射，返回文件路径
+func writeFixtureDB(t *testing.T, rows map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.vscdb")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmts := []string{
+		"CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)",
+		"CREATE TABLE cursorDiskKV (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, value := range rows {
+		if _, err := db.Exec("INSERT INTO cursorDiskKV (key, value) VALUES (?, ?)", "composerData:"+id, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// 设置不包含配置文件的环境，避免用户的配置影响测试
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("CURSOR2MD_CONFIG", "")
+	t.Setenv("CURSOR2MD_PROFILE", "")
+}
+
+// 执行f并返回其写入标准输出的内容
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	return string(<-done)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// git log中的一个提交
+type GitCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Files   []string  `json:"files"` // 相对于仓库根目录的路径
+}
+
+// 与会话关联的git提交
+type RelatedCommit struct {
+	Hash    string    `json:"hash"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+	Files   []string  `json:"files"` // 与会话引用的文件重叠的部分
+}
+
+// 导出的会话记录及其关联的提交
+// 提交来自git仓库而不是Cursor的数据库，因此不放在store的ChatRecord中
+type exportRecord struct {
+	ChatRecord
+	RelatedCommits []RelatedCommit
+}
+
+// 渲染为Markdown，相关提交在最后
+func (r exportRecord) markdown() string {
+	return convertToMarkdown(r.ChatRecord) + relatedCommitsMarkdown(r.RelatedCommits)
+}
+
+// 会话及其关联的提交
+type SessionCommits struct {
+	Hash      string          `json:"hash"`
+	Title     string          `json:"title"`
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+	Commits   []RelatedCommit `json:"commits"`
+}
+
+type GitLinkResponse struct {
+	Repo     string           `json:"repo"`
+	Sessions []SessionCommits `json:"sessions"`
+	Commits  int              `json:"commits"` // 关联到会话的不同提交数量
+	Notes    int              `json:"notes"`   // 写入的git notes数量
+	Success  bool             `json:"success"`
+	Error    *string          `json:"error,omitempty"`
+	Code     string           `json:"code,omitempty"`
+}
+
+// git log输出中记录和字段的分隔符
+const (
+	gitRecordSep = "\x1e"
+	gitFieldSep  = "\x1f"
+)
+
+// 读取仓库的提交历史，since不为零时只读取之后的提交
+func readGitLog(repo string, since time.Time) ([]GitCommit, error) {
+	args := []string{"-C", repo, "log", "--no-color", "--no-renames", "--name-only",
+		"--format=" + gitRecordSep + "%H" + gitFieldSep + "%an" + gitFieldSep + "%ae" + gitFieldSep + "%ct" + gitFieldSep + "%s"}
+	if !since.IsZero() {
+		args = append(args, fmt.Sprintf("--since=@%d", since.Unix()))
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, errors.New(tr("git.log", strings.TrimSpace(string(exitErr.Stderr))))
+		}
+		return nil, errors.New(tr("git.log", err))
+	}
+	return parseGitLog(string(out)), nil
+}
+
+// 解析readGitLog格式的git log输出
+func parseGitLog(output string) []GitCommit {
+	var commits []GitCommit
+	for _, record := range strings.Split(output, gitRecordSep) {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], gitFieldSep)
+		if len(fields) < 5 {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		commit := GitCommit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Time:    time.Unix(seconds, 0),
+			Subject: fields[4],
+		}
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				commit.Files = append(commit.Files, line)
+			}
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// 按时间窗口和重叠的文件路径关联会话和提交
+// 提交时间需要在会话开始之后、结束后window之内，且至少有minOverlap个文件出现在会话引用的路径中
+func linkCommits(sessions []SessionInfo, commits []GitCommit, window time.Duration, minOverlap int) []SessionCommits {
+	var result []SessionCommits
+	for _, s := range sessions {
+		end := s.EndTime
+		if end.Unix() <= 0 || end.Before(s.StartTime) {
+			end = s.StartTime
+		}
+		paths := store.RecordPaths(*s.Record)
+
+		var related []RelatedCommit
+		for _, c := range commits {
+			if c.Time.Before(s.StartTime) || c.Time.After(end.Add(window)) {
+				continue
+			}
+			overlap := []string{}
+			for _, file := range c.Files {
+				for _, p := range paths {
+					if pathHasSuffix(p, file) {
+						overlap = append(overlap, file)
+						break
+					}
+				}
+			}
+			if len(overlap) < minOverlap {
+				continue
+			}
+			related = append(related, RelatedCommit{
+				Hash:    c.Hash,
+				Subject: c.Subject,
+				Author:  c.Author,
+				Time:    c.Time,
+				Files:   overlap,
+			})
+		}
+		if len(related) == 0 {
+			continue
+		}
+		sort.SliceStable(related, func(i, j int) bool { return related[i].Time.Before(related[j].Time) })
+		result = append(result, SessionCommits{
+			Hash:      s.Hash,
+			Title:     s.Title,
+			StartTime: s.StartTime,
+			EndTime:   s.EndTime,
+			Commits:   related,
+		})
+	}
+	return result
+}
+
+// 导出时关联git提交的设置
+type gitLinkOptions struct {
+	Repo   string        // 为空时不关联
+	Window time.Duration // 会话结束后多长时间内的提交可以关联到会话
+}
+
+// 注册导出时关联git提交的命令行参数，设置保存在config.GitLink中，返回在Parse之后调用的检查函数
+func registerGitLinkFlags(fs *flag.FlagSet, config *Config) func() error {
+	fs.StringVar(&config.GitLink.Repo, "git-repo", "", tr("flag.git-repo"))
+	fs.DurationVar(&config.GitLink.Window, "git-window", 2*time.Hour, tr("flag.git-window"))
+	return func() error {
+		if config.GitLink.Window < 0 {
+			return errors.New(tr("git.window"))
+		}
+		if config.GitLink.Repo != "" {
+			if err := checkGitRepo(config.GitLink.Repo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// 读取仓库历史，返回各会话关联的提交，用于在Markdown中输出相关提交
+// 没有设置仓库时返回nil
+func relatedCommits(sessions []SessionInfo, opts gitLinkOptions) (map[string][]RelatedCommit, error) {
+	if opts.Repo == "" || len(sessions) == 0 {
+		return nil, nil
+	}
+	since := sessions[0].StartTime
+	for _, s := range sessions {
+		if s.StartTime.Before(since) {
+			since = s.StartTime
+		}
+	}
+	commits, err := readGitLog(opts.Repo, since)
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string][]RelatedCommit)
+	for _, l := range linkCommits(sessions, commits, opts.Window, 1) {
+		links[l.Hash] = l.Commits
+	}
+	return links, nil
+}
+
+// 将相关提交渲染为Markdown的“相关提交”部分
+func relatedCommitsMarkdown(commits []RelatedCommit) string {
+	if len(commits) == 0 {
+		return ""
+	}
+	var md strings.Builder
+	md.WriteString("## " + tr("md.relatedCommits") + "\n\n")
+	for _, c := range commits {
+		hash := c.Hash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(&md, "- `%s` %s %s (%s)", hash, c.Time.Format("2006-01-02 15:04"), c.Subject, c.Author)
+		if len(c.Files) > 0 {
+			names := make([]string, len(c.Files))
+			for i, f := range c.Files {
+				names[i] = filepath.Base(f)
+			}
+			fmt.Fprintf(&md, " — %s", strings.Join(names, ", "))
+		}
+		md.WriteString("\n")
+	}
+	md.WriteString("\n")
+	return md.String()
+}
+
+// 为每个关联的提交写入git notes，同一提交关联的所有会话写在一条note中
+func writeGitNotes(repo string, ref string, links []SessionCommits) (int, error) {
+	notes := make(map[string][]string)
+	var order []string
+	for _, l := range links {
+		for _, c := range l.Commits {
+			if _, ok := notes[c.Hash]; !ok {
+				order = append(order, c.Hash)
+			}
+			notes[c.Hash] = append(notes[c.Hash], fmt.Sprintf("%s  %s  %s", l.Hash, l.StartTime.Format("2006-01-02 15:04"), l.Title))
+		}
+	}
+	for _, hash := range order {
+		message := tr("git.note") + "\n" + strings.Join(notes[hash], "\n")
+		out, err := exec.Command("git", "-C", repo, "notes", "--ref="+ref, "add", "-f", "-m", message, hash).CombinedOutput()
+		if err != nil {
+			return 0, errors.New(tr("git.notesFailed", hash, strings.TrimSpace(string(out))))
+		}
+	}
+	return len(order), nil
+}
+
+// git-link命令入口
+func runGitLink(args []string) {
+	var config Config
+	gitLinkCmd := flag.NewFlagSet("git-link", flag.ExitOnError)
+	registerDBFlags(gitLinkCmd, &config.DBPaths)
+	gitLinkCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json.git-link"))
+	window := gitLinkCmd.Duration("window", 2*time.Hour, tr("flag.window"))
+	minOverlap := gitLinkCmd.Int("min-overlap", 1, tr("flag.min-overlap"))
+	writeNotes := gitLinkCmd.Bool("notes", false, tr("flag.notes"))
+	notesRef := gitLinkCmd.String("notes-ref", "cursor2md", tr("flag.notes-ref"))
+	parseTimeFilters := registerTimeFilterFlags(gitLinkCmd, &config)
+
+	// 仓库参数可以在选项之前或之后
+	var repo string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		repo, args = args[0], args[1:]
+	}
+	parseFlags(gitLinkCmd, args)
+	if repo == "" && gitLinkCmd.NArg() > 0 {
+		repo = gitLinkCmd.Arg(0)
+	}
+
+	fail := func(err error) {
+		code := failed(err)
+		if config.JsonOutput {
+			errMsg := err.Error()
+			jsonData, _ := json.MarshalIndent(GitLinkResponse{Repo: repo, Success: false, Error: &errMsg, Code: code}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("git.failed", err))
+	}
+
+	if repo == "" {
+		fail(withCode(codeInvalidArg, errors.New(tr("usage.git-link"))))
+		return
+	}
+	if err := parseTimeFilters(); err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+	if *window < 0 || *minOverlap < 0 {
+		fail(withCode(codeInvalidArg, errors.New(tr("git.negative"))))
+		return
+	}
+	if err := checkGitRepo(repo); err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+	if !config.DBPaths.setDefault() {
+		fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		return
+	}
+
+	db, err := openDB(config.DBPaths)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+	sessions, _, err := loadSessions(db, config)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var since time.Time
+	for _, s := range sessions {
+		if since.IsZero() || s.StartTime.Before(since) {
+			since = s.StartTime
+		}
+	}
+	commits, err := readGitLog(repo, since)
+	if err != nil {
+		fail(err)
+		return
+	}
+	links := linkCommits(sessions, commits, *window, *minOverlap)
+
+	response := GitLinkResponse{Repo: repo, Sessions: links, Success: true}
+	if response.Sessions == nil {
+		response.Sessions = []SessionCommits{}
+	}
+	seen := make(map[string]bool)
+	for _, l := range links {
+		for _, c := range l.Commits {
+			seen[c.Hash] = true
+		}
+	}
+	response.Commits = len(seen)
+
+	if *writeNotes {
+		if response.Notes, err = writeGitNotes(repo, *notesRef, links); err != nil {
+			fail(withCode(codeWriteError, err))
+			return
+		}
+	}
+
+	if config.JsonOutput {
+		jsonData, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			fail(errors.New(tr("err.json", err)))
+			return
+		}
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	if len(links) == 0 {
+		fmt.Println(tr("git.none"))
+		return
+	}
+	for _, l := range links {
+		fmt.Printf("%s  %s  %s\n", l.Hash, l.StartTime.Format("2006-01-02 15:04"), l.Title)
+		for _, c := range l.Commits {
+			fmt.Printf("  %.12s  %s  %s (%s)\n", c.Hash, c.Time.Format("2006-01-02 15:04"), c.Subject, strings.Join(c.Files, ", "))
+		}
+	}
+	fmt.Printf("\n%s\n", tr("git.summary", len(links), response.Commits))
+	if *writeNotes {
+		fmt.Println(tr("git.notes", response.Notes, *notesRef, *notesRef))
+	}
+}
+
+// 检查路径是否为git仓库
+func checkGitRepo(repo string) error {
+	if _, err := os.Stat(repo); err != nil {
+		return errors.New(tr("git.noRepo", repo))
+	}
+	if err := exec.Command("git", "-C", repo, "rev-parse", "--git-dir").Run(); err != nil {
+		return errors.New(tr("git.notRepo", repo))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+func TestParseGitLog(t *testing.T) {
+	output := gitRecordSep + "0123456789abcdef0123456789abcdef01234567" + gitFieldSep + "Alice" + gitFieldSep + "alice@example.com" + gitFieldSep + "1704099600" + gitFieldSep + "Fix login: handle expired cookies\n" +
+		"\n" +
+		"src/login.go\n" +
+		"README.md\n" +
+		gitRecordSep + "fedcba9876543210fedcba9876543210fedcba98" + gitFieldSep + "Bob" + gitFieldSep + "bob@example.com" + gitFieldSep + "1704096000" + gitFieldSep + "Empty commit\n" +
+		// 字段不足或时间无效的记录被忽略
+		gitRecordSep + "broken" + gitFieldSep + "only two fields\n" +
+		gitRecordSep + "abc" + gitFieldSep + "Carol" + gitFieldSep + "c@example.com" + gitFieldSep + "yesterday" + gitFieldSep + "Bad time\n"
+
+	commits := parseGitLog(output)
+	if len(commits) != 2 {
+		t.Fatalf("parsed %d commits, want 2: %+v", len(commits), commits)
+	}
+	first := commits[0]
+	if first.Hash != "0123456789abcdef0123456789abcdef01234567" || first.Author != "Alice" || first.Email != "alice@example.com" ||
+		!first.Time.Equal(time.Unix(1704099600, 0)) || first.Subject != "Fix login: handle expired cookies" {
+		t.Errorf("first commit = %+v", first)
+	}
+	if !slices.Equal(first.Files, []string{"src/login.go", "README.md"}) {
+		t.Errorf("first commit files = %v", first.Files)
+	}
+	if commits[1].Subject != "Empty commit" || len(commits[1].Files) != 0 {
+		t.Errorf("second commit = %+v, want no files", commits[1])
+	}
+	if got := parseGitLog(""); len(got) != 0 {
+		t.Errorf("parseGitLog(\"\") = %v, want none", got)
+	}
+}
+
+func TestLinkCommits(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	record := ChatRecord{Conversation: []Message{
+		{Type: 1, Context: store.MessageContext{FileSelections: []store.FileSelection{{Uri: store.Uri{Path: "/home/u/proj/src/login.go"}}}}},
+		{Type: 2, CodeBlocks: []store.CodeBlock{{Uri: store.Uri{Path: "/home/u/proj/src/session.go"}}}},
+	}}
+	sessions := []SessionInfo{
+		{Hash: "s1", Title: "Fix login", StartTime: start, EndTime: start.Add(30 * time.Minute), Record: &record},
+		// 没有结束时间时使用开始时间
+		{Hash: "s2", Title: "No end", StartTime: start.Add(24 * time.Hour), Record: &record},
+		{Hash: "s3", Title: "Unrelated", StartTime: start, EndTime: start.Add(time.Hour), Record: &ChatRecord{}},
+	}
+	commit := func(hash string, at time.Time, files ...string) GitCommit {
+		return GitCommit{Hash: hash, Subject: "commit " + hash, Author: "Alice", Time: at, Files: files}
+	}
+	// git log从新到旧输出
+	commits := []GitCommit{
+		commit("late", start.Add(24*time.Hour+time.Hour+time.Second), "src/login.go"),
+		commit("next-day", start.Add(24*time.Hour+time.Hour), "src/login.go"),
+		commit("after-window", start.Add(30*time.Minute+time.Hour+time.Second), "src/login.go"),
+		commit("window-end", start.Add(30*time.Minute+time.Hour), "src/session.go"),
+		commit("both", start.Add(20*time.Minute), "src/login.go", "src/session.go", "docs/a.md"),
+		commit("docs", start.Add(15*time.Minute), "docs/a.md"),
+		commit("login", start.Add(10*time.Minute), "src/login.go"),
+		commit("before", start.Add(-time.Minute), "src/login.go"),
+	}
+
+	hashes := func(links []SessionCommits) map[string][]string {
+		result := make(map[string][]string)
+		for _, l := range links {
+			for _, c := range l.Commits {
+				result[l.Hash] = append(result[l.Hash], c.Hash)
+			}
+		}
+		return result
+	}
+
+	links := linkCommits(sessions, commits, time.Hour, 1)
+	got := hashes(links)
+	want := map[string][]string{
+		"s1": {"login", "both", "window-end"},
+		"s2": {"next-day"},
+	}
+	if len(got) != len(want) || !slices.Equal(got["s1"], want["s1"]) || !slices.Equal(got["s2"], want["s2"]) {
+		t.Errorf("links = %v, want %v", got, want)
+	}
+	for _, c := range links[0].Commits {
+		if c.Hash == "both" && !slices.Equal(c.Files, []string{"src/login.go", "src/session.go"}) {
+			t.Errorf("overlap of %s = %v, want only the files referenced by the session", c.Hash, c.Files)
+		}
+	}
+
+	got = hashes(linkCommits(sessions, commits, time.Hour, 2))
+	if len(got) != 1 || !slices.Equal(got["s1"], []string{"both"}) {
+		t.Errorf("links with min overlap 2 = %v, want only both", got)
+	}
+
+	// 最少重叠数为0时只按时间关联，结果按提交时间排序
+	got = hashes(linkCommits(sessions[2:], commits, time.Hour, 0))
+	if want := []string{"login", "docs", "both", "window-end", "after-window"}; !slices.Equal(got["s3"], want) {
+		t.Errorf("links without overlap = %v, want %v", got["s3"], want)
+	}
+}
+
+func TestRelatedCommitsMarkdown(t *testing.T) {
+	if md := relatedCommitsMarkdown(nil); md != "" {
+		t.Errorf("markdown without commits = %q, want empty", md)
+	}
+	at := time.Date(2024, 1, 1, 10, 10, 0, 0, time.Local)
+	md := relatedCommitsMarkdown([]RelatedCommit{
+		{Hash: "0123456789abcdef0123", Subject: "Fix login", Author: "Alice", Time: at, Files: []string{"src/login.go", "README.md"}},
+		{Hash: "abc123", Subject: "Tweak", Author: "Bob", Time: at.Add(time.Hour)},
+	})
+	want := "## " + tr("md.relatedCommits") + "\n\n" +
+		"- `0123456789ab` 2024-01-01 10:10 Fix login (Alice) — login.go, README.md\n" +
+		"- `abc123` 2024-01-01 11:10 Tweak (Bob)\n" +
+		"\n"
+	if md != want {
+		t.Errorf("markdown = %q, want %q", md, want)
+	}
+}
+
+// 没有指定仓库时不读取git历史
+func TestRelatedCommitsWithoutRepo(t *testing.T) {
+	commits, err := relatedCommits(fixtureSessions(t), gitLinkOptions{Window: time.Hour})
+	if err != nil || commits != nil {
+		t.Errorf("relatedCommits without a repository = %v, %v; want nil", commits, err)
+	}
+}
@@ -2,4 +2,4 @@ module github.com/M6ZeroG/cursor2md
 
 go 1.23.4
 
-require github.com/mattn/go-sqlite3 v1.14.24 // indirect
+require github.com/mattn/go-sqlite3 v1.14.24
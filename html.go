@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 独立HTML页面的样式
+const htmlStyle = `body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;max-width:1200px;margin:2em auto;padding:0 1em;color:#24292f}
+h2{border-bottom:1px solid #d0d7de;padding-bottom:.3em}
+blockquote{margin:0;padding:0 1em;color:#57606a;border-left:.25em solid #d0d7de;white-space:pre-wrap}
+.text{white-space:pre-wrap}
+.file{font-size:.9em;color:#57606a;margin:.8em 0 .2em}
+pre{background:#f6f8fa;padding:.8em;overflow:auto;font-size:.85em}
+table.diff{border-collapse:collapse;width:100%;font-family:ui-monospace,Menlo,Consolas,monospace;font-size:.8em;table-layout:fixed}
+table.diff td{padding:0 .5em;white-space:pre-wrap;word-break:break-all;vertical-align:top}
+table.diff td.num{width:3em;color:#8c959f;text-align:right;user-select:none}
+table.diff td.del{background:#ffebe9}
+table.diff td.ins{background:#e6ffec}
+table.diff td.empty{background:#f6f8fa}
+table.diff tr.gap td{background:#ddf4ff;color:#57606a;text-align:center}
+`
+
+// 渲染并排显示的差异表格
+func writeSideBySideHTML(sb *strings.Builder, rows []sideBySideRow) {
+	sb.WriteString("<table class=\"diff\">\n")
+	cell := func(op *diffOp, line int, class string) {
+		if op == nil {
+			sb.WriteString(`<td class="num"></td><td class="empty"></td>`)
+			return
+		}
+		fmt.Fprintf(sb, `<td class="num">%d</td><td class="%s">%s</td>`, line, class, html.EscapeString(op.Text))
+	}
+	for _, row := range rows {
+		if row.Gap {
+			sb.WriteString("<tr class=\"gap\"><td colspan=\"4\">⋯</td></tr>\n")
+			continue
+		}
+		oldClass, newClass := "", ""
+		if row.Old != row.New {
+			oldClass, newClass = "del", "ins"
+		}
+		sb.WriteString("<tr>")
+		if row.Old != nil {
+			cell(row.Old, row.Old.OldLine, oldClass)
+		} else {
+			cell(nil, 0, "")
+		}
+		if row.New != nil {
+			cell(row.New, row.New.NewLine, newClass)
+		} else {
+			cell(nil, 0, "")
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>\n")
+}
+
+// 渲染文件链接列表
+func htmlFileLinks(paths []string) string {
+	links := make([]string, 0, len(paths))
+	for _, p := range paths {
+		links = append(links, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(p), html.EscapeString(filepath.Base(p))))
+	}
+	return strings.Join(links, " ")
+}
+
+// 转换为独立的HTML页面，启用-diff时代码块的差异以并排表格显示
+func convertToHTML(record ChatRecord) string {
+	var sb strings.Builder
+	if len(record.Conversation) > 0 {
+		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
+	}
+
+	title := html.EscapeString(record.Name)
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", title, htmlStyle)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<ul>\n", title)
+	fmt.Fprintf(&sb, "<li>%s: %s</li>\n", tr("md.startTime"), time.Unix(record.CreatedAt/1000, 0).Format("2006-01-02 15:04:05"))
+	if record.EndedAt > 0 {
+		fmt.Fprintf(&sb, "<li>%s: %s</li>\n", tr("md.endTime"), time.Unix(record.EndedAt/1000, 0).Format("2006-01-02 15:04:05"))
+	}
+	usage := defaultTokenEstimator.recordUsage(record)
+	if defaultTokenEstimator.hasCost(record) {
+		fmt.Fprintf(&sb, "<li>%s: %s</li>\n", tr("md.tokens"),
+			tr("md.tokenUsage", usage.Input, usage.Output, usage.Total, html.EscapeString(usage.Model), formatCost(usage.Cost)))
+	} else {
+		fmt.Fprintf(&sb, "<li>%s: %s</li>\n", tr("md.tokens"), tr("md.tokenCounts", usage.Input, usage.Output, usage.Total))
+	}
+	if len(record.Context.FileSelections) > 0 {
+		var paths []string
+		for _, file := range record.Context.FileSelections {
+			paths = append(paths, file.Uri.Path)
+		}
+		fmt.Fprintf(&sb, "<li>%s: %s</li>\n", tr("md.files"), htmlFileLinks(paths))
+	}
+	sb.WriteString("</ul>\n")
+
+	for _, msg := range record.Conversation {
+		switch msg.Type {
+		case 1:
+			sb.WriteString("<h2>User</h2>\n")
+			if len(msg.Context.FileSelections) > 0 {
+				var paths []string
+				for _, file := range msg.Context.FileSelections {
+					paths = append(paths, file.Uri.Path)
+				}
+				fmt.Fprintf(&sb, "<p>%s: %s</p>\n", tr("md.fileSelections"), htmlFileLinks(paths))
+			}
+			for _, sel := range msg.Context.Selections {
+				if sel.Uri.Path != "" {
+					fmt.Fprintf(&sb, "<div class=\"file\">From %s:</div>\n", htmlFileLinks([]string{sel.Uri.Path}))
+				}
+				fmt.Fprintf(&sb, "<pre>%s</pre>\n", html.EscapeString(sel.Text))
+			}
+			fmt.Fprintf(&sb, "<blockquote>%s</blockquote>\n", html.EscapeString(msg.Text))
+
+		case 2:
+			sb.WriteString("<h2>Cursor</h2>\n")
+			fmt.Fprintf(&sb, "<div class=\"text\">%s</div>\n", html.EscapeString(msg.Text))
+			for _, block := range msg.CodeBlocks {
+				if block.Content == "" {
+					continue
+				}
+				if block.Uri.Path != "" {
+					fmt.Fprintf(&sb, "<div class=\"file\">%s %s</div>\n", html.EscapeString(block.LanguageId), htmlFileLinks([]string{block.Uri.Path}))
+				}
+				base, hasBase := "", false
+				if defaultCodeDiffOptions.Mode != "" && block.Uri.Path != "" {
+					base, hasBase = loadCodeDiffBase(block.Uri.Path, defaultCodeDiffOptions.Rev)
+				}
+				if !hasBase || defaultCodeDiffOptions.Mode == "both" {
+					fmt.Fprintf(&sb, "<pre><code>%s</code></pre>\n", html.EscapeString(block.Content))
+				}
+				if hasBase {
+					rows := sideBySideRows(base, block.Content, defaultCodeDiffOptions.Context)
+					if len(rows) == 0 {
+						fmt.Fprintf(&sb, "<p>%s</p>\n", tr("md.sameAsFile"))
+					} else {
+						writeSideBySideHTML(&sb, rows)
+					}
+				}
+			}
+		}
+	}
+	if len(record.AppliedChanges) > 0 {
+		title := tr("md.appliedChanges")
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n", html.EscapeString(title))
+		fmt.Fprintf(&sb, "<pre>%s</pre>\n", html.EscapeString(strings.TrimPrefix(appliedChangesMarkdown(record.AppliedChanges), "## "+title+"\n\n")))
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
@@ -0,0 +1,973 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 默认语言，目录中缺少的消息也使用该语言
+const defaultLang = "zh"
+
+// 支持的界面语言
+var languages = []string{"zh", "en"}
+
+// 当前的界面语言，由initLang在解析命令行参数之前设置
+var lang = defaultLang
+
+// 命令行输出、参数说明、错误信息和Markdown标签的消息目录，键为消息ID
+// 带参数的消息使用fmt的格式，各语言中参数的顺序和类型必须一致
+var catalogs = map[string]map[string]string{
+	"zh": {
+		"help": `使用说明:
+  cursor2md ls [-db <数据库路径>] [-json] [-sort <字段>] [-sort-desc] [-limit <N>] [-offset <N>] [-columns <列>] [-start-after <时间>] [-start-before <时间>] [-end-after <时间>] [-end-before <时间>]  列出会话信息
+  cursor2md export [<hash>] [-db <数据库路径>] [-out <输出目录>] [-byname]  导出指定hash的会话
+  cursor2md export [-db <数据库路径>] [-out <输出目录>] [-sort-desc] [-byname] [-strict] [-start-after <时间>] [-start-before <时间>] [-end-after <时间>] [-end-before <时间>]  导出会话记录
+  cursor2md show <hash|hash前缀|标题> [-db <数据库路径>] [-format <格式>] [-template <模板文件>] [-messages <起始:结束>]  将会话输出到标准输出
+  cursor2md browse [-db <数据库路径>] [-out <输出目录>]  在终端中交互式浏览和导出会话
+  cursor2md serve [-db <数据库路径>] [-addr <监听地址>]  启动本地网页界面和REST接口
+  cursor2md mcp [-db <数据库路径>]  通过标准输入输出提供MCP服务，供其他AI代理查询聊天记录
+  cursor2md stats [-db <数据库路径>] [-format text|json|csv] [-by day|week|month] [-top <N>] [-svg <文件>] [-start-after <时间>] ...  统计使用情况
+  cursor2md extract-code <hash|hash前缀|标题> [-db <数据库路径>] [-out <输出目录>] [-json]  将AI回复中的代码块写入文件树
+  cursor2md git-link <仓库路径> [-db <数据库路径>] [-window <时长>] [-min-overlap <N>] [-notes] [-json]  按时间和文件关联会话与git提交
+  cursor2md file-history <文件路径> [-db <数据库路径>] [-json] [-out <输出文件>]  按时间顺序列出所有提及该文件的消息
+  cursor2md doctor [-db <数据库路径> | -archive <归档文件>] [-json] [-verbose]  检查数据库完整性、存储格式和被跳过的记录
+  cursor2md backup [-db <数据库路径>] [-archive <归档文件>] [-history <hash>] [-json]  将会话的原始数据备份到带版本的归档
+  cursor2md import -from <归档|数据库|JSON文件|目录> [-db <目标数据库>] [-conflict skip|overwrite|rename] [-apply [-yes]] [-json]  预览或将会话写入Cursor的数据库
+  cursor2md config show [<命令>] [-profile <方案>] [-json]  显示配置文件和环境变量中的有效配置
+  cursor2md version  显示版本信息
+  cursor2md help  显示此帮助信息
+
+数据库参数说明 (doctor以外的命令):
+  -db          可以重复指定，或指定包含多个数据库副本的目录，合并其中的会话，同一会话只保留最完整或最新的版本
+  -archive     从backup创建的归档读取会话，可以与-db一起使用，也可以重复指定
+
+排序参数说明:
+                使用-sort-desc=false可改为升序排序（从旧到新）
+  -byname      在文件名前添加序号（例如：001-文件名.md）
+
+脱敏参数说明:
+  -redact      导出前替换密钥、令牌、私钥、URL中的密码和高熵字符串（serve、mcp和show -format html默认开启）
+  -anonymize   使用规则文件改写路径、用户名、邮箱和IP等个人信息
+
+诊断参数说明 (ls, export, doctor):
+  -verbose     列出每条被跳过的记录的键和原因，默认只按原因统计数量
+  -strict      (export) 有会话无法解析或写入失败时以非零状态退出
+
+配置参数说明 (所有命令):
+  -profile     使用配置文件中[profiles.<方案>]表的配置
+               配置文件: $XDG_CONFIG_HOME/cursor2md/config.toml 和当前目录的 .cursor2md.toml
+               优先级从低到高: 配置文件顶层 < [命令] < [profiles.方案] < [profiles.方案.命令] < CURSOR2MD_*环境变量 < 命令行参数
+  -lang        界面和Markdown标签的语言: zh 或 en
+               没有指定时依次使用配置文件和CURSOR2MD_LANG、LC_ALL、LC_MESSAGES、LANG，默认为zh
+
+退出状态:
+  0 成功  1 其他错误  2 invalid-arg  3 db-not-found  4 db-locked  5 parse-error  6 not-found  7 write-error
+
+代码差异参数说明 (export, show):
+  -diff        将代码块与磁盘上的文件比较: replace（只显示差异）或 both（同时显示代码块和差异）
+  -diff-rev    与指定的git版本比较，而不是磁盘上的当前文件
+
+git关联参数说明 (export):
+  -git-repo    关联该仓库中在会话期间及结束后-git-window内、修改了会话引用文件的提交
+
+Token估算参数说明 (ls, export, show, stats):
+  -tokenizer   估算token数量的分词器: approx（近似BPE，默认）或 chars（每4个字符一个token）
+  -prices      模型价格表文件 (JSON)，单位为美元/百万token
+  -model       会话没有记录模型时用于估算费用的模型
+  -front-matter (export, show) 在Markdown开头输出YAML front matter，包含token估算；会话记录了模型或指定了-model、-prices时包含费用
+`,
+
+		"flag.db":                  "数据库文件或包含数据库副本的目录，可以重复指定以合并多个数据库 (默认: 系统默认路径)",
+		"flag.db.doctor":           "数据库文件路径 (默认: 系统默认路径)",
+		"flag.archive":             "从backup创建的归档读取会话，可以重复指定，也可以与-db一起使用",
+		"flag.archive.backup":      "归档文件路径，不存在时创建",
+		"flag.archive.doctor":      "检查backup创建的归档而不是数据库",
+		"flag.history":             "列出ID以该值开头的会话在归档中的所有版本，不进行备份",
+		"flag.from":                "导入的归档、数据库、show -format json/raw输出的JSON文件或包含JSON文件的目录，可以重复指定",
+		"flag.db.import":           "写入的目标数据库 (默认: 系统默认路径)",
+		"flag.conflict":            "目标数据库中已有同一会话时的处理方式 (skip, overwrite, rename)",
+		"flag.apply":               "备份目标数据库后写入，不指定时只预览",
+		"flag.json":                "以JSON格式输出",
+		"flag.json.stats":          "以JSON格式输出 (等同于 -format json)",
+		"flag.json.git-link":       "以JSON格式输出关联报告",
+		"flag.out":                 "markdown文件输出目录",
+		"flag.out.extract-code":    "代码文件输出目录",
+		"flag.out.file-history":    "将报告写入文件而不是标准输出",
+		"flag.sort":                "排序字段 (start, end, title, messages, size)",
+		"flag.sort-desc":           "按降序排序",
+		"flag.sort-desc.export":    "按时间降序排序（从新到旧）",
+		"flag.limit":               "最多显示的会话数量 (0表示不限制)",
+		"flag.offset":              "跳过前N个会话",
+		"flag.columns":             "输出的列，逗号分隔 (可选: %s)",
+		"flag.front-matter":        "在Markdown开头输出包含会话信息和token估算的YAML front matter",
+		"flag.start-after":         "仅包含在此时间之后开始的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)",
+		"flag.start-before":        "仅包含在此时间之前开始的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)",
+		"flag.end-after":           "仅包含在此时间之后结束的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)",
+		"flag.end-before":          "仅包含在此时间之前结束的会话 (格式: 2006-01-02 或 2006-01-02 15:04:05)",
+		"flag.byname":              "在文件名前添加序号",
+		"flag.verbose":             "列出每条被跳过的记录及原因",
+		"flag.strict":              "有会话无法解析或写入失败时以错误结束",
+		"flag.strict.single":       "该会话在任一数据库中无法解析或写入失败时以错误结束",
+		"flag.redact":              "替换消息、代码片段和代码块中的密钥、令牌等敏感信息",
+		"flag.redact.show":         "替换密钥、令牌等敏感信息 (-format html默认开启)",
+		"flag.redact.mcp":          "替换密钥、令牌等敏感信息 (使用-redact=false关闭)",
+		"flag.redact.browse":       "预览、导出和复制时替换密钥、令牌等敏感信息",
+		"flag.redact.extract-code": "替换代码块中的密钥、令牌等敏感信息",
+		"flag.anonymize":           "匿名化规则文件 (JSON)，用于改写路径、邮箱、IP等个人信息",
+		"flag.anonymize.ls":        "匿名化规则文件 (JSON)，用于改写标题和工作区路径",
+		"flag.profile":             "使用配置文件中的配置方案 (也可以通过CURSOR2MD_PROFILE指定)",
+		"flag.profile.config":      "显示使用该配置方案时的配置",
+		"flag.lang":                "界面和Markdown标签的语言 (zh, en)",
+		"flag.diff":                "将代码块与磁盘上的文件比较并显示差异: replace（只显示差异）或 both（同时显示代码块）",
+		"flag.diff-rev":            "与指定的git版本（例如 HEAD~1）比较，而不是磁盘上的当前文件",
+		"flag.diff-context":        "差异的上下文行数",
+		"flag.git-repo":            "关联该git仓库中的提交，在Markdown中添加“相关提交”部分",
+		"flag.git-window":          "会话结束后多长时间内的提交可以关联到会话",
+		"flag.window":              "会话结束后多长时间内的提交可以关联到会话",
+		"flag.min-overlap":         "提交中至少有多少个文件出现在会话引用的路径中 (0表示只按时间关联)",
+		"flag.notes":               "将关联的会话写入git notes",
+		"flag.notes-ref":           "写入git notes使用的ref",
+		"flag.tokenizer":           "估算token数量的分词器 (%s)",
+		"flag.prices":              "模型价格表文件 (JSON)，单位为美元/百万token，覆盖内置价格",
+		"flag.model":               "会话没有记录模型时用于估算费用的模型 (默认: %s)",
+		"flag.format":              "输出格式 (%s)",
+		"flag.format.stats":        "输出格式 (text, json, csv)",
+		"flag.template":            "使用text/template模板文件渲染会话，可以通过 .Field \"名称\" 读取未解析的字段",
+		"flag.messages":            "只输出指定范围的消息，例如 3:7、3:、:7",
+		"flag.by":                  "文本输出中按时间段汇总的方式 (day, week, month)",
+		"flag.top":                 "文件和目录排行的数量",
+		"flag.svg":                 "将活动热力图和语言、文件条形图写入SVG文件",
+		"flag.svg-from":            "热力图的开始日期 (默认: 结束日期之前一年)",
+		"flag.svg-to":              "热力图的结束日期 (默认: 最后一个会话的日期)",
+		"flag.svg-colors":          "热力图的颜色（#rgb、#rrggbb或颜色名称），从无活动到最活跃，逗号分隔",
+		"flag.addr":                "监听地址",
+		"flag.clipboard":           "复制操作写入的文件",
+		"flag.input":               "从文件读取按键序列而不是终端（用于脚本和测试）",
+		"flag.size":                "界面大小，格式为 宽x高 (默认: 终端大小)",
+
+		"err.homeDir":           "获取用户主目录失败: %v",
+		"err.unsupportedOS":     "不支持的操作系统: %s",
+		"err.defaultDB":         "无法确定默认数据库路径",
+		"err.dbNotExist":        "数据库文件不存在: %s",
+		"err.dbNotInDir":        "目录中没有.vscdb数据库文件: %s",
+		"err.notArchive":        "不是cursor2md的归档文件: %s",
+		"err.defaultArchive":    "无法确定默认的归档路径，请使用-archive指定",
+		"err.noArchiveVersions": "归档中没有ID以%s开头的会话",
+		"err.importFrom":        "请使用-from指定要导入的归档、数据库或JSON文件",
+		"err.conflict":          "不支持的冲突处理方式: %s (可选: %s)",
+		"err.importArchive":     "不能导入到归档，请指定Cursor的数据库: %s",
+		"err.timeFormat":        "无效的时间格式: %s",
+		"err.parseFlag":         "解析%s参数失败: %v",
+		"err.column":            "无效的列名: %s (可选: %s)",
+		"err.sortField":         "无效的排序字段: %s (可选: start, end, title, messages, size)",
+		"err.negative":          "-limit和-offset不能为负数",
+		"err.json":              "JSON序列化失败: %v",
+		"err.mkdirOut":          "创建输出目录失败: %v",
+		"err.writeMarkdown":     "写入markdown文件失败: %v",
+		"err.sessionHash":       "未找到哈希值为 %s 的会话",
+		"err.config":            "读取配置失败: %v",
+		"err.lang":              "不支持的语言: %s (可选: %s)",
+		"err.writeFile":         "写入文件失败: %v",
+		"err.mkdir":             "创建目录失败: %v",
+
+		"column.hash":      "HASH",
+		"column.start":     "START",
+		"column.end":       "END",
+		"column.title":     "TITLE",
+		"column.messages":  "MESSAGES",
+		"column.size":      "SIZE",
+		"column.workspace": "WORKSPACE",
+		"column.source":    "SOURCE",
+		"column.tokens":    "TOKENS",
+		"column.cost":      "COST",
+		"column.period":    "PERIOD",
+		"column.sessions":  "SESSIONS",
+		"column.input":     "INPUT",
+		"column.output":    "OUTPUT",
+		"column.total":     "TOTAL",
+		"column.name":      "NAME",
+		"column.session":   "SESSION",
+		"column.version":   "VERSION",
+		"column.archived":  "ARCHIVED",
+		"column.rows":      "ROWS",
+		"column.checksum":  "CHECKSUM",
+		"column.action":    "ACTION",
+
+		"ls.failed":   "列出会话失败",
+		"ls.notEnded": "未结束",
+		"ls.empty":    "数据库中没有有效的会话记录",
+		"ls.shown":    "显示 %d 个会话，共有 %d 个会话",
+		"ls.total":    "共有 %d 个会话",
+		"skipped":     "跳过 %d 条记录: %s",
+
+		"export.failed":      "导出会话失败",
+		"backup.failed":      "备份失败: %v",
+		"backup.archive":     "归档: %s",
+		"backup.report":      "%s: %d 个会话，新增 %d 个，更新 %d 个，未变化 %d 个",
+		"backup.shared":      "  共享记录新增 %d 个版本",
+		"import.failed":      "导入失败: %v",
+		"import.target":      "目标数据库: %s",
+		"import.summary":     "新增 %d 个，覆盖 %d 个，重命名 %d 个，跳过 %d 个",
+		"import.preview":     "以上为预览，没有修改数据库。请先退出Cursor，确认后使用-apply写入",
+		"import.nothing":     "没有需要写入的会话",
+		"import.backup":      "已备份目标数据库: %s",
+		"import.done":        "已写入 %d 个会话，重新启动Cursor后生效",
+		"export.failedCount": "%d 个会话导出失败",
+		"export.session":     "导出会话: %s (开始时间: %s)",
+		"export.redacted":    "已替换敏感信息: %s",
+		"export.done":        "成功导出 %d 个会话到 %s",
+		"export.single":      "成功导出会话: %s",
+		"export.complete":    "导出完成!",
+
+		"md.sessionInfo":    "会话信息",
+		"md.startTime":      "开始时间",
+		"md.endTime":        "结束时间",
+		"md.tokens":         "估算Token",
+		"md.tokenUsage":     "输入 %d / 输出 %d / 共 %d (%s, 约 %s)",
+		"md.tokenCounts":    "输入 %d / 输出 %d / 共 %d",
+		"md.files":          "相关文件",
+		"md.fileSelections": "引用的文件",
+		"md.selections":     "引用的代码片段",
+		"md.codeBlock":      "代码块",
+		"md.selectionFrom":  "From [%s](%s):",
+		"md.unchanged":      "[%s](%s) 与原文件相同",
+		"md.sameAsFile":     "与原文件相同",
+		"md.appliedChanges": "已应用的修改",
+		"md.unknownFile":    "(未知文件)",
+		"md.changeSource":   "来源: %s",
+		"md.changeHunks":    "，%d 处修改",
+		"md.changeNote":     "，%s",
+		"md.accepted":       "已接受",
+		"md.rejected":       "已拒绝",
+		"md.pending":        "未处理",
+		"md.relatedCommits": "相关提交",
+
+		"note.checkpoint":  "检查点记录的是修改前的内容",
+		"note.new-file":    "新文件",
+		"note.not-existed": "修改前文件不存在",
+
+		"err.format": "不支持的输出格式: %s (可选: %s)",
+
+		"stats.sessions":       "会话数量",
+		"stats.messages":       "消息数量",
+		"stats.messageCounts":  "%d (用户 %d / AI %d)",
+		"stats.avgMessages":    "平均每个会话的消息数",
+		"stats.range":          "时间范围",
+		"stats.latency":        "AI回复耗时",
+		"stats.latencyValues":  "平均 %s, 中位数 %s, P90 %s, 最长 %s (%d 条回复)",
+		"stats.tokenValues":    "输入 %d / 输出 %d / 共 %d, 估算费用 %s",
+		"stats.monthly":        "按月统计",
+		"stats.weekly":         "按周统计",
+		"stats.daily":          "按天统计",
+		"stats.tokenProjects":  "按项目估算Token",
+		"stats.tokenModels":    "按模型估算Token",
+		"stats.topFiles":       "引用最多的文件",
+		"stats.topDirectories": "引用最多的目录",
+		"stats.languages":      "代码块语言分布",
+		"stats.hours":          "按小时分布的消息数量",
+		"stats.csv":            "写入CSV失败: %v",
+		"stats.by":             "无效的汇总方式: %s (可选: day, week, month)",
+		"stats.svg":            "已生成SVG图表: %s",
+
+		"doctor.failed":          "检查数据库失败: %v",
+		"doctor.dbAndArchive":    "-db和-archive只能指定一个",
+		"doctor.db":              "数据库: %s",
+		"doctor.integrity":       "完整性检查: %s",
+		"doctor.integrityFailed": "失败",
+		"doctor.tables":          "数据表:",
+		"doctor.rows":            "%d 行",
+		"doctor.missing":         "不存在",
+		"doctor.prefixes":        "按键前缀统计:",
+		"doctor.formats":         "存储格式:",
+		"doctor.formatSessions":  "%d 个会话",
+		"doctor.formatInvalid":   "，%d 个无效",
+		"doctor.unknown":         "无法识别的composerData: %d",
+		"doctor.bubbleKeys":      "bubbleId记录: %d",
+		"doctor.legacyChat":      "旧版本聊天面板数据: %s",
+		"doctor.yes":             "有",
+		"doctor.no":              "无",
+		"doctor.noFormats":       "警告: 没有找到任何可以识别的会话，数据库可能来自不支持的Cursor版本",
+		"doctor.noSessionTable":  "警告: 数据库中没有cursorDiskKV表，无法读取Composer会话",
+		"doctor.sessions":        "可以读取的会话: %d",
+		"doctor.noSkipped":       "没有被跳过的记录",
+		"doctor.verbose":         "使用-verbose列出每条记录",
+
+		"format.composer-headers": "composerData只保存fullConversationHeadersOnly，消息保存在bubbleId:<composerId>:<bubbleId>中 (较新的版本)",
+		"format.composer-inline":  "消息直接保存在composerData的conversation数组中 (0.43及以后的版本)",
+		"format.legacy-aichat":    "旧版本聊天面板的数据，保存在ItemTable的workbench.panel.aichat.view.aichat.chatdata中",
+
+		"usage.show":         "用法: cursor2md show <hash|hash前缀|标题> [-db <数据库路径>] [-format <格式>] [-template <模板文件>] [-messages <起始:结束>]",
+		"usage.extract-code": "用法: cursor2md extract-code <hash|hash前缀|标题> [-db <数据库路径>] [-out <输出目录>]",
+		"usage.git-link":     "用法: cursor2md git-link <仓库路径> [-db <数据库路径>] [-window <时长>] [-notes] [-json]",
+		"usage.file-history": "用法: cursor2md file-history <文件路径> [-db <数据库路径>] [-json] [-out <输出文件>]",
+		"usage.config":       "用法: cursor2md config show [<命令>] [-profile <方案>] [-json]",
+
+		"history.failed":  "查询文件历史失败: %v",
+		"history.title":   "文件讨论历史: %s",
+		"history.summary": "共 %d 处提及，涉及 %d 个会话",
+		"history.session": "会话: `%s`",
+		"history.message": "消息 #%d (%s)",
+		"history.file":    "文件: [%s](%s)",
+		"history.none":    "没有找到提及 %s 的消息",
+		"history.written": "已写入文件历史: %s (%d 处提及)",
+
+		"browse.failed":          "浏览会话失败: %v",
+		"browse.inputFailed":     "打开输入文件失败: %v",
+		"browse.size":            "无效的界面大小: %s",
+		"browse.noPager":         "未配置分页器",
+		"browse.pagerFailed":     "打开分页器失败: %v",
+		"browse.clipboardFailed": "写入剪贴板文件失败: %v",
+		"browse.exported":        "已导出 %d 个会话到 %s",
+		"browse.copied":          "已复制 %d 个会话到 %s",
+		"browse.filter":          "过滤",
+		"browse.header":          "%d/%d 个会话  已选 %d",
+		"browse.footer":          "↑/↓ 移动  / 过滤  空格 选择  a 全选  e 导出  c 复制  o/回车 分页器  q 退出",
+
+		"show.failed":         "显示会话失败: %v",
+		"show.noQuery":        "请指定会话的hash或标题",
+		"show.notFound":       "未找到匹配 %s 的会话",
+		"show.ambiguous":      "%s 匹配到 %d 个会话，请使用更精确的hash或标题:",
+		"show.range":          "无效的消息范围: %s",
+		"show.rangeTotal":     "消息范围 %s 超出会话消息数量 %d",
+		"show.rawRedacted":    "启用-redact或-anonymize时无法输出原始数据",
+		"show.rawInvalid":     "原始数据不是有效的JSON: %v",
+		"show.rawMessages":    "-format raw输出完整的原始数据，不支持-messages",
+		"show.readTemplate":   "读取模板文件失败: %v",
+		"show.parseTemplate":  "解析模板失败: %v",
+		"show.renderTemplate": "渲染模板失败: %v",
+
+		"config.read":          "读取配置文件失败: %v",
+		"config.parse":         "解析配置文件%s失败: %v",
+		"config.noProfile":     "配置方案不存在: %s",
+		"config.env":           "环境变量 %s",
+		"config.invalidValue":  "%s: %s的值无效: %v",
+		"config.line":          "第%d行: %v",
+		"config.tableHeader":   "不支持的表头: %s",
+		"config.afterHeader":   "表头之后有多余的内容: %s",
+		"config.emptyTable":    "表名不能为空: %s",
+		"config.noEquals":      "缺少=: %s",
+		"config.key":           "不支持的键: %s",
+		"config.afterValue":    "值之后有多余的内容: %s",
+		"config.noValue":       "缺少值",
+		"config.multiline":     "不支持多行字符串",
+		"config.string":        "无效的字符串 %s (Windows路径请使用单引号)",
+		"config.unterminated":  "字符串没有结束: %s",
+		"config.array":         "数组没有结束 (不支持跨行的数组): %s",
+		"config.comma":         "数组元素之间缺少逗号: %s",
+		"config.value":         "无法解析的值: %s",
+		"config.subcommand":    "未知的子命令",
+		"config.files":         "配置文件:",
+		"config.missing":       "不存在",
+		"config.profile":       "配置方案: %s",
+		"config.none":          "无",
+		"config.commandValues": "%s命令的有效配置 (命令行参数优先于以下配置):",
+		"config.values":        "有效配置 (命令行参数优先于以下配置，指定命令可以包含该命令的表):",
+
+		"git.failed":      "关联git提交失败: %v",
+		"git.log":         "读取git历史失败: %v",
+		"git.window":      "-git-window不能为负数",
+		"git.negative":    "-window和-min-overlap不能为负数",
+		"git.noRepo":      "仓库路径不存在: %s",
+		"git.notRepo":     "%s 不是git仓库",
+		"git.note":        "相关的Cursor会话:",
+		"git.notesFailed": "写入git notes失败 (%s): %s",
+		"git.none":        "没有找到与会话关联的提交",
+		"git.summary":     "%d 个会话关联到 %d 个提交",
+		"git.notes":       "已写入 %d 条git notes (ref: %s)，使用 git log --notes=%s 查看",
+
+		"mcp.failed":             "MCP服务出错: %v",
+		"mcp.parseError":         "解析JSON失败: %v",
+		"mcp.version":            "仅支持JSON-RPC 2.0",
+		"mcp.params":             "无效的参数: %v",
+		"mcp.uri":                "无效的资源URI: %s",
+		"mcp.method":             "未知的方法: %s",
+		"mcp.tool":               "未知的工具: %s",
+		"mcp.required":           "%s需要%s参数",
+		"mcp.resource":           "%s 开始的Cursor会话，共 %d 条消息",
+		"mcp.listSessions":       "列出Cursor聊天会话，返回hash、标题、开始和结束时间、消息数量",
+		"mcp.searchChats":        "在所有会话的标题、消息、引用的代码片段和代码块中搜索关键词",
+		"mcp.getSession":         "获取单个会话的完整内容，支持完整hash、唯一的hash前缀或标题",
+		"mcp.getFileDiscussions": "查找所有引用或修改过某个文件的消息，按路径后缀匹配，适用于不同机器上的不同检出位置",
+		"mcp.startAfter":         "仅包含在此时间之后开始的会话 (2006-01-02 或 2006-01-02 15:04:05)",
+		"mcp.startBefore":        "仅包含在此时间之前开始的会话",
+		"mcp.endAfter":           "仅包含在此时间之后结束的会话",
+		"mcp.endBefore":          "仅包含在此时间之前结束的会话",
+		"mcp.sort":               "排序字段: start, end, title, messages, size",
+		"mcp.sortDesc":           "是否降序排序，默认true",
+		"mcp.sessionLimit":       "最多返回的会话数量，默认50",
+		"mcp.offset":             "跳过的会话数量",
+		"mcp.query":              "搜索关键词（不区分大小写）",
+		"mcp.limit":              "最多返回的结果数量，默认%d",
+		"mcp.hash":               "会话hash、hash前缀或标题",
+		"mcp.format":             "输出格式: markdown（默认）或 json",
+		"mcp.path":               "文件路径或路径后缀，例如 src/main.go",
+
+		"anonymize.read":       "读取匿名化规则文件失败: %v",
+		"anonymize.parse":      "解析匿名化规则文件失败: %v",
+		"anonymize.pattern":    "需要literal或regex",
+		"anonymize.replace":    "第%d条替换规则无效: %v",
+		"anonymize.consistent": "第%d条一致化替换规则无效: %v",
+
+		"diff.noDir":     "目录不存在: %s",
+		"diff.notInRepo": "%s 不在git仓库中",
+		"diff.mode":      "无效的diff参数: %s (可选: replace, both)",
+		"diff.context":   "-diff-context不能为负数",
+
+		"extract.failed":         "提取代码失败: %v",
+		"extract.manifestFailed": "写入清单文件失败: %v",
+		"extract.file":           "写入文件: %s",
+		"extract.fileVersions":   "写入文件: %s (%d 个版本)",
+		"extract.snippets":       "写入代码片段: %d 个 (snippets/)",
+		"extract.done":           "从会话 %s 提取 %d 个代码块到 %s，清单: %s",
+
+		"serve.failed":    "启动服务失败: %v",
+		"serve.listening": "在 http://%s 上提供聊天记录浏览服务 (按Ctrl+C退出)",
+		"serve.param":     "无效的%s参数: %s",
+		"serve.query":     "缺少搜索关键词参数 q",
+
+		"svg.colors":  "颜色列表至少需要两个颜色: %s",
+		"svg.heatmap": "%s ~ %s 共 %d 条消息",
+		"svg.range":   "热力图的开始日期晚于结束日期",
+		"svg.write":   "写入SVG文件失败: %v",
+
+		"tokens.tokenizer":   "不支持的分词器: %s (可选: %s)",
+		"tokens.readPrices":  "读取价格表文件失败: %v",
+		"tokens.parsePrices": "解析价格表文件失败: %v",
+
+		"flag.yes":          "与-apply一起使用时不提示确认，直接写入",
+		"err.importYes":     "-json输出时无法确认，请同时指定-yes",
+		"err.cursorRunning": "Cursor正在运行，请先退出Cursor",
+		"import.confirm":    "备份后将 %d 个会话写入 %s，是否继续？[y/N] ",
+		"import.aborted":    "已取消，没有修改数据库",
+		"import.noID":       "%d 个文件没有composerId（使用-redact或-anonymize的输出），不会导入，以免每次导入都生成新的副本",
+
+		"browse.rawMode": "无法重新进入终端的原始模式: %v",
+
+		"svg.color": "无效的颜色: %s，请使用#rgb、#rrggbb或颜色名称",
+
+		"doctor.corrupt":    "数据库没有通过完整性检查",
+		"doctor.unreadable": "%d 条记录无法读取",
+	},
+
+	"en": {
+		"help": `Usage:
+  cursor2md ls [-db <db path>] [-json] [-sort <field>] [-sort-desc] [-limit <N>] [-offset <N>] [-columns <columns>] [-start-after <time>] [-start-before <time>] [-end-after <time>] [-end-before <time>]  list sessions
+  cursor2md export [<hash>] [-db <db path>] [-out <output dir>] [-byname]  export the session with the given hash
+  cursor2md export [-db <db path>] [-out <output dir>] [-sort-desc] [-byname] [-strict] [-start-after <time>] [-start-before <time>] [-end-after <time>] [-end-before <time>]  export sessions
+  cursor2md show <hash|hash prefix|title> [-db <db path>] [-format <format>] [-template <template file>] [-messages <start:end>]  print a session to standard output
+  cursor2md browse [-db <db path>] [-out <output dir>]  browse and export sessions interactively in the terminal
+  cursor2md serve [-db <db path>] [-addr <listen address>]  start the local web UI and REST API
+  cursor2md mcp [-db <db path>]  serve MCP over stdio so other AI agents can query the chat history
+  cursor2md stats [-db <db path>] [-format text|json|csv] [-by day|week|month] [-top <N>] [-svg <file>] [-start-after <time>] ...  usage statistics
+  cursor2md extract-code <hash|hash prefix|title> [-db <db path>] [-out <output dir>] [-json]  write the code blocks of AI replies to a file tree
+  cursor2md git-link <repo path> [-db <db path>] [-window <duration>] [-min-overlap <N>] [-notes] [-json]  link sessions to git commits by time and files
+  cursor2md file-history <file path> [-db <db path>] [-json] [-out <output file>]  list every message mentioning the file in chronological order
+  cursor2md doctor [-db <db path> | -archive <archive file>] [-json] [-verbose]  check database integrity, storage formats and skipped records
+  cursor2md backup [-db <db path>] [-archive <archive file>] [-history <hash>] [-json]  back up raw session data into a versioned archive
+  cursor2md import -from <archive|db|JSON file|dir> [-db <target db>] [-conflict skip|overwrite|rename] [-apply [-yes]] [-json]  preview or write sessions into a Cursor database
+  cursor2md config show [<command>] [-profile <profile>] [-json]  show the effective settings from config files and environment variables
+  cursor2md version  show version information
+  cursor2md help  show this help
+
+Databases (all commands except doctor):
+  -db          may be repeated or point to a directory of database copies; sessions are merged and only the most complete or latest version of each is kept
+  -archive     read sessions from an archive created by backup; may be combined with -db and repeated
+
+Sorting:
+                use -sort-desc=false to sort in ascending order (oldest first)
+  -byname      prefix file names with a sequence number (e.g. 001-name.md)
+
+Redaction:
+  -redact      replace keys, tokens, private keys, passwords in URLs and high-entropy strings before output (on by default for serve, mcp and show -format html)
+  -anonymize   rewrite paths, user names, emails, IPs and other personal data using a rules file
+
+Diagnostics (ls, export, doctor):
+  -verbose     list the key and reason of every skipped record instead of counts per reason
+  -strict      (export) exit with a non-zero status when a session cannot be parsed or written
+
+Configuration (all commands):
+  -profile     use the settings of the [profiles.<profile>] table in the config file
+               config files: $XDG_CONFIG_HOME/cursor2md/config.toml and .cursor2md.toml in the current directory
+               precedence from low to high: top level < [command] < [profiles.profile] < [profiles.profile.command] < CURSOR2MD_* environment variables < command line flags
+  -lang        language of messages and Markdown labels: zh or en
+               when omitted, the config files and CURSOR2MD_LANG, LC_ALL, LC_MESSAGES and LANG are checked in turn; the default is zh
+
+Exit status:
+  0 success  1 other error  2 invalid-arg  3 db-not-found  4 db-locked  5 parse-error  6 not-found  7 write-error
+
+Code diffs (export, show):
+  -diff        compare code blocks with the files on disk: replace (diff only) or both (code block and diff)
+  -diff-rev    compare with the given git revision instead of the current file on disk
+
+Git links (export):
+  -git-repo    link commits in the repository that touch files referenced by the session, made during the session or within -git-window after it
+
+Token estimates (ls, export, show, stats):
+  -tokenizer   tokenizer used for estimates: approx (approximate BPE, default) or chars (one token per 4 characters)
+  -prices      model price table file (JSON), in USD per million tokens
+  -model       model used to estimate the cost of sessions that did not record one
+  -front-matter (export, show) start the Markdown with YAML front matter including token estimates; cost is included when the session recorded a model or -model/-prices is given
+`,
+
+		"flag.db":                  "database file or directory of database copies; repeat to merge several databases (default: system default path)",
+		"flag.db.doctor":           "database file path (default: system default path)",
+		"flag.archive":             "read sessions from an archive created by backup; repeatable, may be combined with -db",
+		"flag.archive.backup":      "archive file path, created if it does not exist",
+		"flag.archive.doctor":      "check an archive created by backup instead of a database",
+		"flag.history":             "list all archived versions of sessions whose ID starts with this value instead of backing up",
+		"flag.from":                "archive, database, JSON file from show -format json/raw or directory of JSON files to import; repeatable",
+		"flag.db.import":           "target database to write to (default: system default path)",
+		"flag.conflict":            "what to do when the target already has the session (skip, overwrite, rename)",
+		"flag.apply":               "back up the target database and write; without it only a preview is shown",
+		"flag.json":                "output as JSON",
+		"flag.json.stats":          "output as JSON (same as -format json)",
+		"flag.json.git-link":       "output the link report as JSON",
+		"flag.out":                 "markdown output directory",
+		"flag.out.extract-code":    "code output directory",
+		"flag.out.file-history":    "write the report to a file instead of standard output",
+		"flag.sort":                "sort field (start, end, title, messages, size)",
+		"flag.sort-desc":           "sort in descending order",
+		"flag.sort-desc.export":    "sort by time in descending order (newest first)",
+		"flag.limit":               "maximum number of sessions to show (0 means no limit)",
+		"flag.offset":              "skip the first N sessions",
+		"flag.columns":             "columns to output, comma separated (available: %s)",
+		"flag.front-matter":        "start the Markdown with a YAML front matter block containing session details and token estimates",
+		"flag.start-after":         "only include sessions started after this time (format: 2006-01-02 or 2006-01-02 15:04:05)",
+		"flag.start-before":        "only include sessions started before this time (format: 2006-01-02 or 2006-01-02 15:04:05)",
+		"flag.end-after":           "only include sessions ended after this time (format: 2006-01-02 or 2006-01-02 15:04:05)",
+		"flag.end-before":          "only include sessions ended before this time (format: 2006-01-02 or 2006-01-02 15:04:05)",
+		"flag.byname":              "prefix file names with a sequence number",
+		"flag.verbose":             "list every skipped record with its reason",
+		"flag.strict":              "fail when a session cannot be parsed or written",
+		"flag.strict.single":       "fail when the session cannot be parsed in any database or cannot be written",
+		"flag.redact":              "replace keys, tokens and other secrets in messages, snippets and code blocks",
+		"flag.redact.show":         "replace keys, tokens and other secrets (on by default for -format html)",
+		"flag.redact.mcp":          "replace keys, tokens and other secrets (use -redact=false to disable)",
+		"flag.redact.browse":       "replace keys, tokens and other secrets when previewing, exporting and copying",
+		"flag.redact.extract-code": "replace keys, tokens and other secrets in code blocks",
+		"flag.anonymize":           "anonymization rules file (JSON) for rewriting paths, emails, IPs and other personal data",
+		"flag.anonymize.ls":        "anonymization rules file (JSON) for rewriting titles and workspace paths",
+		"flag.profile":             "use a profile from the config file (can also be set with CURSOR2MD_PROFILE)",
+		"flag.profile.config":      "show the settings used with this profile",
+		"flag.lang":                "language of messages and Markdown labels (zh, en)",
+		"flag.diff":                "compare code blocks with the files on disk and show the differences: replace (diff only) or both (code block and diff)",
+		"flag.diff-rev":            "compare with the given git revision (e.g. HEAD~1) instead of the current file on disk",
+		"flag.diff-context":        "number of context lines in diffs",
+		"flag.git-repo":            "link commits from this git repository and add a \"Related commits\" section to the Markdown",
+		"flag.git-window":          "how long after a session ends commits can still be linked to it",
+		"flag.window":              "how long after a session ends commits can still be linked to it",
+		"flag.min-overlap":         "minimum number of files in a commit that the session references (0 links by time only)",
+		"flag.notes":               "write the linked sessions to git notes",
+		"flag.notes-ref":           "ref used for git notes",
+		"flag.tokenizer":           "tokenizer used to estimate token counts (%s)",
+		"flag.prices":              "model price table file (JSON) in USD per million tokens, overriding the built-in prices",
+		"flag.model":               "model used to estimate the cost of sessions that did not record one (default: %s)",
+		"flag.format":              "output format (%s)",
+		"flag.format.stats":        "output format (text, json, csv)",
+		"flag.template":            "render the session with a text/template file; unparsed fields are available via .Field \"name\"",
+		"flag.messages":            "only output the given range of messages, e.g. 3:7, 3:, :7",
+		"flag.by":                  "period used to group the text output (day, week, month)",
+		"flag.top":                 "number of files and directories in the rankings",
+		"flag.svg":                 "write the activity heatmap and language and file bar charts to an SVG file",
+		"flag.svg-from":            "start date of the heatmap (default: one year before the end date)",
+		"flag.svg-to":              "end date of the heatmap (default: date of the last session)",
+		"flag.svg-colors":          "heatmap colors (#rgb, #rrggbb or color names) from no activity to most active, comma separated",
+		"flag.addr":                "listen address",
+		"flag.clipboard":           "file written by the copy action",
+		"flag.input":               "read key presses from a file instead of the terminal (for scripts and tests)",
+		"flag.size":                "screen size as WIDTHxHEIGHT (default: terminal size)",
+
+		"err.homeDir":           "failed to get the user home directory: %v",
+		"err.unsupportedOS":     "unsupported operating system: %s",
+		"err.defaultDB":         "cannot determine the default database path",
+		"err.dbNotExist":        "database file does not exist: %s",
+		"err.dbNotInDir":        "no .vscdb database files in directory: %s",
+		"err.notArchive":        "not a cursor2md archive: %s",
+		"err.defaultArchive":    "cannot determine the default archive path, use -archive",
+		"err.noArchiveVersions": "no sessions with ID starting with %s in the archive",
+		"err.importFrom":        "use -from to specify the archive, database or JSON files to import",
+		"err.conflict":          "unsupported conflict mode: %s (supported: %s)",
+		"err.importArchive":     "cannot import into an archive, specify a Cursor database: %s",
+		"err.timeFormat":        "invalid time format: %s",
+		"err.parseFlag":         "failed to parse %s: %v",
+		"err.column":            "invalid column: %s (available: %s)",
+		"err.sortField":         "invalid sort field: %s (available: start, end, title, messages, size)",
+		"err.negative":          "-limit and -offset must not be negative",
+		"err.json":              "failed to encode JSON: %v",
+		"err.mkdirOut":          "failed to create the output directory: %v",
+		"err.writeMarkdown":     "failed to write the markdown file: %v",
+		"err.sessionHash":       "no session with hash %s",
+		"err.config":            "failed to read the configuration: %v",
+		"err.lang":              "unsupported language: %s (available: %s)",
+		"err.writeFile":         "failed to write the file: %v",
+		"err.mkdir":             "failed to create the directory: %v",
+
+		"column.hash":      "HASH",
+		"column.start":     "START",
+		"column.end":       "END",
+		"column.title":     "TITLE",
+		"column.messages":  "MESSAGES",
+		"column.size":      "SIZE",
+		"column.workspace": "WORKSPACE",
+		"column.source":    "SOURCE",
+		"column.tokens":    "TOKENS",
+		"column.cost":      "COST",
+		"column.period":    "PERIOD",
+		"column.sessions":  "SESSIONS",
+		"column.input":     "INPUT",
+		"column.output":    "OUTPUT",
+		"column.total":     "TOTAL",
+		"column.name":      "NAME",
+		"column.session":   "SESSION",
+		"column.version":   "VERSION",
+		"column.archived":  "ARCHIVED",
+		"column.rows":      "ROWS",
+		"column.checksum":  "CHECKSUM",
+		"column.action":    "ACTION",
+
+		"ls.failed":   "failed to list sessions",
+		"ls.notEnded": "ongoing",
+		"ls.empty":    "no valid sessions in the database",
+		"ls.shown":    "showing %d of %d sessions",
+		"ls.total":    "%d sessions in total",
+		"skipped":     "skipped %d records: %s",
+
+		"export.failed":      "failed to export sessions",
+		"backup.failed":      "backup failed: %v",
+		"backup.archive":     "archive: %s",
+		"backup.report":      "%s: %d sessions, %d new, %d updated, %d unchanged",
+		"backup.shared":      "  %d new versions of shared records",
+		"import.failed":      "import failed: %v",
+		"import.target":      "target database: %s",
+		"import.summary":     "%d new, %d overwritten, %d renamed, %d skipped",
+		"import.preview":     "this is a preview, the database was not modified. Quit Cursor and use -apply to write",
+		"import.nothing":     "nothing to write",
+		"import.backup":      "backed up the target database to %s",
+		"import.done":        "wrote %d sessions, restart Cursor to see them",
+		"export.failedCount": "%d sessions failed to export",
+		"export.session":     "exported session: %s (started: %s)",
+		"export.redacted":    "secrets replaced: %s",
+		"export.done":        "exported %d sessions to %s",
+		"export.single":      "exported session: %s",
+		"export.complete":    "Export complete!",
+
+		"md.sessionInfo":    "Session info",
+		"md.startTime":      "Started",
+		"md.endTime":        "Ended",
+		"md.tokens":         "Estimated tokens",
+		"md.tokenUsage":     "input %d / output %d / total %d (%s, about %s)",
+		"md.tokenCounts":    "input %d / output %d / total %d",
+		"md.files":          "Related files",
+		"md.fileSelections": "Referenced files",
+		"md.selections":     "Referenced snippets",
+		"md.codeBlock":      "Code block",
+		"md.selectionFrom":  "From [%s](%s):",
+		"md.unchanged":      "[%s](%s) is unchanged",
+		"md.sameAsFile":     "unchanged from the file",
+		"md.appliedChanges": "Applied changes",
+		"md.unknownFile":    "(unknown file)",
+		"md.changeSource":   "Source: %s",
+		"md.changeHunks":    ", %d hunks",
+		"md.changeNote":     ", %s",
+		"md.accepted":       "accepted",
+		"md.rejected":       "rejected",
+		"md.pending":        "pending",
+		"md.relatedCommits": "Related commits",
+
+		"note.checkpoint":  "checkpoint records the content before the change",
+		"note.new-file":    "new file",
+		"note.not-existed": "file did not exist before the change",
+
+		"err.format": "unsupported output format: %s (available: %s)",
+
+		"stats.sessions":       "Sessions",
+		"stats.messages":       "Messages",
+		"stats.messageCounts":  "%d (user %d / AI %d)",
+		"stats.avgMessages":    "Messages per session",
+		"stats.range":          "Time range",
+		"stats.latency":        "AI response time",
+		"stats.latencyValues":  "average %s, median %s, P90 %s, max %s (%d replies)",
+		"stats.tokenValues":    "input %d / output %d / total %d, estimated cost %s",
+		"stats.monthly":        "By month",
+		"stats.weekly":         "By week",
+		"stats.daily":          "By day",
+		"stats.tokenProjects":  "Estimated tokens by project",
+		"stats.tokenModels":    "Estimated tokens by model",
+		"stats.topFiles":       "Most referenced files",
+		"stats.topDirectories": "Most referenced directories",
+		"stats.languages":      "Code block languages",
+		"stats.hours":          "Messages by hour",
+		"stats.csv":            "failed to write CSV: %v",
+		"stats.by":             "invalid grouping: %s (available: day, week, month)",
+		"stats.svg":            "SVG chart written to %s",
+
+		"doctor.failed":          "failed to check the database: %v",
+		"doctor.dbAndArchive":    "-db and -archive cannot be used together",
+		"doctor.db":              "database: %s",
+		"doctor.integrity":       "integrity check: %s",
+		"doctor.integrityFailed": "failed",
+		"doctor.tables":          "tables:",
+		"doctor.rows":            "%d rows",
+		"doctor.missing":         "missing",
+		"doctor.prefixes":        "rows by key prefix:",
+		"doctor.formats":         "storage formats:",
+		"doctor.formatSessions":  "%d sessions",
+		"doctor.formatInvalid":   ", %d invalid",
+		"doctor.unknown":         "unrecognized composerData: %d",
+		"doctor.bubbleKeys":      "bubbleId records: %d",
+		"doctor.legacyChat":      "legacy chat panel data: %s",
+		"doctor.yes":             "yes",
+		"doctor.no":              "no",
+		"doctor.noFormats":       "warning: no recognizable sessions found, the database may come from an unsupported Cursor version",
+		"doctor.noSessionTable":  "warning: the database has no cursorDiskKV table, Composer sessions cannot be read",
+		"doctor.sessions":        "readable sessions: %d",
+		"doctor.noSkipped":       "no skipped records",
+		"doctor.verbose":         "use -verbose to list every record",
+
+		"format.composer-headers": "composerData only keeps fullConversationHeadersOnly, messages are stored under bubbleId:<composerId>:<bubbleId> (newer versions)",
+		"format.composer-inline":  "messages are stored inline in the conversation array of composerData (0.43 and later)",
+		"format.legacy-aichat":    "legacy chat panel data stored in ItemTable under workbench.panel.aichat.view.aichat.chatdata",
+
+		"usage.show":         "usage: cursor2md show <hash|hash prefix|title> [-db <db path>] [-format <format>] [-template <template file>] [-messages <start:end>]",
+		"usage.extract-code": "usage: cursor2md extract-code <hash|hash prefix|title> [-db <db path>] [-out <output dir>]",
+		"usage.git-link":     "usage: cursor2md git-link <repo path> [-db <db path>] [-window <duration>] [-notes] [-json]",
+		"usage.file-history": "usage: cursor2md file-history <file path> [-db <db path>] [-json] [-out <output file>]",
+		"usage.config":       "usage: cursor2md config show [<command>] [-profile <profile>] [-json]",
+
+		"history.failed":  "failed to query the file history: %v",
+		"history.title":   "File discussion history: %s",
+		"history.summary": "%d mentions in %d sessions",
+		"history.session": "Session: `%s`",
+		"history.message": "message #%d (%s)",
+		"history.file":    "File: [%s](%s)",
+		"history.none":    "no messages mention %s",
+		"history.written": "file history written to %s (%d mentions)",
+
+		"browse.failed":          "failed to browse sessions: %v",
+		"browse.inputFailed":     "failed to open the input file: %v",
+		"browse.size":            "invalid screen size: %s",
+		"browse.noPager":         "no pager configured",
+		"browse.pagerFailed":     "failed to open the pager: %v",
+		"browse.clipboardFailed": "failed to write the clipboard file: %v",
+		"browse.exported":        "exported %d sessions to %s",
+		"browse.copied":          "copied %d sessions to %s",
+		"browse.filter":          "filter",
+		"browse.header":          "%d/%d sessions  %d selected",
+		"browse.footer":          "↑/↓ move  / filter  space select  a all  e export  c copy  o/enter pager  q quit",
+
+		"show.failed":         "failed to show the session: %v",
+		"show.noQuery":        "specify the hash or title of a session",
+		"show.notFound":       "no session matches %s",
+		"show.ambiguous":      "%s matches %d sessions, use a more specific hash or title:",
+		"show.range":          "invalid message range: %s",
+		"show.rangeTotal":     "message range %s exceeds the %d messages of the session",
+		"show.rawRedacted":    "raw data cannot be output with -redact or -anonymize",
+		"show.rawInvalid":     "raw data is not valid JSON: %v",
+		"show.rawMessages":    "-format raw outputs the complete raw data and does not support -messages",
+		"show.readTemplate":   "failed to read the template file: %v",
+		"show.parseTemplate":  "failed to parse the template: %v",
+		"show.renderTemplate": "failed to render the template: %v",
+
+		"config.read":          "failed to read the config file: %v",
+		"config.parse":         "failed to parse the config file %s: %v",
+		"config.noProfile":     "profile does not exist: %s",
+		"config.env":           "environment variable %s",
+		"config.invalidValue":  "%s: invalid value for %s: %v",
+		"config.line":          "line %d: %v",
+		"config.tableHeader":   "unsupported table header: %s",
+		"config.afterHeader":   "unexpected content after the table header: %s",
+		"config.emptyTable":    "empty table name: %s",
+		"config.noEquals":      "missing =: %s",
+		"config.key":           "unsupported key: %s",
+		"config.afterValue":    "unexpected content after the value: %s",
+		"config.noValue":       "missing value",
+		"config.multiline":     "multi-line strings are not supported",
+		"config.string":        "invalid string %s (use single quotes for Windows paths)",
+		"config.unterminated":  "unterminated string: %s",
+		"config.array":         "unterminated array (arrays spanning lines are not supported): %s",
+		"config.comma":         "missing comma between array elements: %s",
+		"config.value":         "cannot parse value: %s",
+		"config.subcommand":    "unknown subcommand",
+		"config.files":         "config files:",
+		"config.missing":       "missing",
+		"config.profile":       "profile: %s",
+		"config.none":          "none",
+		"config.commandValues": "effective settings of the %s command (command line flags take precedence):",
+		"config.values":        "effective settings (command line flags take precedence; specify a command to include its tables):",
+
+		"git.failed":      "failed to link git commits: %v",
+		"git.log":         "failed to read the git history: %v",
+		"git.window":      "-git-window must not be negative",
+		"git.negative":    "-window and -min-overlap must not be negative",
+		"git.noRepo":      "repository path does not exist: %s",
+		"git.notRepo":     "%s is not a git repository",
+		"git.note":        "Related Cursor sessions:",
+		"git.notesFailed": "failed to write git notes (%s): %s",
+		"git.none":        "no commits linked to sessions",
+		"git.summary":     "%d sessions linked to %d commits",
+		"git.notes":       "wrote %d git notes (ref: %s), view them with git log --notes=%s",
+
+		"mcp.failed":             "MCP server error: %v",
+		"mcp.parseError":         "failed to parse JSON: %v",
+		"mcp.version":            "only JSON-RPC 2.0 is supported",
+		"mcp.params":             "invalid params: %v",
+		"mcp.uri":                "invalid resource URI: %s",
+		"mcp.method":             "unknown method: %s",
+		"mcp.tool":               "unknown tool: %s",
+		"mcp.required":           "%s requires the %s argument",
+		"mcp.resource":           "Cursor session started %s, %d messages",
+		"mcp.listSessions":       "list Cursor chat sessions with their hash, title, start and end time and message count",
+		"mcp.searchChats":        "search the titles, messages, referenced snippets and code blocks of all sessions for a keyword",
+		"mcp.getSession":         "get the full content of one session by full hash, unique hash prefix or title",
+		"mcp.getFileDiscussions": "find every message that referenced or changed a file, matched by path suffix so checkouts in different locations work",
+		"mcp.startAfter":         "only sessions started after this time (2006-01-02 or 2006-01-02 15:04:05)",
+		"mcp.startBefore":        "only sessions started before this time",
+		"mcp.endAfter":           "only sessions ended after this time",
+		"mcp.endBefore":          "only sessions ended before this time",
+		"mcp.sort":               "sort field: start, end, title, messages, size",
+		"mcp.sortDesc":           "sort in descending order, default true",
+		"mcp.sessionLimit":       "maximum number of sessions to return, default 50",
+		"mcp.offset":             "number of sessions to skip",
+		"mcp.query":              "keyword to search for (case insensitive)",
+		"mcp.limit":              "maximum number of results to return, default %d",
+		"mcp.hash":               "session hash, hash prefix or title",
+		"mcp.format":             "output format: markdown (default) or json",
+		"mcp.path":               "file path or path suffix, e.g. src/main.go",
+
+		"anonymize.read":       "failed to read the anonymization rules file: %v",
+		"anonymize.parse":      "failed to parse the anonymization rules file: %v",
+		"anonymize.pattern":    "literal or regex is required",
+		"anonymize.replace":    "replacement rule %d is invalid: %v",
+		"anonymize.consistent": "consistent replacement rule %d is invalid: %v",
+
+		"diff.noDir":     "directory does not exist: %s",
+		"diff.notInRepo": "%s is not in a git repository",
+		"diff.mode":      "invalid -diff value: %s (available: replace, both)",
+		"diff.context":   "-diff-context must not be negative",
+
+		"extract.failed":         "failed to extract code: %v",
+		"extract.manifestFailed": "failed to write the manifest: %v",
+		"extract.file":           "wrote file: %s",
+		"extract.fileVersions":   "wrote file: %s (%d versions)",
+		"extract.snippets":       "wrote %d snippets (snippets/)",
+		"extract.done":           "extracted %[2]d code blocks from session %[1]s to %[3]s, manifest: %[4]s",
+
+		"serve.failed":    "failed to start the server: %v",
+		"serve.listening": "serving chat history on http://%s (press Ctrl+C to quit)",
+		"serve.param":     "invalid %s parameter: %s",
+		"serve.query":     "missing search parameter q",
+
+		"svg.colors":  "the color list needs at least two colors: %s",
+		"svg.heatmap": "%s ~ %s, %d messages",
+		"svg.range":   "the heatmap start date is after the end date",
+		"svg.write":   "failed to write the SVG file: %v",
+
+		"tokens.tokenizer":   "unsupported tokenizer: %s (available: %s)",
+		"tokens.readPrices":  "failed to read the price table file: %v",
+		"tokens.parsePrices": "failed to parse the price table file: %v",
+
+		"flag.yes":          "with -apply, write without asking for confirmation",
+		"err.importYes":     "cannot ask for confirmation with -json, add -yes",
+		"err.cursorRunning": "Cursor is running, quit it first",
+		"import.confirm":    "write %d sessions into %s after a backup? [y/N] ",
+		"import.aborted":    "cancelled, the database was not modified",
+		"import.noID":       "%d files have no composerId (output of -redact or -anonymize) and are not imported, since each import would create another copy",
+
+		"browse.rawMode": "cannot re-enter raw terminal mode: %v",
+
+		"svg.color": "invalid color: %s, use #rgb, #rrggbb or a color name",
+
+		"doctor.corrupt":    "the database failed the integrity check",
+		"doctor.unreadable": "%d records could not be read",
+	},
+}
+
+// 返回当前语言中的消息，有参数时按fmt的格式格式化
+// 当前语言缺少该消息时使用默认语言，都没有时返回消息ID
+func tr(key string, args ...any) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		msg, ok = catalogs[defaultLang][key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// 将zh_CN.UTF-8、en-US等语言标识转换为支持的语言，不支持时返回false
+func normalizeLang(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, l := range languages {
+		if s == l || strings.HasPrefix(s, l+"_") || strings.HasPrefix(s, l+"-") || strings.HasPrefix(s, l+".") {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// 在注册命令行参数之前确定界面语言，使参数说明也使用该语言
+// 优先级: -lang参数 > 配置文件和CURSOR2MD_LANG > LC_ALL > LC_MESSAGES > LANG
+// 无效的-lang和配置由parseFlags报告，这里只忽略
+func initLang(args []string) {
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if v, ok := flagValue(args, "lang"); ok {
+		if l, ok := normalizeLang(v); ok {
+			lang = l
+		}
+		return
+	}
+	profile, _ := flagValue(args, "profile")
+	if cfg, err := resolveConfig(command, profile); err == nil {
+		for _, v := range cfg.Values {
+			if v.Key != "lang" {
+				continue
+			}
+			if l, ok := normalizeLang(v.Value); ok {
+				lang = l
+			}
+			return
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		// 系统语言不支持时仍然使用默认语言，例如LANG=C
+		if l, ok := normalizeLang(v); ok {
+			lang = l
+		}
+		return
+	}
+}
+
+// 在flag包解析之前从参数中查找-name或--name的值，遇到--时停止
+func flagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		key, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if key != name {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 消息中的fmt动词，可以带有[n]形式的参数序号
+var formatVerb = regexp.MustCompile(`%(\[(\d+)\])?[-+# 0]*\d*(\.\d+)?([a-zA-Z%])`)
+
+// 返回消息中每个参数使用的动词，参数从1开始编号
+func formatArgs(msg string) map[int]string {
+	args := make(map[int]string)
+	next := 1
+	for _, m := range formatVerb.FindAllStringSubmatch(msg, -1) {
+		if m[4] == "%" {
+			continue
+		}
+		if m[2] != "" {
+			next, _ = strconv.Atoi(m[2])
+		}
+		args[next] = m[4]
+		next++
+	}
+	return args
+}
+
+// 每个目录的键都必须出现在其他目录中，且参数的数量和类型一致
+func TestCatalogParity(t *testing.T) {
+	for _, l := range languages {
+		if catalogs[l] == nil {
+			t.Fatalf("no catalog for %s", l)
+		}
+	}
+	for _, a := range languages {
+		for key, msg := range catalogs[a] {
+			for _, b := range languages {
+				other, ok := catalogs[b][key]
+				if !ok {
+					t.Errorf("%s: missing in %s", key, b)
+					continue
+				}
+				want, got := formatArgs(msg), formatArgs(other)
+				if len(want) != len(got) {
+					t.Errorf("%s: %s has %d arguments, %s has %d", key, a, len(want), b, len(got))
+					continue
+				}
+				for i, verb := range want {
+					if got[i] != verb {
+						t.Errorf("%s: argument %d is %%%s in %s but %%%s in %s", key, i, verb, a, got[i], b)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 源代码中以字面量调用tr的键和按前缀拼接的键都必须存在于每个目录中
+func TestCatalogKeysUsed(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]string)
+	fset := token.NewFileSet()
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "tr" {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				key, _ := strconv.Unquote(lit.Value)
+				keys[key] = fset.Position(lit.Pos()).String()
+			}
+			return true
+		})
+	}
+	if len(keys) == 0 {
+		t.Fatal("no tr calls found")
+	}
+
+	for _, key := range changeStatusLabels {
+		keys[key] = "changeStatusLabels"
+	}
+	for _, key := range mentionSourceLabels {
+		keys[key] = "mentionSourceLabels"
+	}
+	for _, note := range []string{store.NoteCheckpoint, store.NoteNewFile, store.NoteNotExisted} {
+		keys["note."+note] = "store.Note*"
+	}
+	for _, a := range store.Adapters() {
+		keys["format."+a.Name] = "store adapters"
+	}
+
+	for key, pos := range keys {
+		for _, l := range languages {
+			if _, ok := catalogs[l][key]; !ok {
+				t.Errorf("%s: key %q missing in %s", pos, key, l)
+			}
+		}
+	}
+}
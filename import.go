@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+type ImportResponse struct {
+	Target  string             `json:"target"`
+	Items   []store.ImportItem `json:"items"`
+	Applied bool               `json:"applied"`
+	Backup  string             `json:"backup,omitempty"` // 写入前备份的目标数据库副本
+	Success bool               `json:"success"`
+	Error   *string            `json:"error,omitempty"`
+	Code    string             `json:"code,omitempty"`
+}
+
+// SQLite数据库文件的文件头，用于区分数据库（包括归档）和JSON文件
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// 读取-from指定的所有会话
+// 数据库和归档读取其中所有会话的原始记录，JSON文件为show -format json或-format raw的输出，目录中读取所有.json文件
+func loadImportSources(ctx context.Context, paths []string) ([]store.ImportSession, error) {
+	var sessions []store.ImportSession
+	readFile := func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return withCode(codeInvalidArg, err)
+		}
+		if !bytes.HasPrefix(data, sqliteHeader) {
+			session, err := store.ParseImportJSON(data, path)
+			if err != nil {
+				return withCode(codeParseError, fmt.Errorf("%s: %w", path, err))
+			}
+			sessions = append(sessions, session)
+			return nil
+		}
+		db, err := store.Open(path, store.Options{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		found, err := db.ImportSessions(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		sessions = append(sessions, found...)
+		return nil
+	}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			return nil, withCode(codeDBNotFound, errors.New(tr("err.dbNotExist", p)))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := readFile(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+				return nil
+			}
+			return readFile(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// 写入前备份目标数据库的路径: <数据库>.bak-<时间>，已存在时添加序号
+func backupPath(dbPath string, now time.Time) string {
+	base := fmt.Sprintf("%s.bak-%s", dbPath, now.Format("20060102-150405"))
+	path := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// 检查是否有正在运行的Cursor进程，无法检查时返回false
+// Linux读取/proc中的进程名，macOS使用pgrep，Windows使用tasklist
+func cursorRunning() bool {
+	switch runtime.GOOS {
+	case "linux":
+		names, _ := filepath.Glob("/proc/[0-9]*/comm")
+		for _, name := range names {
+			comm, err := os.ReadFile(name)
+			if err == nil && strings.EqualFold(strings.TrimSpace(string(comm)), "cursor") {
+				return true
+			}
+		}
+	case "darwin":
+		return exec.Command("pgrep", "-x", "Cursor").Run() == nil
+	case "windows":
+		out, err := exec.Command("tasklist", "/FI", "IMAGENAME eq Cursor.exe", "/NH").Output()
+		return err == nil && bytes.Contains(bytes.ToLower(out), []byte("cursor.exe"))
+	}
+	return false
+}
+
+// 提示用户确认写入，只有输入y或yes时返回true
+func confirmImport(in io.Reader, writes int, dbPath string) bool {
+	fmt.Print(tr("import.confirm", writes, dbPath))
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// 按操作统计导入计划
+func countImportActions(items []store.ImportItem) map[string]int {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.Action]++
+	}
+	return counts
+}
+
+// import命令入口
+func runImport(args []string) {
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	var from dbPaths
+	importCmd.Var(&from, "from", tr("flag.from"))
+	dbPath := importCmd.String("db", "", tr("flag.db.import"))
+	conflict := importCmd.String("conflict", string(store.ConflictSkip), tr("flag.conflict"))
+	apply := importCmd.Bool("apply", false, tr("flag.apply"))
+	yes := importCmd.Bool("yes", false, tr("flag.yes"))
+	jsonOutput := importCmd.Bool("json", false, tr("flag.json"))
+	parseFlags(importCmd, args)
+
+	response := ImportResponse{Target: *dbPath, Items: []store.ImportItem{}}
+	fail := func(err error) {
+		code := failed(err)
+		if *jsonOutput {
+			errMsg := err.Error()
+			response.Error = &errMsg
+			response.Code = code
+			jsonData, _ := json.MarshalIndent(response, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+		fmt.Println(tr("import.failed", err))
+	}
+
+	if len(from) == 0 {
+		fail(withCode(codeInvalidArg, errors.New(tr("err.importFrom"))))
+		return
+	}
+	mode := store.ConflictMode(*conflict)
+	valid := false
+	var names []string
+	for _, m := range store.ConflictModes {
+		valid = valid || m == mode
+		names = append(names, string(m))
+	}
+	if !valid {
+		fail(withCode(codeInvalidArg, errors.New(tr("err.conflict", *conflict, strings.Join(names, ", ")))))
+		return
+	}
+	// JSON输出时无法提示确认
+	if *apply && *jsonOutput && !*yes {
+		fail(withCode(codeInvalidArg, errors.New(tr("err.importYes"))))
+		return
+	}
+	if *dbPath == "" {
+		*dbPath = getDefaultDBPath()
+		response.Target = *dbPath
+		if *dbPath == "" {
+			fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+			return
+		}
+	}
+	// 归档中的cursorDiskKV是视图，不能写入
+	if ok, err := store.IsArchive(*dbPath); err != nil {
+		fail(err)
+		return
+	} else if ok {
+		fail(withCode(codeInvalidArg, errors.New(tr("err.importArchive", *dbPath))))
+		return
+	}
+
+	ctx := context.Background()
+	sessions, err := loadImportSources(ctx, from)
+	if err != nil {
+		fail(err)
+		return
+	}
+	// 空闲的Cursor不锁定数据库，只能通过进程和数据库旁的文件判断；预览时也检查，避免确认计划后才发现无法写入
+	if cursorRunning() {
+		fail(withCode(codeDBLocked, errors.New(tr("err.cursorRunning"))))
+		return
+	}
+	if err := store.CheckInUse(*dbPath); err != nil {
+		fail(err)
+		return
+	}
+	db, err := store.Open(*dbPath, store.Options{})
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+
+	if err := db.CheckLocked(ctx); err != nil {
+		fail(err)
+		return
+	}
+	response.Items, err = db.PlanImport(ctx, sessions, mode)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	counts := countImportActions(response.Items)
+	writes := counts[store.ImportAdd] + counts[store.ImportOverwrite] + counts[store.ImportRename]
+	if !*jsonOutput {
+		printImportPlan(*dbPath, response.Items, counts)
+	}
+	if *apply && writes > 0 {
+		if !*yes && !confirmImport(os.Stdin, writes, *dbPath) {
+			fmt.Println(tr("import.aborted"))
+			return
+		}
+		response.Backup = backupPath(*dbPath, time.Now())
+		if err := db.BackupTo(ctx, response.Backup); err != nil {
+			response.Backup = ""
+			fail(err)
+			return
+		}
+		if err := db.Import(ctx, sessions, response.Items); err != nil {
+			fail(err)
+			return
+		}
+		response.Applied = true
+	}
+
+	response.Success = true
+	if *jsonOutput {
+		jsonData, _ := json.MarshalIndent(response, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	switch {
+	case response.Applied:
+		fmt.Println(tr("import.backup", response.Backup))
+		fmt.Println(tr("import.done", writes))
+	case writes == 0:
+		fmt.Println(tr("import.nothing"))
+	default:
+		fmt.Println(tr("import.preview"))
+	}
+}
+
+// 输出导入计划和各操作的数量
+func printImportPlan(dbPath string, items []store.ImportItem, counts map[string]int) {
+	fmt.Println(tr("import.target", dbPath))
+	fmt.Println()
+	fmt.Printf("%-9s  %-36s  %5s  %s\n", tr("column.action"), tr("column.hash"), tr("column.rows"), tr("column.title"))
+	for _, item := range items {
+		hash := item.Hash
+		switch {
+		case item.NewHash != "":
+			hash = item.NewHash
+		case hash == "":
+			hash = "-"
+		}
+		title := item.Title
+		if item.Action == store.ImportNoID {
+			title = item.Source
+		}
+		fmt.Printf("%-9s  %-36s  %5d  %s\n", item.Action, hash, item.Rows, title)
+	}
+	fmt.Println()
+	fmt.Println(tr("import.summary", counts[store.ImportAdd], counts[store.ImportOverwrite], counts[store.ImportRename],
+		counts[store.ImportSkip]+counts[store.ImportSame]+counts[store.ImportDuplicate]+counts[store.ImportNoID]))
+	if counts[store.ImportNoID] > 0 {
+		fmt.Println(tr("import.noID", counts[store.ImportNoID]))
+	}
+}
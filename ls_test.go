@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 每个排序字段的升序和降序结果，相同值时依次按开始时间和哈希值排序
+func TestSortSessions(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC) }
+	sessions := []SessionInfo{
+		{Hash: "d", StartTime: at(3), EndTime: at(6), Title: "alpha", MessageCount: 1, Size: 20},
+		{Hash: "c", StartTime: at(3), EndTime: at(6), Title: "gamma", MessageCount: 5, Size: 20},
+		{Hash: "b", StartTime: at(2), EndTime: at(4), Title: "Alpha", MessageCount: 2, Size: 10},
+		{Hash: "a", StartTime: at(1), EndTime: at(5), Title: "beta", MessageCount: 2, Size: 30},
+	}
+	tests := []struct {
+		sortBy    string
+		asc, desc string
+	}{
+		{"", "abcd", "dcba"},
+		{"start", "abcd", "dcba"},
+		{"end", "bacd", "dcab"},
+		{"title", "bdac", "cadb"},
+		{"messages", "dabc", "cbad"},
+		{"size", "bcda", "adcb"},
+	}
+	order := func(s []SessionInfo) string {
+		var b strings.Builder
+		for _, x := range s {
+			b.WriteString(x.Hash)
+		}
+		return b.String()
+	}
+	for _, tt := range tests {
+		for _, desc := range []bool{false, true} {
+			want := tt.asc
+			if desc {
+				want = tt.desc
+			}
+			// 正序和倒序的输入得到相同的结果
+			for _, reversed := range []bool{false, true} {
+				s := slices.Clone(sessions)
+				if reversed {
+					slices.Reverse(s)
+				}
+				if err := sortSessions(s, tt.sortBy, desc); err != nil {
+					t.Fatalf("sort %q: %v", tt.sortBy, err)
+				}
+				if got := order(s); got != want {
+					t.Errorf("sort %q desc=%v reversed input=%v: %s, want %s", tt.sortBy, desc, reversed, got, want)
+				}
+			}
+		}
+	}
+
+	if err := sortSessions(slices.Clone(sessions), "tokens", false); err == nil || errorCode(err) != codeInvalidArg {
+		t.Errorf("unknown sort field: got %v, want invalid-arg", err)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", defaultSessionColumns, false},
+		{"  ", defaultSessionColumns, false},
+		{"hash, Title,,cost", []string{"hash", "title", "cost"}, false},
+		{"hash,model", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseColumns(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseColumns(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseColumns(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// -offset超过会话数量时返回空列表，-limit 0表示不限制，匹配数量不受分页影响
+func TestLoadSessionsPaging(t *testing.T) {
+	db, err := openDB(dbPaths{newFixtureDB(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		offset, limit int
+		want          []string
+	}{
+		{0, 0, []string{fixtureLogin, fixtureParser, fixtureHTML}},
+		{1, 1, []string{fixtureParser}},
+		{2, 5, []string{fixtureHTML}},
+		{3, 0, nil},
+		{10, 1, nil},
+	}
+	for _, tt := range tests {
+		sessions, matched, err := loadSessions(db, Config{Offset: tt.offset, Limit: tt.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range sessions {
+			got = append(got, s.Hash)
+		}
+		if !slices.Equal(got, tt.want) || matched != 3 {
+			t.Errorf("offset %d limit %d = %v (matched %d), want %v (matched 3)", tt.offset, tt.limit, got, matched, tt.want)
+		}
+	}
+}
+
+// 文本和JSON输出的会话顺序相同
+func TestListSessionsOrder(t *testing.T) {
+	isolateConfig(t)
+	config := Config{DBPaths: dbPaths{newFixtureDB(t)}, SortBy: "messages", SortDesc: true, Columns: []string{"hash"}}
+
+	var text []string
+	out := captureStdout(t, func() {
+		if err := listSessions(config); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) == len(fixtureLogin) && strings.Count(line, "-") == 4 {
+			text = append(text, line)
+		}
+	}
+
+	config.JsonOutput = true
+	out = captureStdout(t, func() {
+		if err := listSessions(config); err != nil {
+			t.Error(err)
+		}
+	})
+	var response SessionListResponse
+	if err := json.Unmarshal([]byte(out), &response); err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON []string
+	for _, s := range response.Sessions {
+		fromJSON = append(fromJSON, s.Hash)
+	}
+
+	// fixtureParser有3条消息，另外两个会话消息数相同时按开始时间降序
+	want := []string{fixtureParser, fixtureHTML, fixtureLogin}
+	if !slices.Equal(text, want) || !slices.Equal(fromJSON, want) {
+		t.Errorf("text order %v, JSON order %v, want %v", text, fromJSON, want)
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// MCP协议版本
+const mcpProtocolVersion = "2024-11-05"
+
+// 会话资源URI前缀
+const mcpSessionURIPrefix = "cursor2md://sessions/"
+
+// JSON-RPC 2.0 请求
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// JSON-RPC 2.0 响应
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC 2.0 标准错误码
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// MCP工具定义
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// MCP工具调用结果中的内容
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// MCP资源定义
+type mcpResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+type mcpResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// 生成JSON Schema中的对象类型
+func mcpObjectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpProperty(typ string, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+// 提供的工具列表，说明使用当前的界面语言
+func mcpTools() []mcpTool {
+	return []mcpTool{
+		{
+			Name:        "list_sessions",
+			Description: tr("mcp.listSessions"),
+			InputSchema: mcpObjectSchema(map[string]interface{}{
+				"start_after":  mcpProperty("string", tr("mcp.startAfter")),
+				"start_before": mcpProperty("string", tr("mcp.startBefore")),
+				"end_after":    mcpProperty("string", tr("mcp.endAfter")),
+				"end_before":   mcpProperty("string", tr("mcp.endBefore")),
+				"sort":         mcpProperty("string", tr("mcp.sort")),
+				"sort_desc":    mcpProperty("boolean", tr("mcp.sortDesc")),
+				"limit":        mcpProperty("integer", tr("mcp.sessionLimit")),
+				"offset":       mcpProperty("integer", tr("mcp.offset")),
+			}),
+		},
+		{
+			Name:        "search_chats",
+			Description: tr("mcp.searchChats"),
+			InputSchema: mcpObjectSchema(map[string]interface{}{
+				"query": mcpProperty("string", tr("mcp.query")),
+				"limit": mcpProperty("integer", tr("mcp.limit", 20)),
+			}, "query"),
+		},
+		{
+			Name:        "get_session",
+			Description: tr("mcp.getSession"),
+			InputSchema: mcpObjectSchema(map[string]interface{}{
+				"hash":   mcpProperty("string", tr("mcp.hash")),
+				"format": mcpProperty("string", tr("mcp.format")),
+			}, "hash"),
+		},
+		{
+			Name:        "get_file_discussions",
+			Description: tr("mcp.getFileDiscussions"),
+			InputSchema: mcpObjectSchema(map[string]interface{}{
+				"path":  mcpProperty("string", tr("mcp.path")),
+				"limit": mcpProperty("integer", tr("mcp.limit", 50)),
+			}, "path"),
+		},
+	}
+}
+
+// 通过标准输入输出提供MCP服务
+type mcpServer struct {
+	db     *store.Merged
+	redact bool        // 是否替换敏感信息
+	anon   *anonymizer // 匿名化处理器，为nil时不处理
+}
+
+// 加载会话，启用脱敏时替换敏感信息后再返回给调用方
+func (s *mcpServer) loadSessions(config Config) ([]SessionInfo, int, error) {
+	sessions, matched, err := loadSessions(s.db, config)
+	if err == nil {
+		s.protect(sessions)
+	}
+	return sessions, matched, err
+}
+
+// 按设置脱敏和匿名化会话
+func (s *mcpServer) protect(sessions []SessionInfo) {
+	if s.redact {
+		redactSessions(sessions)
+	}
+	if s.anon != nil {
+		s.anon.anonymizeSessions(sessions)
+	}
+}
+
+// 处理请求直到输入结束，每行一个JSON-RPC消息
+func (s *mcpServer) serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			encoder.Encode(rpcResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   &rpcError{Code: rpcParseError, Message: tr("mcp.parseError", err)},
+			})
+			continue
+		}
+
+		result, rpcErr := s.handle(req)
+		// 通知消息没有id，不需要响应
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+		if rpcErr == nil && result == nil {
+			resp.Result = struct{}{}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// 分发请求
+func (s *mcpServer) handle(req rpcRequest) (interface{}, *rpcError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: tr("mcp.version")}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities": map[string]interface{}{
+				"tools":     map[string]interface{}{},
+				"resources": map[string]interface{}{},
+			},
+			"serverInfo": map[string]interface{}{
+				"name":    "cursor2md",
+				"version": version,
+			},
+		}, nil
+	case "notifications/initialized", "notifications/cancelled", "ping":
+		return nil, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": mcpTools()}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: tr("mcp.params", err)}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		text, err := s.callTool(params.Name, params.Arguments)
+		if err != nil {
+			if rpcErr, ok := err.(*rpcError); ok {
+				return nil, rpcErr
+			}
+			// 工具执行失败作为结果返回，便于调用方的模型看到错误信息
+			return mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+	case "resources/list":
+		sessions, _, err := s.loadSessions(Config{SortDesc: true})
+		if err != nil {
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		resources := make([]mcpResource, 0, len(sessions))
+		for _, session := range sessions {
+			resources = append(resources, mcpResource{
+				URI:         mcpSessionURIPrefix + session.Hash,
+				Name:        session.Title,
+				Description: tr("mcp.resource", session.StartTime.Format("2006-01-02 15:04:05"), session.MessageCount),
+				MimeType:    "text/markdown",
+			})
+		}
+		return map[string]interface{}{"resources": resources}, nil
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || !strings.HasPrefix(params.URI, mcpSessionURIPrefix) {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: tr("mcp.uri", params.URI)}
+		}
+		hash := strings.TrimPrefix(params.URI, mcpSessionURIPrefix)
+		session, err := s.findSession(hash)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		return map[string]interface{}{
+			"contents": []mcpResourceContent{{
+				URI:      params.URI,
+				MimeType: "text/markdown",
+				Text:     convertToMarkdown(*session.Record),
+			}},
+		}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: tr("mcp.method", req.Method)}
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// 根据hash、hash前缀或标题查找会话
+// 完整hash直接读取单个会话，只处理找到的会话
+func (s *mcpServer) findSession(query string) (SessionInfo, error) {
+	session, err := findSession(s.db, query)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	sessions := []SessionInfo{session}
+	s.protect(sessions)
+	return sessions[0], nil
+}
+
+// 执行工具调用，返回文本结果
+func (s *mcpServer) callTool(name string, arguments json.RawMessage) (string, error) {
+	marshal := func(v interface{}) (string, error) {
+		jsonData, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", errors.New(tr("err.json", err))
+		}
+		return string(jsonData), nil
+	}
+
+	switch name {
+	case "list_sessions":
+		var args struct {
+			StartAfter  string `json:"start_after"`
+			StartBefore string `json:"start_before"`
+			EndAfter    string `json:"end_after"`
+			EndBefore   string `json:"end_before"`
+			Sort        string `json:"sort"`
+			SortDesc    *bool  `json:"sort_desc"`
+			Limit       int    `json:"limit"`
+			Offset      int    `json:"offset"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", &rpcError{Code: rpcInvalidParams, Message: tr("mcp.params", err)}
+		}
+		config := Config{SortBy: args.Sort, SortDesc: true, Limit: args.Limit, Offset: args.Offset}
+		if args.SortDesc != nil {
+			config.SortDesc = *args.SortDesc
+		}
+		if config.Limit <= 0 {
+			config.Limit = 50
+		}
+		timeArgs := []struct {
+			name   string
+			value  string
+			target *time.Time
+		}{
+			{"start_after", args.StartAfter, &config.StartAfter},
+			{"start_before", args.StartBefore, &config.StartBefore},
+			{"end_after", args.EndAfter, &config.EndAfter},
+			{"end_before", args.EndBefore, &config.EndBefore},
+		}
+		for _, p := range timeArgs {
+			t, err := parseTimeArg(p.value)
+			if err != nil {
+				return "", errors.New(tr("err.parseFlag", p.name, err))
+			}
+			*p.target = t
+		}
+		config.HasTimeFilter = !config.StartAfter.IsZero() || !config.StartBefore.IsZero() ||
+			!config.EndAfter.IsZero() || !config.EndBefore.IsZero()
+
+		sessions, matched, err := s.loadSessions(config)
+		if err != nil {
+			return "", err
+		}
+		return marshal(SessionListResponse{Sessions: sessions, Total: len(sessions), Matched: matched, Success: true})
+
+	case "search_chats":
+		var args struct {
+			Query string `json:"query"`
+			Limit int    `json:"limit"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil || strings.TrimSpace(args.Query) == "" {
+			return "", &rpcError{Code: rpcInvalidParams, Message: tr("mcp.required", "search_chats", "query")}
+		}
+		if args.Limit <= 0 {
+			args.Limit = 20
+		}
+		sessions, _, err := s.loadSessions(Config{SortDesc: true})
+		if err != nil {
+			return "", err
+		}
+		results := searchSessions(sessions, args.Query, args.Limit)
+		return marshal(SearchResponse{Query: args.Query, Results: results, Total: len(results), Success: true})
+
+	case "get_session":
+		var args struct {
+			Hash   string `json:"hash"`
+			Format string `json:"format"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil || strings.TrimSpace(args.Hash) == "" {
+			return "", &rpcError{Code: rpcInvalidParams, Message: tr("mcp.required", "get_session", "hash")}
+		}
+		if args.Format == "" {
+			args.Format = "markdown"
+		}
+		session, err := s.findSession(args.Hash)
+		if err != nil {
+			return "", err
+		}
+		return renderSession(*session.Record, args.Format)
+
+	case "get_file_discussions":
+		var args struct {
+			Path  string `json:"path"`
+			Limit int    `json:"limit"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil || strings.TrimSpace(args.Path) == "" {
+			return "", &rpcError{Code: rpcInvalidParams, Message: tr("mcp.required", "get_file_discussions", "path")}
+		}
+		if args.Limit <= 0 {
+			args.Limit = 50
+		}
+		sessions, _, err := s.loadSessions(Config{})
+		if err != nil {
+			return "", err
+		}
+		mentions := findFileMentions(sessions, args.Path)
+		// 保留最近的记录
+		if len(mentions) > args.Limit {
+			mentions = mentions[len(mentions)-args.Limit:]
+		}
+		return marshal(map[string]interface{}{"path": args.Path, "mentions": mentions, "total": len(mentions)})
+	}
+	return "", &rpcError{Code: rpcInvalidParams, Message: tr("mcp.tool", name)}
+}
+
+// mcp命令入口
+func runMCP(args []string) {
+	mcpCmd := flag.NewFlagSet("mcp", flag.ExitOnError)
+	var dbPath dbPaths
+	registerDBFlags(mcpCmd, &dbPath)
+	redact := mcpCmd.Bool("redact", true, tr("flag.redact.mcp"))
+	anonymizeRules := mcpCmd.String("anonymize", "", tr("flag.anonymize"))
+	parseFlags(mcpCmd, args)
+
+	// 标准输出用于协议通信，错误信息写入标准错误
+	if !dbPath.setDefault() {
+		failed(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		fmt.Fprintln(os.Stderr, tr("err.defaultDB"))
+		return
+	}
+	anon, err := loadAnonymizer(*anonymizeRules)
+	if err != nil {
+		failed(withCode(codeInvalidArg, err))
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	db, err := openReadOnlyDB(dbPath)
+	if err != nil {
+		failed(err)
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer db.Close()
+
+	server := &mcpServer{db: db, redact: *redact, anon: anon}
+	if err := server.serve(os.Stdin, os.Stdout); err != nil {
+		failed(err)
+		fmt.Fprintln(os.Stderr, tr("mcp.failed", err))
+	}
+}
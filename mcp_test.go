@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 通过输入流向MCP服务发送请求，返回按行解析的响应
+func runMCPScript(t *testing.T, lines ...string) []rpcResponse {
+	t.Helper()
+	db, err := openReadOnlyDB([]string{newFixtureDB(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var out strings.Builder
+	server := &mcpServer{db: db, redact: true}
+	if err := server.serve(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	var responses []rpcResponse
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var resp rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response line %q: %v", scanner.Text(), err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+// 将响应结果重新解析为指定类型
+func decodeResult(t *testing.T, resp rpcResponse, v interface{}) {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("id %s: unexpected error %d %s", resp.ID, resp.Error.Code, resp.Error.Message)
+	}
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 工具调用返回的文本内容
+func toolText(t *testing.T, resp rpcResponse) (string, bool) {
+	t.Helper()
+	var result mcpToolResult
+	decodeResult(t, resp, &result)
+	if len(result.Content) != 1 || result.Content[0].Type != "text" {
+		t.Fatalf("id %s: unexpected content %+v", resp.ID, result.Content)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestMCPSession(t *testing.T) {
+	responses := runMCPScript(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"search_chats","arguments":{"query":"COOKIE"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_session","arguments":{"hash":"`+fixtureParser+`"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"get_session","arguments":{"hash":"Fix login bug","format":"json"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"get_session","arguments":{"hash":"missing"}}}`,
+	)
+	// 通知不产生响应
+	if len(responses) != 6 {
+		t.Fatalf("got %d responses, want 6", len(responses))
+	}
+	for i, resp := range responses {
+		if want := string(rune('1' + i)); string(resp.ID) != want || resp.JSONRPC != "2.0" {
+			t.Errorf("response %d: jsonrpc = %q, id = %s, want id %s", i, resp.JSONRPC, resp.ID, want)
+		}
+	}
+
+	var initResult struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	decodeResult(t, responses[0], &initResult)
+	if initResult.ProtocolVersion != mcpProtocolVersion || initResult.ServerInfo.Name != "cursor2md" {
+		t.Errorf("initialize result = %+v", initResult)
+	}
+
+	var list struct {
+		Tools []mcpTool `json:"tools"`
+	}
+	decodeResult(t, responses[1], &list)
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "list_sessions,search_chats,get_session,get_file_discussions" {
+		t.Errorf("tools = %s", got)
+	}
+
+	text, isError := toolText(t, responses[2])
+	var search SearchResponse
+	if err := json.Unmarshal([]byte(text), &search); err != nil || isError {
+		t.Fatalf("search_chats: isError = %v, err = %v, text = %s", isError, err, text)
+	}
+	if search.Total != 1 || search.Results[0].Hash != fixtureLogin {
+		t.Errorf("search_chats results = %+v, want only %s", search.Results, fixtureLogin)
+	}
+
+	text, isError = toolText(t, responses[3])
+	if isError || !strings.HasPrefix(text, "# Parser refactor\n") || !strings.Contains(text, "Split the parser") {
+		t.Errorf("get_session by hash returned:\n%s", text)
+	}
+
+	text, isError = toolText(t, responses[4])
+	var record struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(text), &record); err != nil || isError || record.Name != "Fix login bug" {
+		t.Errorf("get_session by title: isError = %v, err = %v, text = %s", isError, err, text)
+	}
+
+	// 找不到会话时作为工具错误返回
+	if _, isError = toolText(t, responses[5]); !isError {
+		t.Error("get_session for a missing session did not report an error")
+	}
+}
+
+func TestMCPErrors(t *testing.T) {
+	responses := runMCPScript(t,
+		`{"jsonrpc":"2.0","id":1,"method":`,
+		``,
+		`{"jsonrpc":"1.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"unknown/method"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"search_chats","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"no_such_tool"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"ping"}`,
+	)
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"null", rpcParseError},
+		{"2", rpcInvalidRequest},
+		{"3", rpcMethodNotFound},
+		{"4", rpcInvalidParams},
+		{"5", rpcInvalidParams},
+		{"6", 0},
+	}
+	if len(responses) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(want))
+	}
+	for i, w := range want {
+		resp := responses[i]
+		code := 0
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if string(resp.ID) != w.id || code != w.code {
+			t.Errorf("response %d: id = %s, code = %d, want id %s, code %d", i, resp.ID, code, w.id, w.code)
+		}
+	}
+	// 格式错误的行之后服务继续处理后续请求
+	if responses[len(responses)-1].Result == nil {
+		t.Error("ping returned no result")
+	}
+}
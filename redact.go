@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 敏感信息检测规则
+type redactionRule struct {
+	kind string         // 占位符中的类型名称
+	re   *regexp.Regexp // 匹配规则
+	// 只替换指定的子匹配组，0表示替换整个匹配
+	group int
+}
+
+// 按顺序应用的规则：先处理私钥块等较长的匹配，再处理具体的令牌格式，最后是高熵字符串
+var redactionRules = []redactionRule{
+	{kind: "PRIVATE_KEY", re: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{kind: "AWS_ACCESS_KEY", re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`)},
+	{kind: "AWS_SECRET_KEY", re: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), group: 1},
+	{kind: "GITHUB_TOKEN", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "ANTHROPIC_KEY", re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{kind: "OPENAI_KEY", re: regexp.MustCompile(`\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{20,}`)},
+	{kind: "JWT", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "URL_PASSWORD", re: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`), group: 1},
+}
+
+// 高熵字符串候选：较长的、由字母数字和常见编码字符组成的片段
+var highEntropyCandidate = regexp.MustCompile(`[A-Za-z0-9+_=-]{24,}`)
+
+// 计算字符串的香农熵（每个字符的比特数）
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// 判断字符串是否像随机生成的密钥：同时包含大小写字母和至少两个数字，且熵足够高
+// 十六进制的提交哈希、UUID和普通标识符不会被认为是密钥
+func looksLikeSecret(s string) bool {
+	var upper, lower, digits int
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		case unicode.IsDigit(r):
+			digits++
+		}
+	}
+	if upper == 0 || lower == 0 || digits < 2 {
+		return false
+	}
+	return shannonEntropy(s) >= 4.0
+}
+
+// 敏感信息替换器，记录每种类型的替换次数
+type redactor struct {
+	counts map[string]int
+}
+
+func newRedactor() *redactor {
+	return &redactor{counts: make(map[string]int)}
+}
+
+// 替换后的占位符
+func redactionPlaceholder(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// 替换文本中的敏感信息
+func (r *redactor) redactString(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, rule := range redactionRules {
+		if rule.group == 0 {
+			text = rule.re.ReplaceAllStringFunc(text, func(string) string {
+				r.counts[rule.kind]++
+				return redactionPlaceholder(rule.kind)
+			})
+			continue
+		}
+
+		// 只替换子匹配组，保留前后的上下文（例如URL中的用户名和主机）
+		matches := rule.re.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[2*rule.group], m[2*rule.group+1]
+			if start < 0 {
+				continue
+			}
+			sb.WriteString(text[last:start])
+			sb.WriteString(redactionPlaceholder(rule.kind))
+			r.counts[rule.kind]++
+			last = end
+		}
+		sb.WriteString(text[last:])
+		text = sb.String()
+	}
+
+	return highEntropyCandidate.ReplaceAllStringFunc(text, func(s string) string {
+		if !looksLikeSecret(s) {
+			return s
+		}
+		r.counts["HIGH_ENTROPY"]++
+		return redactionPlaceholder("HIGH_ENTROPY")
+	})
+}
+
+// 返回替换掉敏感信息的记录副本，不修改原记录
+// 处理标题、消息文本、引用的代码片段、代码块内容和已应用的修改
+// 原始JSON和未解析的字段无法逐项处理，会被丢弃
+func (r *redactor) redactRecord(record ChatRecord) ChatRecord {
+	record = record.StripRaw()
+	record.Name = r.redactString(record.Name)
+	conversation := make([]Message, len(record.Conversation))
+	for i, msg := range record.Conversation {
+		msg.Text = r.redactString(msg.Text)
+
+		selections := append(msg.Context.Selections[:0:0], msg.Context.Selections...)
+		for j := range selections {
+			selections[j].Text = r.redactString(selections[j].Text)
+		}
+		msg.Context.Selections = selections
+
+		codeBlocks := append(msg.CodeBlocks[:0:0], msg.CodeBlocks...)
+		for j := range codeBlocks {
+			codeBlocks[j].Content = r.redactString(codeBlocks[j].Content)
+		}
+		msg.CodeBlocks = codeBlocks
+
+		conversation[i] = msg
+	}
+	record.Conversation = conversation
+
+	changes := append(record.AppliedChanges[:0:0], record.AppliedChanges...)
+	for i := range changes {
+		changes[i].Hunks = append(changes[i].Hunks[:0:0], changes[i].Hunks...)
+		for j := range changes[i].Hunks {
+			changes[i].Hunks[j].Removed = r.redactLines(changes[i].Hunks[j].Removed)
+			changes[i].Hunks[j].Added = r.redactLines(changes[i].Hunks[j].Added)
+		}
+	}
+	record.AppliedChanges = changes
+	return record
+}
+
+// 返回替换掉提交说明中敏感信息的副本
+func (r *redactor) redactCommits(commits []RelatedCommit) []RelatedCommit {
+	commits = append(commits[:0:0], commits...)
+	for i := range commits {
+		commits[i].Subject = r.redactString(commits[i].Subject)
+	}
+	return commits
+}
+
+// 返回替换掉敏感信息的行副本
+func (r *redactor) redactLines(lines []string) []string {
+	if lines == nil {
+		return nil
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = r.redactString(line)
+	}
+	return result
+}
+
+// 对记录进行脱敏，返回脱敏后的记录和各类型的替换次数
+func redactRecord(record ChatRecord) (ChatRecord, map[string]int) {
+	r := newRedactor()
+	record = r.redactRecord(record)
+	if len(r.counts) == 0 {
+		return record, nil
+	}
+	return record, r.counts
+}
+
+// 对导出记录及其关联提交进行脱敏，返回各类型的替换次数
+func redactExportRecord(record exportRecord) (exportRecord, map[string]int) {
+	r := newRedactor()
+	record.ChatRecord = r.redactRecord(record.ChatRecord)
+	record.RelatedCommits = r.redactCommits(record.RelatedCommits)
+	if len(r.counts) == 0 {
+		return record, nil
+	}
+	return record, r.counts
+}
+
+// 将替换次数格式化为简短的描述，例如 "AWS_ACCESS_KEY×1, JWT×2"
+func formatRedactionCounts(counts map[string]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s×%d", kind, counts[kind]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// 对会话列表中的每条记录进行脱敏，返回各会话的替换次数
+func redactSessions(sessions []SessionInfo) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for i := range sessions {
+		record, c := redactRecord(*sessions[i].Record)
+		sessions[i].Record = &record
+		sessions[i].Title = record.Name
+		if c != nil {
+			counts[sessions[i].Hash] = c
+		}
+	}
+	return counts
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 搜索命中的消息
+type SearchResult struct {
+	Hash         string    `json:"hash"`
+	Title        string    `json:"title"`
+	MessageIndex int       `json:"messageIndex"` // 消息在会话中的位置，从1开始；0表示标题命中
+	Role         string    `json:"role"`
+	Time         time.Time `json:"time"`
+	Snippet      string    `json:"snippet"`
+}
+
+// 消息类型对应的角色名称
+func messageRole(msgType int) string {
+	switch msgType {
+	case 1:
+		return "user"
+	case 2:
+		return "assistant"
+	}
+	return "unknown"
+}
+
+// 截取关键词附近的文本作为摘要
+func searchSnippet(text string, index int, length int) string {
+	const context = 60
+	runes := []rune(text)
+	// 将字节位置转换为字符位置
+	runeIndex := len([]rune(text[:index]))
+	runeEnd := runeIndex + len([]rune(text[index:index+length]))
+
+	start := runeIndex - context
+	if start < 0 {
+		start = 0
+	}
+	end := runeEnd + context
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// 在会话标题、消息文本、引用的代码片段和代码块中搜索关键词（不区分大小写）
+// limit为0时返回全部结果
+func searchSessions(sessions []SessionInfo, query string, limit int) []SearchResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var results []SearchResult
+	add := func(r SearchResult) bool {
+		results = append(results, r)
+		return limit > 0 && len(results) >= limit
+	}
+
+	for _, s := range sessions {
+		if i := strings.Index(strings.ToLower(s.Title), query); i >= 0 {
+			if add(SearchResult{Hash: s.Hash, Title: s.Title, Role: "title", Time: s.StartTime, Snippet: s.Title}) {
+				return results
+			}
+		}
+
+		for n, msg := range s.Record.Conversation {
+			texts := []string{msg.Text}
+			for _, sel := range msg.Context.Selections {
+				texts = append(texts, sel.Text)
+			}
+			for _, block := range msg.CodeBlocks {
+				texts = append(texts, block.Content)
+			}
+
+			for _, text := range texts {
+				// 转小写后字节长度可能变化，只在长度一致时使用原文截取摘要
+				lower := strings.ToLower(text)
+				i := strings.Index(lower, query)
+				if i < 0 {
+					continue
+				}
+				source := text
+				if len(lower) != len(text) {
+					source = lower
+				}
+				result := SearchResult{
+					Hash:         s.Hash,
+					Title:        s.Title,
+					MessageIndex: n + 1,
+					Role:         messageRole(msg.Type),
+					Time:         time.UnixMilli(msg.TimingInfo.ClientStartTime),
+					Snippet:      searchSnippet(source, i, len(query)),
+				}
+				if add(result) {
+					return results
+				}
+				// 每条消息只记录一次命中
+				break
+			}
+		}
+	}
+	return results
+}
+
+// 会话中提及某个文件的消息
+type FileMention struct {
+	Hash         string    `json:"hash"`
+	Title        string    `json:"title"`
+	MessageIndex int       `json:"messageIndex"` // 消息在会话中的位置，从1开始
+	Role         string    `json:"role"`
+	Time         time.Time `json:"time"`
+	Path         string    `json:"path"`   // 消息中记录的完整路径
+	Source       string    `json:"source"` // 提及方式: fileSelection, selection, codeBlock
+	Excerpt      string    `json:"excerpt"`
+}
+
+// 判断路径是否以指定的路径结尾（按路径段匹配，忽略大小写和分隔符差异）
+// 这样不同机器上不同检出位置的同一文件也能匹配
+func pathHasSuffix(path string, suffix string) bool {
+	path = strings.ToLower(strings.TrimRight(strings.ReplaceAll(path, "\\", "/"), "/"))
+	suffix = strings.ToLower(strings.Trim(strings.ReplaceAll(suffix, "\\", "/"), "/"))
+	suffix = strings.TrimPrefix(suffix, "./")
+	if path == "" || suffix == "" {
+		return false
+	}
+	return path == suffix || strings.HasSuffix(path, "/"+suffix)
+}
+
+// 查找所有在引用文件、引用的代码片段或代码块中提及指定路径的消息，按时间顺序排列
+func findFileMentions(sessions []SessionInfo, path string) []FileMention {
+	var mentions []FileMention
+	for _, s := range sessions {
+		for n, msg := range s.Record.Conversation {
+			base := FileMention{
+				Hash:         s.Hash,
+				Title:        s.Title,
+				MessageIndex: n + 1,
+				Role:         messageRole(msg.Type),
+				Time:         time.UnixMilli(msg.TimingInfo.ClientStartTime),
+			}
+			if msg.TimingInfo.ClientStartTime == 0 {
+				base.Time = s.StartTime
+			}
+
+			for _, file := range msg.Context.FileSelections {
+				if pathHasSuffix(file.Uri.Path, path) {
+					m := base
+					m.Path, m.Source, m.Excerpt = file.Uri.Path, "fileSelection", msg.Text
+					mentions = append(mentions, m)
+				}
+			}
+			for _, sel := range msg.Context.Selections {
+				if pathHasSuffix(sel.Uri.Path, path) {
+					m := base
+					m.Path, m.Source, m.Excerpt = sel.Uri.Path, "selection", sel.Text
+					mentions = append(mentions, m)
+				}
+			}
+			for _, block := range msg.CodeBlocks {
+				if pathHasSuffix(block.Uri.Path, path) {
+					m := base
+					m.Path, m.Source, m.Excerpt = block.Uri.Path, "codeBlock", block.Content
+					mentions = append(mentions, m)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].Time.Before(mentions[j].Time)
+	})
+	return mentions
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 内嵌的单页网页界面
+//
+//go:embed web
+var webFiles embed.FS
+
+// 单个会话的详细信息
+type SessionDetailResponse struct {
+	Session *SessionInfo `json:"session"`
+	Record  *ChatRecord  `json:"record"`
+	Success bool         `json:"success"`
+	Error   *string      `json:"error,omitempty"`
+}
+
+// 搜索结果
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Success bool           `json:"success"`
+	Error   *string        `json:"error,omitempty"`
+}
+
+// 以只读方式打开数据库，避免与正在运行的Cursor冲突
+func openReadOnlyDB(paths []string) (*store.Merged, error) {
+	files, err := expandDBPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenMerged(files, store.Options{ReadOnly: true})
+}
+
+// 从查询参数中解析与ls命令相同的过滤、排序和分页参数
+func configFromQuery(query url.Values) (Config, error) {
+	var config Config
+	var err error
+
+	timeParams := []struct {
+		name   string
+		target *time.Time
+	}{
+		{"start-after", &config.StartAfter},
+		{"start-before", &config.StartBefore},
+		{"end-after", &config.EndAfter},
+		{"end-before", &config.EndBefore},
+	}
+	for _, p := range timeParams {
+		if *p.target, err = parseTimeArg(query.Get(p.name)); err != nil {
+			return config, errors.New(tr("err.parseFlag", p.name, err))
+		}
+	}
+	config.HasTimeFilter = !config.StartAfter.IsZero() || !config.StartBefore.IsZero() ||
+		!config.EndAfter.IsZero() || !config.EndBefore.IsZero()
+
+	config.SortBy = query.Get("sort")
+	if err := sortSessions(nil, config.SortBy, false); err != nil {
+		return config, err
+	}
+	if v := query.Get("sort-desc"); v != "" {
+		if config.SortDesc, err = strconv.ParseBool(v); err != nil {
+			return config, errors.New(tr("serve.param", "sort-desc", v))
+		}
+	}
+	intParams := []struct {
+		name   string
+		target *int
+	}{
+		{"limit", &config.Limit},
+		{"offset", &config.Offset},
+	}
+	for _, p := range intParams {
+		if v := query.Get(p.name); v != "" {
+			if *p.target, err = strconv.Atoi(v); err != nil || *p.target < 0 {
+				return config, errors.New(tr("serve.param", p.name, v))
+			}
+		}
+	}
+	return config, nil
+}
+
+// 提供REST接口和网页界面的只读服务
+type archiveServer struct {
+	db     *store.Merged
+	redact bool        // 是否替换敏感信息
+	anon   *anonymizer // 匿名化处理器，为nil时不处理
+}
+
+func newArchiveServer(db *store.Merged, redact bool, anon *anonymizer) http.Handler {
+	s := &archiveServer{db: db, redact: redact, anon: anon}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sessions", s.handleSessions)
+	mux.HandleFunc("GET /api/sessions/{hash}", s.handleSession)
+	mux.HandleFunc("GET /api/search", s.handleSearch)
+
+	static, _ := fs.Sub(webFiles, "web")
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	return mux
+}
+
+// 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// 输出统一格式的JSON错误
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	errMsg := err.Error()
+	writeJSON(w, status, struct {
+		Success bool    `json:"success"`
+		Error   *string `json:"error"`
+		Code    string  `json:"code"`
+	}{false, &errMsg, errorCode(err)})
+}
+
+// 加载会话，启用脱敏时替换敏感信息后再返回给客户端
+func (s *archiveServer) loadSessions(config Config) ([]SessionInfo, int, error) {
+	sessions, matched, err := loadSessions(s.db, config)
+	if err == nil {
+		s.protect(sessions)
+	}
+	return sessions, matched, err
+}
+
+// 按服务的设置脱敏和匿名化会话
+func (s *archiveServer) protect(sessions []SessionInfo) {
+	if s.redact {
+		redactSessions(sessions)
+	}
+	if s.anon != nil {
+		s.anon.anonymizeSessions(sessions)
+	}
+}
+
+// GET /api/sessions
+func (s *archiveServer) handleSessions(w http.ResponseWriter, r *http.Request) {
+	config, err := configFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, withCode(codeInvalidArg, err))
+		return
+	}
+	sessions, matched, err := s.loadSessions(config)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []SessionInfo{}
+	}
+	writeJSON(w, http.StatusOK, SessionListResponse{
+		Sessions: sessions,
+		Total:    len(sessions),
+		Matched:  matched,
+		Success:  true,
+	})
+}
+
+// GET /api/sessions/{hash} 和 /api/sessions/{hash}.md
+func (s *archiveServer) handleSession(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	markdown := strings.HasSuffix(hash, ".md")
+	hash = strings.TrimSuffix(hash, ".md")
+
+	// 只读取请求的会话，无效的会话与不存在的会话一样返回404
+	found, err := s.db.Session(r.Context(), hash)
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrInvalidSession) {
+		writeJSONError(w, http.StatusNotFound, withCode(codeNotFound, errors.New(tr("err.sessionHash", hash))))
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sessions := []SessionInfo{newSessionInfo(found)}
+	s.protect(sessions)
+	session := &sessions[0]
+
+	if markdown {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		fmt.Fprint(w, convertToMarkdown(*session.Record))
+		return
+	}
+	writeJSON(w, http.StatusOK, SessionDetailResponse{
+		Session: session,
+		Record:  session.Record,
+		Success: true,
+	})
+}
+
+// GET /api/search?q=关键词
+func (s *archiveServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		writeJSONError(w, http.StatusBadRequest, withCode(codeInvalidArg, errors.New(tr("serve.query"))))
+		return
+	}
+	config, err := configFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, withCode(codeInvalidArg, err))
+		return
+	}
+	// limit和offset作用于搜索结果而不是会话列表
+	limit, offset := config.Limit, config.Offset
+	config.Limit, config.Offset = 0, 0
+
+	sessions, _, err := s.loadSessions(config)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if limit > 0 {
+		limit += offset
+	}
+	results := searchSessions(sessions, query, limit)
+	if offset >= len(results) {
+		results = nil
+	} else {
+		results = results[offset:]
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, SearchResponse{
+		Query:   query,
+		Results: results,
+		Total:   len(results),
+		Success: true,
+	})
+}
+
+// serve命令入口
+func runServe(args []string) {
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	var dbPath dbPaths
+	registerDBFlags(serveCmd, &dbPath)
+	addr := serveCmd.String("addr", "127.0.0.1:8080", tr("flag.addr"))
+	redact := serveCmd.Bool("redact", true, tr("flag.redact.mcp"))
+	anonymizeRules := serveCmd.String("anonymize", "", tr("flag.anonymize"))
+	parseFlags(serveCmd, args)
+
+	if !dbPath.setDefault() {
+		failed(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		fmt.Println(tr("err.defaultDB"))
+		return
+	}
+
+	anon, err := loadAnonymizer(*anonymizeRules)
+	if err != nil {
+		failed(withCode(codeInvalidArg, err))
+		fmt.Println(err)
+		return
+	}
+	db, err := openReadOnlyDB(dbPath)
+	if err != nil {
+		failed(err)
+		fmt.Println(err)
+		return
+	}
+	defer db.Close()
+
+	fmt.Println(tr("serve.listening", *addr))
+	if err := http.ListenAndServe(*addr, newArchiveServer(db, *redact, anon)); err != nil {
+		failed(err)
+		fmt.Println(tr("serve.failed", err))
+	}
+}
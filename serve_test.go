@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 基于测试数据库启动服务
+func newTestServer(t *testing.T, redact bool) *httptest.Server {
+	t.Helper()
+	db, err := openReadOnlyDB([]string{newFixtureDB(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	srv := httptest.NewServer(newArchiveServer(db, redact, nil))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// 发送GET请求，返回状态码、Content-Type和响应内容
+func get(t *testing.T, srv *httptest.Server, path string) (int, string, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestServeSessions(t *testing.T) {
+	srv := newTestServer(t, true)
+	status, contentType, body := get(t, srv, "/api/sessions?sort=start&sort-desc=false&limit=2")
+	if status != http.StatusOK || !strings.HasPrefix(contentType, "application/json") {
+		t.Fatalf("status = %d, Content-Type = %q", status, contentType)
+	}
+	var resp SessionListResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Matched != 3 || resp.Total != 2 {
+		t.Fatalf("success = %v, matched = %d, total = %d, want true, 3, 2", resp.Success, resp.Matched, resp.Total)
+	}
+	if resp.Sessions[0].Hash != fixtureLogin || resp.Sessions[1].Hash != fixtureParser {
+		t.Errorf("sessions = %s, %s, want oldest first", resp.Sessions[0].Hash, resp.Sessions[1].Hash)
+	}
+
+	status, _, body = get(t, srv, "/api/sessions?limit=-1")
+	if status != http.StatusBadRequest || !strings.Contains(body, `"code": "invalid-arg"`) {
+		t.Errorf("invalid limit: status = %d, body = %s", status, body)
+	}
+}
+
+func TestServeSession(t *testing.T) {
+	srv := newTestServer(t, true)
+	status, _, body := get(t, srv, "/api/sessions/"+fixtureParser)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", status, body)
+	}
+	var resp SessionDetailResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Session.Title != "Parser refactor" || len(resp.Record.Conversation) != 3 {
+		t.Errorf("title = %q, messages = %d", resp.Session.Title, len(resp.Record.Conversation))
+	}
+}
+
+func TestServeSessionMarkdown(t *testing.T) {
+	srv := newTestServer(t, true)
+	status, contentType, body := get(t, srv, "/api/sessions/"+fixtureLogin+".md")
+	if status != http.StatusOK || !strings.HasPrefix(contentType, "text/markdown") {
+		t.Fatalf("status = %d, Content-Type = %q", status, contentType)
+	}
+	if !strings.HasPrefix(body, "# Fix login bug\n") || !strings.Contains(body, "Check the session cookie.") {
+		t.Errorf("unexpected markdown:\n%s", body)
+	}
+}
+
+func TestServeSessionNotFound(t *testing.T) {
+	srv := newTestServer(t, true)
+	for _, path := range []string{"/api/sessions/missing", "/api/sessions/missing.md"} {
+		status, _, body := get(t, srv, path)
+		if status != http.StatusNotFound || !strings.Contains(body, `"code": "not-found"`) {
+			t.Errorf("%s: status = %d, body = %s", path, status, body)
+		}
+	}
+}
+
+func TestServeEscapesHTML(t *testing.T) {
+	srv := newTestServer(t, false)
+	for _, path := range []string{"/api/sessions", "/api/sessions/" + fixtureHTML, "/api/search?q=script"} {
+		status, _, body := get(t, srv, path)
+		if status != http.StatusOK {
+			t.Fatalf("%s: status = %d", path, status)
+		}
+		if strings.Contains(body, "<script>") || strings.Contains(body, "<b>") {
+			t.Errorf("%s: response contains unescaped HTML:\n%s", path, body)
+		}
+		if !strings.Contains(body, `\u003cb\u003eEscaping\u003c/b\u003e \u0026 co`) {
+			t.Errorf("%s: title is not escaped as expected", path)
+		}
+	}
+
+	// 网页界面在插入页面前转义标题、摘要和会话内容
+	status, contentType, body := get(t, srv, "/")
+	if status != http.StatusOK || !strings.HasPrefix(contentType, "text/html") {
+		t.Fatalf("index: status = %d, Content-Type = %q", status, contentType)
+	}
+	if !strings.Contains(body, "escapeHTML(item.title") {
+		t.Error("index page does not escape session titles")
+	}
+}
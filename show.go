@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 会话渲染函数，将记录转换为指定格式的文本
+type sessionRenderer func(record ChatRecord) (string, error)
+
+// 支持的输出格式
+var sessionFormats = map[string]sessionRenderer{
+	"markdown": func(record ChatRecord) (string, error) {
+		return convertToMarkdown(record), nil
+	},
+	"html": func(record ChatRecord) (string, error) {
+		return convertToHTML(record), nil
+	},
+	"json": func(record ChatRecord) (string, error) {
+		jsonData, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return "", errors.New(tr("err.json", err))
+		}
+		return string(jsonData) + "\n", nil
+	},
+	"raw": renderRawSession,
+}
+
+// 输出未经处理的composerData和气泡数据，只调整缩进
+func renderRawSession(record ChatRecord) (string, error) {
+	if record.Raw == nil {
+		return "", withCode(codeInvalidArg, errors.New(tr("show.rawRedacted")))
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"composerData":`)
+	buf.Write(record.Raw)
+	if len(record.RawBubbles) > 0 {
+		ids := make([]string, 0, len(record.RawBubbles))
+		for id := range record.RawBubbles {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		buf.WriteString(`,"bubbles":{`)
+		for i, id := range ids {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			name, _ := json.Marshal(id)
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(record.RawBubbles[id])
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return "", errors.New(tr("show.rawInvalid", err))
+	}
+	out.WriteByte('\n')
+	return out.String(), nil
+}
+
+// 模板中可用的函数
+var sessionTemplateFuncs = template.FuncMap{
+	// 将值序列化为JSON，例如 {{json .Field "modelConfig"}}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+	// 将毫秒时间戳格式化为本地时间，例如 {{time .CreatedAt}}
+	"time": func(ms int64) string {
+		return time.UnixMilli(ms).Format("2006-01-02 15:04:05")
+	},
+}
+
+// 使用text/template模板文件渲染会话，模板的数据为会话记录
+// 未解析的字段可以通过 .Field "名称" 读取，会话和消息都支持
+func renderTemplate(record ChatRecord, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", withCode(codeInvalidArg, errors.New(tr("show.readTemplate", err)))
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(sessionTemplateFuncs).Parse(string(data))
+	if err != nil {
+		return "", withCode(codeInvalidArg, errors.New(tr("show.parseTemplate", err)))
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, record); err != nil {
+		return "", errors.New(tr("show.renderTemplate", err))
+	}
+	return out.String(), nil
+}
+
+// 返回所有支持的格式名称
+func sessionFormatNames() []string {
+	names := make([]string, 0, len(sessionFormats))
+	for name := range sessionFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 按指定格式渲染会话
+func renderSession(record ChatRecord, format string) (string, error) {
+	if format == "md" {
+		format = "markdown"
+	}
+	render, ok := sessionFormats[format]
+	if !ok {
+		return "", withCode(codeInvalidArg, errors.New(tr("err.format", format, strings.Join(sessionFormatNames(), ", "))))
+	}
+	return render(record)
+}
+
+// 打开数据库，数据库文件不存在时返回错误
+// 指定多个数据库或目录时合并其中的会话
+func openDB(paths []string) (*store.Merged, error) {
+	files, err := expandDBPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenMerged(files, store.Options{})
+}
+
+// 根据完整哈希、唯一的哈希前缀或标题查找会话
+// 先按完整哈希直接读取，没有该会话时才读取所有会话按前缀或标题匹配
+func findSession(db *store.Merged, query string) (SessionInfo, error) {
+	if hash := strings.TrimSpace(query); hash != "" {
+		session, err := db.Session(context.Background(), hash)
+		if err == nil {
+			return newSessionInfo(session), nil
+		}
+		// 无效的会话不会出现在会话列表中，与按前缀查找时一样报告未找到
+		if !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrInvalidSession) {
+			return SessionInfo{}, err
+		}
+	}
+	sessions, _, err := loadSessions(db, Config{})
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	return resolveSession(sessions, query)
+}
+
+// 在已加载的会话中根据完整哈希、唯一的哈希前缀或标题查找会话
+// 多个会话匹配时返回列出候选会话的错误
+func resolveSession(sessions []SessionInfo, query string) (SessionInfo, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return SessionInfo{}, withCode(codeInvalidArg, errors.New(tr("show.noQuery")))
+	}
+
+	for _, s := range sessions {
+		if s.Hash == query {
+			return s, nil
+		}
+	}
+
+	var candidates []SessionInfo
+	for _, s := range sessions {
+		if strings.HasPrefix(s.Hash, query) {
+			candidates = append(candidates, s)
+		}
+	}
+
+	// 没有哈希前缀匹配时按标题模糊匹配：标题完全相同优先，其次是包含所有关键词
+	if len(candidates) == 0 {
+		lowerQuery := strings.ToLower(query)
+		for _, s := range sessions {
+			if strings.ToLower(s.Title) == lowerQuery {
+				candidates = append(candidates, s)
+			}
+		}
+		if len(candidates) == 0 {
+			words := strings.Fields(lowerQuery)
+			for _, s := range sessions {
+				title := strings.ToLower(s.Title)
+				matched := true
+				for _, w := range words {
+					if !strings.Contains(title, w) {
+						matched = false
+						break
+					}
+				}
+				if matched {
+					candidates = append(candidates, s)
+				}
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return SessionInfo{}, withCode(codeNotFound, errors.New(tr("show.notFound", query)))
+	case 1:
+		return candidates[0], nil
+	}
+
+	var b strings.Builder
+	b.WriteString(tr("show.ambiguous", query, len(candidates)))
+	for _, s := range candidates {
+		fmt.Fprintf(&b, "\n  %s  %s  %s", s.Hash, s.StartTime.Format("2006-01-02"), s.Title)
+	}
+	return SessionInfo{}, withCode(codeInvalidArg, fmt.Errorf("%s", b.String()))
+}
+
+// 解析-messages参数，格式为 起始:结束（从1开始，包含两端），起始或结束可省略
+func parseMessageRange(rangeStr string, total int) (int, int, error) {
+	if strings.TrimSpace(rangeStr) == "" {
+		return 0, total, nil
+	}
+
+	startStr, endStr, hasColon := strings.Cut(rangeStr, ":")
+	if !hasColon {
+		endStr = startStr
+	}
+
+	start, end := 1, total
+	var err error
+	if s := strings.TrimSpace(startStr); s != "" {
+		if start, err = strconv.Atoi(s); err != nil || start < 1 {
+			return 0, 0, errors.New(tr("show.range", rangeStr))
+		}
+	}
+	if s := strings.TrimSpace(endStr); s != "" {
+		if end, err = strconv.Atoi(s); err != nil || end < 1 {
+			return 0, 0, errors.New(tr("show.range", rangeStr))
+		}
+	}
+	if start > end {
+		return 0, 0, errors.New(tr("show.range", rangeStr))
+	}
+	if start > total {
+		return 0, 0, errors.New(tr("show.rangeTotal", rangeStr, total))
+	}
+	if end > total {
+		end = total
+	}
+	return start - 1, end, nil
+}
+
+// 将单个会话渲染到标准输出
+// templatePath不为空时使用模板渲染，忽略format
+func showSession(paths []string, query string, format string, templatePath string, messageRange string, redact bool, anon *anonymizer) error {
+	db, err := openDB(paths)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	session, err := findSession(db, query)
+	if err != nil {
+		return err
+	}
+
+	record := *session.Record
+	if format == "raw" && templatePath == "" {
+		if messageRange != "" {
+			return withCode(codeInvalidArg, errors.New(tr("show.rawMessages")))
+		}
+		if record.RawBubbles, err = db.RawBubbles(context.Background(), store.Session{Hash: session.Hash, Source: session.Source}); err != nil {
+			return err
+		}
+	}
+	from, to, err := parseMessageRange(messageRange, len(record.Conversation))
+	if err != nil {
+		return withCode(codeInvalidArg, err)
+	}
+	record.Conversation = record.Conversation[from:to]
+	if redact {
+		record, _ = redactRecord(record)
+	}
+	if anon != nil {
+		record = anon.anonymizeRecord(record)
+	}
+
+	var content string
+	if templatePath != "" {
+		content, err = renderTemplate(record, templatePath)
+	} else {
+		content, err = renderSession(record, format)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Print(content)
+	return nil
+}
+
+// show命令入口
+func runShow(args []string) {
+	showCmd := flag.NewFlagSet("show", flag.ExitOnError)
+	var dbPath dbPaths
+	registerDBFlags(showCmd, &dbPath)
+	format := showCmd.String("format", "markdown", tr("flag.format", strings.Join(sessionFormatNames(), ", ")))
+	templatePath := showCmd.String("template", "", tr("flag.template"))
+	messageRange := showCmd.String("messages", "", tr("flag.messages"))
+	redact := showCmd.Bool("redact", false, tr("flag.redact.show"))
+	anonymizeRules := showCmd.String("anonymize", "", tr("flag.anonymize"))
+	showCmd.BoolVar(&markdownFrontMatter, "front-matter", false, tr("flag.front-matter"))
+	parseTokenFlags := registerTokenFlags(showCmd)
+	parseDiffFlags := registerDiffFlags(showCmd)
+
+	// hash参数可以在选项之前或之后
+	var query string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		query, args = args[0], args[1:]
+	}
+	parseFlags(showCmd, args)
+	if query == "" {
+		query = strings.Join(showCmd.Args(), " ")
+	}
+	// HTML通常用于分享，未指定-redact时默认脱敏
+	redactSet := false
+	showCmd.Visit(func(f *flag.Flag) { redactSet = redactSet || f.Name == "redact" })
+	if !redactSet && *format == "html" && *templatePath == "" {
+		*redact = true
+	}
+	if query == "" {
+		err := withCode(codeInvalidArg, errors.New(tr("usage.show")))
+		failed(err)
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if !dbPath.setDefault() {
+		err := withCode(codeDBNotFound, errors.New(tr("err.defaultDB")))
+		failed(err)
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	anon, err := loadAnonymizer(*anonymizeRules)
+	if err == nil {
+		err = parseTokenFlags()
+	}
+	if err == nil {
+		err = parseDiffFlags()
+	}
+	if err != nil {
+		failed(withCode(codeInvalidArg, err))
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if err := showSession(dbPath, query, *format, *templatePath, *messageRange, *redact, anon); err != nil {
+		failed(err)
+		fmt.Fprintln(os.Stderr, tr("show.failed", err))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 消息单独保存在气泡记录中的会话，包含未知字段、大整数和转义字符
+const (
+	rawComposerID = "44444444-dddd-4000-8000-000000000004"
+	rawComposer   = `{"composerId":"44444444-dddd-4000-8000-000000000004","name":"Raw <data>","createdAt":1704099600000,"unifiedMode":"agent","big":12345678901234567890,"fullConversationHeadersOnly":[{"bubbleId":"b1","type":1},{"bubbleId":"b2","type":2}]}`
+	rawBubble1    = `{"bubbleId":"b1","type":1,"text":"café","richText":"{\"root\":{}}","timingInfo":{"clientStartTime":1704099600000}}`
+	rawBubble2    = `{"bubbleId":"b2","type":2,"text":"ok","toolFormerData":{"name":"read_file","rawArgs":"{}"},"tokenCount":{"inputTokens":1.0e3}}`
+)
+
+func newRawFixtureDB(t *testing.T) string {
+	t.Helper()
+	path := writeFixtureDB(t, map[string]string{rawComposerID: rawComposer})
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for id, value := range map[string]string{"b1": rawBubble1, "b2": rawBubble2} {
+		if _, err := db.Exec("INSERT INTO cursorDiskKV (key, value) VALUES (?, ?)", "bubbleId:"+rawComposerID+":"+id, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// -format raw只调整缩进，去掉空白后与数据库中的值逐字节相同
+func TestShowRaw(t *testing.T) {
+	isolateConfig(t)
+	path := newRawFixtureDB(t)
+	var err error
+	out := captureStdout(t, func() { err = showSession([]string{path}, rawComposerID, "raw", "", "", false, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		ComposerData json.RawMessage            `json:"composerData"`
+		Bubbles      map[string]json.RawMessage `json:"bubbles"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	compact := func(data []byte) string {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	if got := compact(doc.ComposerData); got != rawComposer {
+		t.Errorf("composerData =\n%s\nwant\n%s", got, rawComposer)
+	}
+	if len(doc.Bubbles) != 2 || compact(doc.Bubbles["b1"]) != rawBubble1 || compact(doc.Bubbles["b2"]) != rawBubble2 {
+		t.Errorf("bubbles = %v, want b1 and b2 unchanged", doc.Bubbles)
+	}
+
+	// 脱敏后没有原始数据，不能输出raw格式
+	captureStdout(t, func() { err = showSession([]string{path}, rawComposerID, "raw", "", "", true, nil) })
+	if errorCode(err) != codeInvalidArg {
+		t.Errorf("raw with -redact: got %v, want invalid-arg", err)
+	}
+	captureStdout(t, func() { err = showSession([]string{path}, rawComposerID, "raw", "", "1-2", false, nil) })
+	if errorCode(err) != codeInvalidArg {
+		t.Errorf("raw with -messages: got %v, want invalid-arg", err)
+	}
+}
+
+// 模板可以通过.Field读取未解析的字段
+func TestShowTemplateField(t *testing.T) {
+	isolateConfig(t)
+	path := newRawFixtureDB(t)
+	tmpl := filepath.Join(t.TempDir(), "session.tmpl")
+	content := `{{.Name}} {{.Field "unifiedMode"}} {{printf "%v" (.Field "missing")}}
+{{range .Conversation}}{{.Field "bubbleId"}}:{{.Text}}{{with .Field "toolFormerData"}} {{json .}}{{end}}
+{{end}}`
+	if err := os.WriteFile(tmpl, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	out := captureStdout(t, func() { err = showSession([]string{path}, rawComposerID, "markdown", tmpl, "", false, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Raw <data> agent <nil>\n" +
+		"b1:café\n" +
+		"b2:ok {\n  \"name\": \"read_file\",\n  \"rawArgs\": \"{}\"\n}\n"
+	if out != want {
+		t.Errorf("template output =\n%s\nwant\n%s", out, want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 可以重复指定的-db参数，每个值是数据库文件或包含数据库副本的目录
+// 指定多个数据库时合并其中的会话，同一会话只保留最完整或最新的版本
+type dbPaths []string
+
+func (p *dbPaths) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(*p, ", ")
+}
+
+func (p *dbPaths) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
+func (p *dbPaths) repeatable() {}
+
+// 可以重复指定的-archive参数，归档追加到-db参数的列表中
+type archivePaths struct {
+	paths *dbPaths
+}
+
+func (a archivePaths) String() string {
+	return ""
+}
+
+func (a archivePaths) Set(v string) error {
+	// 文件不存在时与-db相同，由打开数据库时报告
+	if ok, err := store.IsArchive(v); err == nil && !ok {
+		return errors.New(tr("err.notArchive", v))
+	}
+	return a.paths.Set(v)
+}
+
+func (a archivePaths) repeatable() {}
+
+// 注册-db和-archive参数，两者指定的文件都作为读取会话的数据库
+// 归档中的cursorDiskKV和ItemTable视图与state.vscdb的结构相同，因此归档可以与数据库一起合并
+func registerDBFlags(fs *flag.FlagSet, paths *dbPaths) {
+	fs.Var(paths, "db", tr("flag.db"))
+	fs.Var(archivePaths{paths}, "archive", tr("flag.archive"))
+}
+
+// 没有指定-db和-archive时使用系统默认路径，无法确定默认路径时返回false
+func (p *dbPaths) setDefault() bool {
+	if len(*p) > 0 {
+		return true
+	}
+	path := getDefaultDBPath()
+	if path == "" {
+		return false
+	}
+	*p = dbPaths{path}
+	return true
+}
+
+// 展开-db参数中的目录，返回所有数据库文件
+// 目录中的.vscdb文件按路径排序，重复的路径只保留第一个，合并时先出现的数据库优先
+func expandDBPaths(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			return nil, withCode(codeDBNotFound, errors.New(tr("err.dbNotExist", p)))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(p)
+			continue
+		}
+		found := false
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), ".vscdb") {
+				add(path)
+				found = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, withCode(codeDBNotFound, errors.New(tr("err.dbNotInDir", p)))
+		}
+	}
+	return files, nil
+}
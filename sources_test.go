@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// 目录中的.vscdb文件按路径排序，重复的路径只保留第一次出现的位置
+func TestExpandDBPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b/desktop.vscdb", "a/laptop.vscdb", "a/nested/old.vscdb", "a/notes.txt", "a/state.vscdb.backup"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	join := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	got, err := expandDBPaths([]string{
+		join("b/desktop.vscdb"),
+		dir,
+		join("a/./laptop.vscdb"),
+		join("a") + string(filepath.Separator),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{join("b/desktop.vscdb"), join("a/laptop.vscdb"), join("a/nested/old.vscdb")}
+	if !slices.Equal(got, want) {
+		t.Errorf("expandDBPaths = %q, want %q", got, want)
+	}
+
+	for _, path := range []string{join("missing.vscdb"), empty} {
+		if _, err := expandDBPaths([]string{dir, path}); errorCode(err) != codeDBNotFound {
+			t.Errorf("expandDBPaths(%s) error = %v, want db-not-found", path, err)
+		}
+	}
+}
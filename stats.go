@@ -0,0 +1,544 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 某个时间段内的会话、消息和token数量
+type PeriodCount struct {
+	Period   string  `json:"period"`
+	Sessions int     `json:"sessions"`
+	Messages int     `json:"messages"`
+	Tokens   int     `json:"tokens"` // 估算的token数量
+	Cost     float64 `json:"cost"`   // 估算费用（美元）
+}
+
+// 名称和出现次数
+type NamedCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// AI回复耗时统计，单位为毫秒
+type LatencyStats struct {
+	Count    int   `json:"count"`
+	AvgMs    int64 `json:"avgMs"`
+	MedianMs int64 `json:"medianMs"`
+	P90Ms    int64 `json:"p90Ms"`
+	MaxMs    int64 `json:"maxMs"`
+}
+
+// 使用情况统计
+type UsageStats struct {
+	Sessions           int           `json:"sessions"`
+	Messages           int           `json:"messages"`
+	UserMessages       int           `json:"userMessages"`
+	AssistantMessages  int           `json:"assistantMessages"`
+	AvgTurnsPerSession float64       `json:"avgTurnsPerSession"`
+	FirstSession       time.Time     `json:"firstSession"`
+	LastSession        time.Time     `json:"lastSession"`
+	Daily              []PeriodCount `json:"daily"`
+	Weekly             []PeriodCount `json:"weekly"`
+	Monthly            []PeriodCount `json:"monthly"`
+	Latency            LatencyStats  `json:"latency"`
+	TopFiles           []NamedCount  `json:"topFiles"`
+	TopDirectories     []NamedCount  `json:"topDirectories"`
+	Languages          []NamedCount  `json:"languages"`
+	Hours              [24]int       `json:"hours"` // 每个小时（本地时间）的消息数量
+	Tokens             TokenUsage    `json:"tokens"`
+	TokenProjects      []NamedTokens `json:"tokenProjects"` // 按工作区汇总的token数量，按费用降序
+	TokenModels        []NamedTokens `json:"tokenModels"`   // 按模型汇总的token数量，按费用降序
+}
+
+// 按名称汇总的token数量和费用
+type NamedTokens struct {
+	Name string `json:"name"`
+	TokenUsage
+}
+
+type StatsResponse struct {
+	Stats   *UsageStats `json:"stats"`
+	Success bool        `json:"success"`
+	Error   *string     `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+}
+
+// 消息的时间，缺少计时信息时使用会话开始时间
+func messageTime(msg Message, session SessionInfo) time.Time {
+	if msg.TimingInfo.ClientStartTime > 0 {
+		return time.UnixMilli(msg.TimingInfo.ClientStartTime)
+	}
+	return session.StartTime
+}
+
+// ISO周，例如 2024-W01
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// 将计数表转换为按次数降序排列的列表，limit为0时返回全部
+func topCounts(counts map[string]int, limit int) []NamedCount {
+	result := make([]NamedCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, NamedCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// 按时间段汇总的计数器
+type periodCounter struct {
+	sessions map[string]int
+	messages map[string]int
+	tokens   map[string]TokenUsage
+}
+
+func newPeriodCounter() *periodCounter {
+	return &periodCounter{sessions: make(map[string]int), messages: make(map[string]int), tokens: make(map[string]TokenUsage)}
+}
+
+// 累加时间段的token数量
+func (p *periodCounter) addTokens(period string, usage TokenUsage) {
+	u := p.tokens[period]
+	u.add(usage)
+	p.tokens[period] = u
+}
+
+// 按时间段名称排序输出
+func (p *periodCounter) list() []PeriodCount {
+	periods := make(map[string]bool)
+	for k := range p.sessions {
+		periods[k] = true
+	}
+	for k := range p.messages {
+		periods[k] = true
+	}
+	result := make([]PeriodCount, 0, len(periods))
+	for period := range periods {
+		result = append(result, PeriodCount{
+			Period:   period,
+			Sessions: p.sessions[period],
+			Messages: p.messages[period],
+			Tokens:   p.tokens[period].Total,
+			Cost:     roundCost(p.tokens[period].Cost),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Period < result[j].Period })
+	return result
+}
+
+// 将token汇总表转换为按费用降序排列的列表，费用相同时按token数量降序，limit为0时返回全部
+func topTokens(usages map[string]TokenUsage, limit int) []NamedTokens {
+	result := make([]NamedTokens, 0, len(usages))
+	for name, usage := range usages {
+		usage.Cost = roundCost(usage.Cost)
+		result = append(result, NamedTokens{Name: name, TokenUsage: usage})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Name < result[j].Name
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// 保留6位小数，避免浮点累加误差
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
+
+// 计算会话集合的使用情况统计，top为文件、目录和项目排行的数量
+func computeStats(sessions []SessionInfo, top int, estimator *tokenEstimator) *UsageStats {
+	stats := &UsageStats{Sessions: len(sessions)}
+	daily, weekly, monthly := newPeriodCounter(), newPeriodCounter(), newPeriodCounter()
+	files := make(map[string]int)
+	dirs := make(map[string]int)
+	languages := make(map[string]int)
+	projects := make(map[string]TokenUsage)
+	models := make(map[string]TokenUsage)
+	var latencies []int64
+
+	addFile := func(path string) {
+		if path == "" {
+			return
+		}
+		files[path]++
+		dirs[filepath.Dir(path)]++
+	}
+
+	for _, s := range sessions {
+		if stats.FirstSession.IsZero() || s.StartTime.Before(stats.FirstSession) {
+			stats.FirstSession = s.StartTime
+		}
+		if s.StartTime.After(stats.LastSession) {
+			stats.LastSession = s.StartTime
+		}
+		daily.sessions[s.StartTime.Format("2006-01-02")]++
+		weekly.sessions[isoWeek(s.StartTime)]++
+		monthly.sessions[s.StartTime.Format("2006-01")]++
+
+		for _, file := range s.Record.Context.FileSelections {
+			addFile(file.Uri.Path)
+		}
+
+		model := estimator.sessionModel(*s.Record)
+		project := s.Workspace
+		if project == "" {
+			project = "(unknown)"
+		}
+		for _, msg := range s.Record.Conversation {
+			stats.Messages++
+			t := messageTime(msg, s)
+			daily.messages[t.Format("2006-01-02")]++
+			weekly.messages[isoWeek(t)]++
+			monthly.messages[t.Format("2006-01")]++
+			stats.Hours[t.Hour()]++
+
+			usage := estimator.messageUsage(msg, model)
+			stats.Tokens.add(usage)
+			daily.addTokens(t.Format("2006-01-02"), usage)
+			weekly.addTokens(isoWeek(t), usage)
+			monthly.addTokens(t.Format("2006-01"), usage)
+			p := projects[project]
+			p.add(usage)
+			projects[project] = p
+			m := models[model]
+			m.add(usage)
+			models[model] = m
+
+			switch msg.Type {
+			case 1:
+				stats.UserMessages++
+			case 2:
+				stats.AssistantMessages++
+				start, end := msg.TimingInfo.ClientStartTime, msg.TimingInfo.ClientEndTime
+				if start > 0 && end >= start {
+					latencies = append(latencies, end-start)
+				}
+			}
+
+			for _, file := range msg.Context.FileSelections {
+				addFile(file.Uri.Path)
+			}
+			for _, sel := range msg.Context.Selections {
+				addFile(sel.Uri.Path)
+			}
+			for _, block := range msg.CodeBlocks {
+				addFile(block.Uri.Path)
+				lang := strings.ToLower(block.LanguageId)
+				if lang == "" {
+					lang = "unknown"
+				}
+				languages[lang]++
+			}
+		}
+	}
+
+	if stats.Sessions > 0 {
+		stats.AvgTurnsPerSession = float64(stats.Messages) / float64(stats.Sessions)
+	}
+	stats.Daily, stats.Weekly, stats.Monthly = daily.list(), weekly.list(), monthly.list()
+	stats.TopFiles = topCounts(files, top)
+	stats.TopDirectories = topCounts(dirs, top)
+	stats.Languages = topCounts(languages, 0)
+	stats.Tokens.Cost = roundCost(stats.Tokens.Cost)
+	stats.TokenProjects = topTokens(projects, top)
+	stats.TokenModels = topTokens(models, 0)
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		var sum int64
+		for _, l := range latencies {
+			sum += l
+		}
+		stats.Latency = LatencyStats{
+			Count:    len(latencies),
+			AvgMs:    sum / int64(len(latencies)),
+			MedianMs: latencies[len(latencies)/2],
+			P90Ms:    nearestRank(latencies, 0.9),
+			MaxMs:    latencies[len(latencies)-1],
+		}
+	}
+	return stats
+}
+
+// 按最近秩法计算已排序数据的百分位数，p在0到1之间
+func nearestRank(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// 格式化毫秒耗时
+func formatDurationMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+}
+
+// 以文本表格输出统计结果，by为时间段汇总方式: day, week, month
+func writeStatsText(w io.Writer, stats *UsageStats, by string) {
+	fmt.Fprintf(w, "%s:\t%d\n", tr("stats.sessions"), stats.Sessions)
+	fmt.Fprintf(w, "%s:\t%s\n", tr("stats.messages"), tr("stats.messageCounts", stats.Messages, stats.UserMessages, stats.AssistantMessages))
+	fmt.Fprintf(w, "%s:\t%.1f\n", tr("stats.avgMessages"), stats.AvgTurnsPerSession)
+	if stats.Sessions > 0 {
+		fmt.Fprintf(w, "%s:\t%s ~ %s\n", tr("stats.range"), stats.FirstSession.Format("2006-01-02"), stats.LastSession.Format("2006-01-02"))
+	}
+	if stats.Latency.Count > 0 {
+		fmt.Fprintf(w, "%s:\t%s\n", tr("stats.latency"), tr("stats.latencyValues",
+			formatDurationMs(stats.Latency.AvgMs), formatDurationMs(stats.Latency.MedianMs),
+			formatDurationMs(stats.Latency.P90Ms), formatDurationMs(stats.Latency.MaxMs), stats.Latency.Count))
+	}
+	fmt.Fprintf(w, "%s:\t%s\n", tr("md.tokens"), tr("stats.tokenValues",
+		stats.Tokens.Input, stats.Tokens.Output, stats.Tokens.Total, formatCost(stats.Tokens.Cost)))
+
+	periods, title := stats.Monthly, tr("stats.monthly")
+	switch by {
+	case "day":
+		periods, title = stats.Daily, tr("stats.daily")
+	case "week":
+		periods, title = stats.Weekly, tr("stats.weekly")
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	fmt.Fprintf(w, "  %-10s  %8s  %8s  %10s  %10s\n",
+		tr("column.period"), tr("column.sessions"), tr("column.messages"), tr("column.tokens"), tr("column.cost"))
+	for _, p := range periods {
+		fmt.Fprintf(w, "  %-10s  %8d  %8d  %10d  %10s\n", p.Period, p.Sessions, p.Messages, p.Tokens, formatCost(p.Cost))
+	}
+
+	writeTokens := func(title string, usages []NamedTokens) {
+		if len(usages) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s:\n", title)
+		fmt.Fprintf(w, "  %10s  %10s  %10s  %10s  %s\n",
+			tr("column.input"), tr("column.output"), tr("column.total"), tr("column.cost"), tr("column.name"))
+		for _, u := range usages {
+			fmt.Fprintf(w, "  %10d  %10d  %10d  %10s  %s\n", u.Input, u.Output, u.Total, formatCost(u.Cost), u.Name)
+		}
+	}
+	writeTokens(tr("stats.tokenProjects"), stats.TokenProjects)
+	writeTokens(tr("stats.tokenModels"), stats.TokenModels)
+
+	writeCounts := func(title string, counts []NamedCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s:\n", title)
+		for _, c := range counts {
+			fmt.Fprintf(w, "  %6d  %s\n", c.Count, c.Name)
+		}
+	}
+	writeCounts(tr("stats.topFiles"), stats.TopFiles)
+	writeCounts(tr("stats.topDirectories"), stats.TopDirectories)
+	writeCounts(tr("stats.languages"), stats.Languages)
+
+	maxHour := 0
+	for _, n := range stats.Hours {
+		if n > maxHour {
+			maxHour = n
+		}
+	}
+	if maxHour > 0 {
+		fmt.Fprintf(w, "\n%s:\n", tr("stats.hours"))
+		for hour, n := range stats.Hours {
+			bar := strings.Repeat("#", n*40/maxHour)
+			if n > 0 && bar == "" {
+				bar = "#"
+			}
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %02d:00  %6d  %s", hour, n, bar), " "))
+		}
+	}
+}
+
+// 以CSV格式输出统计结果，每行为 section,key,metric,value
+func writeStatsCSV(w io.Writer, stats *UsageStats) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"section", "key", "metric", "value"},
+		{"summary", "", "sessions", strconv.Itoa(stats.Sessions)},
+		{"summary", "", "messages", strconv.Itoa(stats.Messages)},
+		{"summary", "", "userMessages", strconv.Itoa(stats.UserMessages)},
+		{"summary", "", "assistantMessages", strconv.Itoa(stats.AssistantMessages)},
+		{"summary", "", "avgTurnsPerSession", strconv.FormatFloat(stats.AvgTurnsPerSession, 'f', 2, 64)},
+		{"latency", "", "count", strconv.Itoa(stats.Latency.Count)},
+		{"latency", "", "avgMs", strconv.FormatInt(stats.Latency.AvgMs, 10)},
+		{"latency", "", "medianMs", strconv.FormatInt(stats.Latency.MedianMs, 10)},
+		{"latency", "", "p90Ms", strconv.FormatInt(stats.Latency.P90Ms, 10)},
+		{"latency", "", "maxMs", strconv.FormatInt(stats.Latency.MaxMs, 10)},
+		{"tokens", "", "input", strconv.Itoa(stats.Tokens.Input)},
+		{"tokens", "", "output", strconv.Itoa(stats.Tokens.Output)},
+		{"tokens", "", "total", strconv.Itoa(stats.Tokens.Total)},
+		{"tokens", "", "cost", strconv.FormatFloat(stats.Tokens.Cost, 'f', 6, 64)},
+	}
+	for _, section := range []struct {
+		name    string
+		periods []PeriodCount
+	}{{"daily", stats.Daily}, {"weekly", stats.Weekly}, {"monthly", stats.Monthly}} {
+		for _, p := range section.periods {
+			rows = append(rows,
+				[]string{section.name, p.Period, "sessions", strconv.Itoa(p.Sessions)},
+				[]string{section.name, p.Period, "messages", strconv.Itoa(p.Messages)},
+				[]string{section.name, p.Period, "tokens", strconv.Itoa(p.Tokens)},
+				[]string{section.name, p.Period, "cost", strconv.FormatFloat(p.Cost, 'f', 6, 64)})
+		}
+	}
+	for _, section := range []struct {
+		name   string
+		usages []NamedTokens
+	}{{"project", stats.TokenProjects}, {"model", stats.TokenModels}} {
+		for _, u := range section.usages {
+			rows = append(rows,
+				[]string{section.name, u.Name, "inputTokens", strconv.Itoa(u.Input)},
+				[]string{section.name, u.Name, "outputTokens", strconv.Itoa(u.Output)},
+				[]string{section.name, u.Name, "cost", strconv.FormatFloat(u.Cost, 'f', 6, 64)})
+		}
+	}
+	for _, section := range []struct {
+		name   string
+		counts []NamedCount
+	}{{"file", stats.TopFiles}, {"directory", stats.TopDirectories}, {"language", stats.Languages}} {
+		for _, c := range section.counts {
+			rows = append(rows, []string{section.name, c.Name, "count", strconv.Itoa(c.Count)})
+		}
+	}
+	for hour, n := range stats.Hours {
+		rows = append(rows, []string{"hour", fmt.Sprintf("%02d", hour), "messages", strconv.Itoa(n)})
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return errors.New(tr("stats.csv", err))
+	}
+	return nil
+}
+
+// 输出stats命令的JSON错误
+func printStatsError(err error) {
+	errMsg := err.Error()
+	jsonData, _ := json.MarshalIndent(StatsResponse{Success: false, Error: &errMsg, Code: errorCode(err)}, "", "  ")
+	fmt.Println(string(jsonData))
+}
+
+// stats命令入口
+func runStats(args []string) {
+	var config Config
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	registerDBFlags(statsCmd, &config.DBPaths)
+	format := statsCmd.String("format", "text", tr("flag.format.stats"))
+	statsCmd.BoolVar(&config.JsonOutput, "json", false, tr("flag.json.stats"))
+	by := statsCmd.String("by", "month", tr("flag.by"))
+	top := statsCmd.Int("top", 10, tr("flag.top"))
+	svgPath := statsCmd.String("svg", "", tr("flag.svg"))
+	svgFrom := statsCmd.String("svg-from", "", tr("flag.svg-from"))
+	svgTo := statsCmd.String("svg-to", "", tr("flag.svg-to"))
+	svgColors := statsCmd.String("svg-colors", strings.Join(defaultHeatmapColors, ","), tr("flag.svg-colors"))
+	parseTimeFilters := registerTimeFilterFlags(statsCmd, &config)
+	parseTokenFlags := registerTokenFlags(statsCmd)
+	parseFlags(statsCmd, args)
+
+	if config.JsonOutput {
+		*format = "json"
+	}
+	fail := func(err error) {
+		failed(err)
+		if *format == "json" {
+			printStatsError(err)
+			return
+		}
+		fmt.Println(err)
+	}
+
+	if err := parseTimeFilters(); err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+	if err := parseTokenFlags(); err != nil {
+		fail(withCode(codeInvalidArg, err))
+		return
+	}
+	switch *format {
+	case "text", "json", "csv":
+	default:
+		fail(withCode(codeInvalidArg, errors.New(tr("err.format", *format, "text, json, csv"))))
+		return
+	}
+	switch *by {
+	case "day", "week", "month":
+	default:
+		fail(withCode(codeInvalidArg, errors.New(tr("stats.by", *by))))
+		return
+	}
+
+	if !config.DBPaths.setDefault() {
+		fail(withCode(codeDBNotFound, errors.New(tr("err.defaultDB"))))
+		return
+	}
+	db, err := openDB(config.DBPaths)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+
+	sessions, _, err := loadSessions(db, config)
+	if err != nil {
+		fail(err)
+		return
+	}
+	stats := computeStats(sessions, *top, defaultTokenEstimator)
+
+	if *svgPath != "" {
+		if err := writeStatsSVG(*svgPath, stats, *svgFrom, *svgTo, *svgColors); err != nil {
+			fail(err)
+			return
+		}
+		if *format == "text" {
+			fmt.Printf("%s\n\n", tr("stats.svg", *svgPath))
+		}
+	}
+
+	switch *format {
+	case "json":
+		jsonData, err := json.MarshalIndent(StatsResponse{Stats: stats, Success: true}, "", "  ")
+		if err != nil {
+			fail(errors.New(tr("err.json", err)))
+			return
+		}
+		fmt.Println(string(jsonData))
+	case "csv":
+		if err := writeStatsCSV(os.Stdout, stats); err != nil {
+			failed(withCode(codeWriteError, err))
+			fmt.Fprintln(os.Stderr, err)
+		}
+	default:
+		writeStatsText(os.Stdout, stats, *by)
+	}
+}
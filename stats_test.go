@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/M6ZeroG/cursor2md/store"
+)
+
+// 两个会话：第一个在1月31日，第二个在2月1日，其中一条消息没有计时信息
+func statsFixture() []SessionInfo {
+	at := func(month time.Month, day, hour, minute int) int64 {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.Local).UnixMilli()
+	}
+	file := func(path string) store.FileSelection { return store.FileSelection{Uri: store.Uri{Path: path}} }
+	first := ChatRecord{Name: "first", Conversation: []Message{
+		{Type: 1, Text: "explain main.go", TimingInfo: store.TimingInfo{ClientStartTime: at(1, 31, 10, 0)},
+			Context: store.MessageContext{FileSelections: []store.FileSelection{file("/ws/a/main.go")}}},
+		{Type: 2, Text: "it prints hello", TimingInfo: store.TimingInfo{ClientStartTime: at(1, 31, 10, 1), ClientEndTime: at(1, 31, 10, 1) + 1000},
+			CodeBlocks: []store.CodeBlock{{Uri: store.Uri{Path: "/ws/a/main.go"}, LanguageId: "Go", Content: "package main"}, {Content: "echo"}}},
+	}}
+	second := ChatRecord{Name: "second", Conversation: []Message{
+		{Type: 1, Text: "fix x.py", TimingInfo: store.TimingInfo{ClientStartTime: at(2, 1, 9, 0)},
+			Context: store.MessageContext{
+				Selections:     []store.Selection{{Text: "x = 1", Uri: store.Uri{Path: "/ws/b/x.py"}}},
+				FileSelections: []store.FileSelection{file("/ws/b/with,comma.txt")},
+			}},
+		{Type: 2, Text: "done", TimingInfo: store.TimingInfo{ClientStartTime: at(2, 1, 9, 5), ClientEndTime: at(2, 1, 9, 5) + 3000},
+			CodeBlocks: []store.CodeBlock{{Uri: store.Uri{Path: "/ws/b/x.py"}, LanguageId: "python", Content: "x = 2"}}},
+		{Type: 1, Text: "thanks"},
+	}}
+	return []SessionInfo{
+		{Hash: "a", StartTime: time.UnixMilli(at(1, 31, 10, 0)), Workspace: "/ws/a", Record: &first},
+		{Hash: "b", StartTime: time.UnixMilli(at(2, 1, 9, 0)), Workspace: "/ws/b", Record: &second},
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	stats := computeStats(statsFixture(), 10, defaultTokenEstimator)
+
+	if stats.Sessions != 2 || stats.Messages != 5 || stats.UserMessages != 3 || stats.AssistantMessages != 2 || stats.AvgTurnsPerSession != 2.5 {
+		t.Errorf("summary = %d sessions, %d messages (%d user, %d assistant), %.2f per session; want 2, 5 (3, 2), 2.50",
+			stats.Sessions, stats.Messages, stats.UserMessages, stats.AssistantMessages, stats.AvgTurnsPerSession)
+	}
+	if want := (LatencyStats{Count: 2, AvgMs: 2000, MedianMs: 3000, P90Ms: 3000, MaxMs: 3000}); stats.Latency != want {
+		t.Errorf("latency = %+v, want %+v", stats.Latency, want)
+	}
+
+	periods := func(counts []PeriodCount) [][3]any {
+		var result [][3]any
+		for _, p := range counts {
+			result = append(result, [3]any{p.Period, p.Sessions, p.Messages})
+		}
+		return result
+	}
+	// 没有计时信息的消息计入会话开始的日期和小时
+	if got, want := periods(stats.Daily), [][3]any{{"2024-01-31", 1, 2}, {"2024-02-01", 1, 3}}; !slices.Equal(got, want) {
+		t.Errorf("daily = %v, want %v", got, want)
+	}
+	if got, want := periods(stats.Weekly), [][3]any{{"2024-W05", 2, 5}}; !slices.Equal(got, want) {
+		t.Errorf("weekly = %v, want %v", got, want)
+	}
+	if got, want := periods(stats.Monthly), [][3]any{{"2024-01", 1, 2}, {"2024-02", 1, 3}}; !slices.Equal(got, want) {
+		t.Errorf("monthly = %v, want %v", got, want)
+	}
+	if stats.Hours[10] != 2 || stats.Hours[9] != 3 {
+		t.Errorf("hours 9 and 10 = %d, %d, want 3, 2", stats.Hours[9], stats.Hours[10])
+	}
+
+	wantFiles := []NamedCount{{"/ws/a/main.go", 2}, {"/ws/b/x.py", 2}, {"/ws/b/with,comma.txt", 1}}
+	if !slices.Equal(stats.TopFiles, wantFiles) {
+		t.Errorf("top files = %v, want %v", stats.TopFiles, wantFiles)
+	}
+	if want := []NamedCount{{"/ws/b", 3}, {"/ws/a", 2}}; !slices.Equal(stats.TopDirectories, want) {
+		t.Errorf("top directories = %v, want %v", stats.TopDirectories, want)
+	}
+	if want := []NamedCount{{"go", 1}, {"python", 1}, {"unknown", 1}}; !slices.Equal(stats.Languages, want) {
+		t.Errorf("languages = %v, want %v", stats.Languages, want)
+	}
+
+	if stats.Tokens.Total == 0 || stats.Tokens.Total != stats.Tokens.Input+stats.Tokens.Output {
+		t.Errorf("tokens = %+v, want a positive total of input and output", stats.Tokens)
+	}
+	if len(stats.TokenProjects) != 2 {
+		t.Errorf("token projects = %v, want /ws/a and /ws/b", stats.TokenProjects)
+	}
+
+	// top只限制文件、目录和项目排行
+	limited := computeStats(statsFixture(), 1, defaultTokenEstimator)
+	if len(limited.TopFiles) != 1 || len(limited.TopDirectories) != 1 || len(limited.TokenProjects) != 1 || len(limited.Languages) != 3 {
+		t.Errorf("with top 1: %d files, %d directories, %d projects, %d languages; want 1, 1, 1, 3",
+			len(limited.TopFiles), len(limited.TopDirectories), len(limited.TokenProjects), len(limited.Languages))
+	}
+
+	if empty := computeStats(nil, 10, defaultTokenEstimator); empty.Sessions != 0 || empty.Latency.Count != 0 || empty.AvgTurnsPerSession != 0 {
+		t.Errorf("stats without sessions = %+v", empty)
+	}
+}
+
+// 最近秩法: 10个值的P90是第9个，而不是最大值
+func TestNearestRank(t *testing.T) {
+	tests := []struct {
+		n    int
+		p    float64
+		want int64
+	}{
+		{10, 0.9, 9},
+		{10, 0.5, 5},
+		{11, 0.9, 10},
+		{1, 0.9, 1},
+		{2, 0.9, 2},
+		{10, 0, 1},
+	}
+	for _, tt := range tests {
+		sorted := make([]int64, tt.n)
+		for i := range sorted {
+			sorted[i] = int64(i + 1)
+		}
+		if got := nearestRank(sorted, tt.p); got != tt.want {
+			t.Errorf("nearestRank(%v, %v) = %d, want %d", sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestTopCounts(t *testing.T) {
+	counts := map[string]int{"b": 2, "a": 2, "c": 5}
+	if got, want := topCounts(counts, 0), []NamedCount{{"c", 5}, {"a", 2}, {"b", 2}}; !slices.Equal(got, want) {
+		t.Errorf("topCounts(limit 0) = %v, want %v", got, want)
+	}
+	if got, want := topCounts(counts, 2), []NamedCount{{"c", 5}, {"a", 2}}; !slices.Equal(got, want) {
+		t.Errorf("topCounts(limit 2) = %v, want %v", got, want)
+	}
+	if got := topCounts(nil, 3); got == nil || len(got) != 0 {
+		t.Errorf("topCounts(nil) = %#v, want an empty list", got)
+	}
+}
+
+// CSV中每行为section,key,metric,value，名称中的逗号经过转义
+func TestWriteStatsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeStatsCSV(&buf, computeStats(statsFixture(), 10, defaultTokenEstimator)); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(records[0], []string{"section", "key", "metric", "value"}) {
+		t.Errorf("header = %v", records[0])
+	}
+	rows := make(map[[3]string]string)
+	for _, r := range records[1:] {
+		if len(r) != 4 {
+			t.Fatalf("row %v has %d fields, want 4", r, len(r))
+		}
+		rows[[3]string{r[0], r[1], r[2]}] = r[3]
+	}
+	for key, want := range map[[3]string]string{
+		{"summary", "", "sessions"}:               "2",
+		{"summary", "", "avgTurnsPerSession"}:     "2.50",
+		{"latency", "", "p90Ms"}:                  "3000",
+		{"daily", "2024-01-31", "messages"}:       "2",
+		{"weekly", "2024-W05", "sessions"}:        "2",
+		{"monthly", "2024-02", "messages"}:        "3",
+		{"file", "/ws/b/with,comma.txt", "count"}: "1",
+		{"directory", "/ws/b", "count"}:           "3",
+		{"language", "unknown", "count"}:          "1",
+		{"hour", "09", "messages"}:                "3",
+		{"hour", "23", "messages"}:                "0",
+	} {
+		if got, ok := rows[key]; !ok || got != want {
+			t.Errorf("%v = %q (present %v), want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := rows[[3]string{"project", "/ws/a", "cost"}]; !ok {
+		t.Error("missing project rows")
+	}
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// composerData中用于判断存储格式的字段
+type FormatHeader struct {
+	Version      int  // _v字段，没有时为0
+	Conversation bool // 包含非空的conversation数组，消息直接保存在composerData中
+	Headers      bool // 包含fullConversationHeadersOnly，消息单独保存在bubbleId:<composerId>:<bubbleId>中
+}
+
+// 解析composerData中的格式字段
+func detectHeader(value []byte) (FormatHeader, error) {
+	var doc struct {
+		Version      int             `json:"_v"`
+		Conversation json.RawMessage `json:"conversation"`
+		Headers      json.RawMessage `json:"fullConversationHeadersOnly"`
+	}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return FormatHeader{}, fmt.Errorf("parse JSON: %v", err)
+	}
+	nonEmpty := func(raw json.RawMessage) bool {
+		s := strings.TrimSpace(string(raw))
+		return s != "" && s != "null" && s != "[]"
+	}
+	return FormatHeader{
+		Version:      doc.Version,
+		Conversation: nonEmpty(doc.Conversation),
+		Headers:      nonEmpty(doc.Headers),
+	}, nil
+}
+
+// 一种存储格式的适配器，将该格式转换为通用的会话模型
+// 处理composerData的适配器设置Detect和Convert，从其他位置读取会话的适配器设置Load
+type Adapter struct {
+	Name        string
+	Description string
+	// 判断composerData是否为该格式
+	Detect func(header FormatHeader) bool
+	// 将composerData转换为会话记录，不需要计算EndedAt
+	Convert func(ctx context.Context, s *Store, hash string, value []byte) (ChatRecord, error)
+	// 读取composerData以外的会话，例如旧版本保存在ItemTable中的聊天
+	Load func(ctx context.Context, s *Store) ([]Session, error)
+}
+
+// 已注册的适配器，按注册顺序匹配，第一个匹配的适配器处理该记录
+var adapters []Adapter
+
+// 注册适配器，同名的适配器会被替换
+func RegisterAdapter(a Adapter) {
+	for i := range adapters {
+		if adapters[i].Name == a.Name {
+			adapters[i] = a
+			return
+		}
+	}
+	adapters = append(adapters, a)
+}
+
+// 返回已注册的适配器
+func Adapters() []Adapter {
+	return append([]Adapter(nil), adapters...)
+}
+
+// 查找处理该composerData的适配器
+func matchAdapter(header FormatHeader) (Adapter, bool) {
+	for _, a := range adapters {
+		if a.Detect != nil && a.Detect(header) {
+			return a, true
+		}
+	}
+	return Adapter{}, false
+}
+
+func init() {
+	RegisterAdapter(Adapter{
+		Name:        "composer-headers",
+		Description: "composerData only keeps fullConversationHeadersOnly, messages are stored under bubbleId:<composerId>:<bubbleId> (newer versions)",
+		Detect:      func(h FormatHeader) bool { return h.Headers && !h.Conversation },
+		Convert:     convertHeadersComposer,
+	})
+	RegisterAdapter(Adapter{
+		Name:        "composer-inline",
+		Description: "messages are stored inline in the conversation array of composerData (0.43 and later)",
+		Detect:      func(h FormatHeader) bool { return true },
+		Convert:     convertInlineComposer,
+	})
+	RegisterAdapter(Adapter{
+		Name:        "legacy-aichat",
+		Description: "legacy chat panel data stored in ItemTable under " + legacyChatKey,
+		Load:        loadLegacyChat,
+	})
+}
+
+// 消息直接保存在conversation中的格式
+func convertInlineComposer(ctx context.Context, s *Store, hash string, value []byte) (ChatRecord, error) {
+	var record ChatRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return ChatRecord{}, fmt.Errorf("parse JSON: %v", err)
+	}
+	return record, nil
+}
+
+// 消息保存在单独的气泡记录中的格式，按fullConversationHeadersOnly的顺序组装消息
+// 找不到的气泡会被跳过
+func convertHeadersComposer(ctx context.Context, s *Store, hash string, value []byte) (ChatRecord, error) {
+	var record ChatRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return ChatRecord{}, fmt.Errorf("parse JSON: %v", err)
+	}
+	var doc struct {
+		Headers []struct {
+			BubbleId string `json:"bubbleId"`
+			Type     int    `json:"type"`
+		} `json:"fullConversationHeadersOnly"`
+	}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return ChatRecord{}, fmt.Errorf("parse fullConversationHeadersOnly: %v", err)
+	}
+	bubbles, err := s.RawBubbles(ctx, hash)
+	if err != nil {
+		return ChatRecord{}, err
+	}
+
+	record.Conversation = nil
+	for _, h := range doc.Headers {
+		raw, ok := bubbles[h.BubbleId]
+		if !ok {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			continue
+		}
+		if msg.Type == 0 {
+			msg.Type = h.Type
+		}
+		record.Conversation = append(record.Conversation, msg)
+	}
+	return record, nil
+}
+
+// 旧版本聊天面板数据在ItemTable中的键
+const legacyChatKey = "workbench.panel.aichat.view.aichat.chatdata"
+
+// 旧版本聊天面板的数据
+type legacyChatData struct {
+	Tabs []struct {
+		TabId        string `json:"tabId"`
+		ChatTitle    string `json:"chatTitle"`
+		LastSendTime int64  `json:"lastSendTime"`
+		Bubbles      []struct {
+			Type       string      `json:"type"` // user或ai
+			Text       string      `json:"text"`
+			RawText    string      `json:"rawText"`
+			Selections []Selection `json:"selections"`
+			CodeBlocks []CodeBlock `json:"codeBlocks"`
+		} `json:"bubbles"`
+	} `json:"tabs"`
+}
+
+// 读取ItemTable中旧版本聊天面板的会话，没有该记录时返回空
+func loadLegacyChat(ctx context.Context, s *Store) ([]Session, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM ItemTable WHERE key = ?", legacyChatKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %v", legacyChatKey, err)
+	}
+	var data legacyChatData
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", legacyChatKey, err)
+	}
+
+	var sessions []Session
+	for _, tab := range data.Tabs {
+		if tab.TabId == "" {
+			continue
+		}
+		record := ChatRecord{Name: tab.ChatTitle, CreatedAt: tab.LastSendTime}
+		for _, b := range tab.Bubbles {
+			msg := Message{Text: b.Text, CodeBlocks: b.CodeBlocks}
+			if msg.Text == "" {
+				msg.Text = b.RawText
+			}
+			switch b.Type {
+			case "user":
+				msg.Type = 1
+			case "ai":
+				msg.Type = 2
+			}
+			msg.Context.Selections = b.Selections
+			msg.TimingInfo.ClientStartTime = tab.LastSendTime
+			msg.TimingInfo.ClientEndTime = tab.LastSendTime
+			record.Conversation = append(record.Conversation, msg)
+		}
+		if record.Name == "" {
+			record.Name = "Untitled chat"
+		}
+		raw, _ := json.Marshal(tab)
+		record.Raw = raw
+		session, err := newSession(tab.TabId, record, len(raw), "legacy-aichat")
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// 数据库中某种格式的统计
+type FormatCount struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Sessions    int    `json:"sessions"`           // 能转换为有效会话的数量
+	Invalid     int    `json:"invalid"`            // 识别为该格式但转换失败或没有消息的数量
+	Versions    []int  `json:"versions,omitempty"` // 出现过的_v值
+}
+
+// 数据库中存储格式的检测结果
+type FormatReport struct {
+	Formats    []FormatCount `json:"formats"`    // 所有已注册的适配器，包括没有找到记录的
+	Unknown    int           `json:"unknown"`    // 无法解析的composerData数量
+	BubbleKeys int           `json:"bubbleKeys"` // bubbleId:记录的数量
+	LegacyChat bool          `json:"legacyChat"` // 是否存在旧版本聊天面板数据
+}
+
+// 检测数据库中使用的存储格式
+func (s *Store) DetectFormats(ctx context.Context) (*FormatReport, error) {
+	report := &FormatReport{}
+	index := make(map[string]int)
+	versions := make(map[string]map[int]bool)
+	for _, a := range adapters {
+		index[a.Name] = len(report.Formats)
+		report.Formats = append(report.Formats, FormatCount{Name: a.Name, Description: a.Description})
+		versions[a.Name] = make(map[int]bool)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM cursorDiskKV WHERE key LIKE 'composerData:%'")
+	if err != nil {
+		return nil, fmt.Errorf("query database: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			report.Unknown++
+			continue
+		}
+		header, err := detectHeader(value)
+		if err != nil {
+			report.Unknown++
+			continue
+		}
+		a, ok := matchAdapter(header)
+		if !ok {
+			report.Unknown++
+			continue
+		}
+		count := &report.Formats[index[a.Name]]
+		versions[a.Name][header.Version] = true
+		if _, err := s.convertComposer(ctx, a, strings.TrimPrefix(key, composerKeyPrefix), value); err != nil {
+			count.Invalid++
+		} else {
+			count.Sessions++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read database: %v", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM cursorDiskKV WHERE key LIKE 'bubbleId:%'").Scan(&report.BubbleKeys); err != nil {
+		return nil, fmt.Errorf("query database: %v", err)
+	}
+	var n int
+	if s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ItemTable WHERE key = ?", legacyChatKey).Scan(&n) == nil && n > 0 {
+		report.LegacyChat = true
+	}
+	for _, a := range adapters {
+		if a.Load == nil {
+			continue
+		}
+		sessions, err := a.Load(ctx, s)
+		count := &report.Formats[index[a.Name]]
+		if err != nil {
+			count.Invalid++
+			continue
+		}
+		count.Sessions += len(sessions)
+	}
+
+	for i := range report.Formats {
+		for v := range versions[report.Formats[i].Name] {
+			report.Formats[i].Versions = append(report.Formats[i].Versions, v)
+		}
+		sort.Ints(report.Formats[i].Versions)
+	}
+	return report, nil
+}
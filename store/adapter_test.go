@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// 创建包含指定行的state.vscdb，itemTable为false时不创建ItemTable
+func newTestDB(t *testing.T, itemTable bool, kv map[string]any, items map[string]any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.vscdb")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmts := []string{"CREATE TABLE cursorDiskKV (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)"}
+	if itemTable {
+		stmts = append(stmts, "CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)")
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert := func(table string, rows map[string]any) {
+		for key, value := range rows {
+			data, err := json.Marshal(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO "+table+" (key, value) VALUES (?, ?)", key, string(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	insert("cursorDiskKV", kv)
+	insert("ItemTable", items)
+	return path
+}
+
+func openTestDB(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// 读取所有会话，按hash排序
+func scanAll(t *testing.T, s *Store) ([]Session, []Skipped) {
+	t.Helper()
+	var sessions []Session
+	var skipped []Skipped
+	for session, err := range s.Scan(context.Background(), Filter{}, func(sk Skipped) { skipped = append(skipped, sk) }) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Hash < sessions[j].Hash })
+	return sessions, skipped
+}
+
+const (
+	headersID = "aaaaaaaa-0000-4000-8000-000000000001"
+	inlineID  = "bbbbbbbb-0000-4000-8000-000000000002"
+	legacyID  = "cccccccc-0000-4000-8000-000000000003"
+)
+
+// 三种格式各一个会话，另有一条无法解析的composerData
+func formatFixture() (map[string]any, map[string]any) {
+	kv := map[string]any{
+		composerKeyPrefix + headersID: map[string]any{
+			"_v":         3,
+			"composerId": headersID,
+			"name":       "Headers session",
+			"createdAt":  1704099600000,
+			"fullConversationHeadersOnly": []map[string]any{
+				{"bubbleId": "b1", "type": 1},
+				{"bubbleId": "b2", "type": 2},
+				{"bubbleId": "missing", "type": 2},
+			},
+		},
+		bubbleKeyPrefix + headersID + ":b1": map[string]any{"text": "question", "timingInfo": map[string]any{"clientStartTime": 1704099600000, "clientEndTime": 1704099601000}},
+		bubbleKeyPrefix + headersID + ":b2": map[string]any{"type": 2, "text": "answer", "timingInfo": map[string]any{"clientStartTime": 1704099660000, "clientEndTime": 1704099662000}},
+		composerKeyPrefix + inlineID: map[string]any{
+			"composerId": inlineID,
+			"name":       "Inline session",
+			"createdAt":  1704186000000,
+			"conversation": []map[string]any{
+				{"type": 1, "text": "hello", "timingInfo": map[string]any{"clientStartTime": 1704186000000, "clientEndTime": 1704186001000}},
+				{"type": 2, "text": "hi", "timingInfo": map[string]any{"clientStartTime": 1704186060000, "clientEndTime": 1704186065000}},
+			},
+		},
+		composerKeyPrefix + "broken": "not a composer",
+	}
+	items := map[string]any{
+		legacyChatKey: map[string]any{
+			"tabs": []map[string]any{{
+				"tabId":        legacyID,
+				"lastSendTime": 1703980800000,
+				"bubbles": []map[string]any{
+					{"type": "user", "text": "", "rawText": "old question"},
+					{"type": "ai", "text": "old answer"},
+				},
+			}},
+		},
+	}
+	return kv, items
+}
+
+func TestDetectFormats(t *testing.T) {
+	kv, items := formatFixture()
+	s := openTestDB(t, newTestDB(t, true, kv, items))
+	report, err := s.DetectFormats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]FormatCount)
+	for _, f := range report.Formats {
+		counts[f.Name] = f
+	}
+	want := map[string]int{"composer-headers": 1, "composer-inline": 1, "legacy-aichat": 1}
+	for name, n := range want {
+		if c := counts[name]; c.Sessions != n || c.Invalid != 0 {
+			t.Errorf("%s: sessions = %d, invalid = %d, want %d, 0", name, c.Sessions, c.Invalid, n)
+		}
+	}
+	if v := counts["composer-headers"].Versions; len(v) != 1 || v[0] != 3 {
+		t.Errorf("composer-headers versions = %v, want [3]", v)
+	}
+	if report.Unknown != 1 || report.BubbleKeys != 2 || !report.LegacyChat {
+		t.Errorf("unknown = %d, bubbleKeys = %d, legacyChat = %v, want 1, 2, true", report.Unknown, report.BubbleKeys, report.LegacyChat)
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	kv, items := formatFixture()
+	s := openTestDB(t, newTestDB(t, true, kv, items))
+	sessions, skipped := scanAll(t, s)
+	if len(sessions) != 3 {
+		t.Fatalf("loaded %d sessions, want 3", len(sessions))
+	}
+	tests := []struct {
+		hash, format, title string
+		texts               []string
+		types               []int
+	}{
+		{headersID, "composer-headers", "Headers session", []string{"question", "answer"}, []int{1, 2}},
+		{inlineID, "composer-inline", "Inline session", []string{"hello", "hi"}, []int{1, 2}},
+		{legacyID, "legacy-aichat", "Untitled chat", []string{"old question", "old answer"}, []int{1, 2}},
+	}
+	for i, tt := range tests {
+		got := sessions[i]
+		if got.Hash != tt.hash || got.Format != tt.format || got.Title != tt.title {
+			t.Errorf("session %d = %s/%s/%q, want %s/%s/%q", i, got.Hash, got.Format, got.Title, tt.hash, tt.format, tt.title)
+			continue
+		}
+		if got.MessageCount != len(tt.texts) {
+			t.Errorf("%s: %d messages, want %d", tt.format, got.MessageCount, len(tt.texts))
+			continue
+		}
+		for j, msg := range got.Record.Conversation {
+			if msg.Text != tt.texts[j] || msg.Type != tt.types[j] {
+				t.Errorf("%s message %d = %d %q, want %d %q", tt.format, j, msg.Type, msg.Text, tt.types[j], tt.texts[j])
+			}
+		}
+	}
+	// 结束时间取最后一条消息的结束时间
+	if end := sessions[0].Record.EndedAt; end != 1704099662000 {
+		t.Errorf("composer-headers EndedAt = %d, want 1704099662000", end)
+	}
+
+	if len(skipped) != 1 || skipped[0].Key != composerKeyPrefix+"broken" || skipped[0].Reason != SkipInvalidJSON && skipped[0].Reason != SkipUnknownFormat {
+		t.Errorf("skipped = %+v, want only the broken composerData", skipped)
+	}
+}
+
+func TestLoadLegacyChatErrors(t *testing.T) {
+	kv, _ := formatFixture()
+
+	// 没有旧版本聊天数据时不报告错误
+	s := openTestDB(t, newTestDB(t, true, kv, nil))
+	if sessions, err := loadLegacyChat(context.Background(), s); err != nil || len(sessions) != 0 {
+		t.Errorf("without legacy data: sessions = %d, err = %v", len(sessions), err)
+	}
+
+	// 查询失败时返回错误，读取会话时记录为跳过
+	s = openTestDB(t, newTestDB(t, false, kv, nil))
+	if _, err := loadLegacyChat(context.Background(), s); err == nil {
+		t.Fatal("missing ItemTable did not return an error")
+	}
+	sessions, skipped := scanAll(t, s)
+	if len(sessions) != 2 {
+		t.Errorf("loaded %d sessions, want 2", len(sessions))
+	}
+	found := false
+	for _, sk := range skipped {
+		found = found || sk.Key == "legacy-aichat" && sk.Reason == SkipLoadError
+	}
+	if !found {
+		t.Errorf("skipped = %+v, want a load-error for legacy-aichat", skipped)
+	}
+}
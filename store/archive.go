@@ -0,0 +1,516 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 归档格式的版本，格式不兼容时递增
+const archiveFormat = 1
+
+// 不是cursor2md的归档文件
+var ErrNotArchive = errors.New("not a cursor2md archive")
+
+// 归档数据库的表结构
+// archive_versions记录每个归档单元的各个版本，archive_rows保存每个版本的原始记录
+// 归档单元是一个会话（键为composer ID）或多个会话共享的记录（键为 表名:键名）
+// cursorDiskKV和ItemTable视图只包含每个单元的最新版本，因此可以像state.vscdb一样用Open读取归档
+var archiveSchema = []string{
+	`CREATE TABLE IF NOT EXISTS archive_info (key TEXT PRIMARY KEY, value TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS archive_versions (
+		unit TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		archived_at INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		rows INTEGER NOT NULL,
+		PRIMARY KEY (unit, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS archive_rows (
+		unit TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		tbl TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value BLOB,
+		PRIMARY KEY (unit, version, tbl, key)
+	)`,
+	`CREATE VIEW IF NOT EXISTS archive_latest AS
+		SELECT r.tbl AS tbl, r.key AS key, r.value AS value FROM archive_rows r
+		JOIN (SELECT unit, MAX(version) AS version FROM archive_versions GROUP BY unit) l
+		ON r.unit = l.unit AND r.version = l.version`,
+	`CREATE VIEW IF NOT EXISTS cursorDiskKV AS SELECT key, value FROM archive_latest WHERE tbl = 'cursorDiskKV'`,
+	`CREATE VIEW IF NOT EXISTS ItemTable AS SELECT key, value FROM archive_latest WHERE tbl = 'ItemTable'`,
+}
+
+// 按会话归档的cursorDiskKV键前缀，完整的键为 前缀<composerId> 或 前缀<composerId>:<id>
+var archivePrefixes = []string{composerKeyPrefix, bubbleKeyPrefix, "codeBlockDiff:", "checkpointId:"}
+
+// 多个会话共享、整体归档的记录
+var sharedRecords = []struct{ table, key string }{
+	{"cursorDiskKV", "inlineDiffsData"},
+	{"ItemTable", "inlineDiffsData"},
+	{"ItemTable", legacyChatKey},
+}
+
+// 保存会话原始数据的归档，每次备份时只为内容发生变化的会话添加新版本，旧版本一直保留
+type Archive struct {
+	db   *sql.DB
+	path string
+}
+
+// 归档单元的一个版本
+type ArchiveVersion struct {
+	Unit       string    `json:"unit"`
+	Version    int       `json:"version"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	Source     string    `json:"source"`
+	Checksum   string    `json:"checksum"`
+	Rows       int       `json:"rows"`
+}
+
+// 备份一个数据库的结果，会话数量不包括共享的记录
+type BackupReport struct {
+	Source    string `json:"source"`
+	Sessions  int    `json:"sessions"`
+	Added     int    `json:"added"`     // 归档中原来没有的会话
+	Updated   int    `json:"updated"`   // 内容变化、添加了新版本的会话
+	Unchanged int    `json:"unchanged"` // 与归档中最新版本相同的会话
+	Shared    int    `json:"shared"`    // 添加了新版本的共享记录
+}
+
+// 打开归档，文件不存在时创建
+// 文件存在但不是归档（例如state.vscdb）或格式版本较新时返回包装了ErrNotArchive的错误
+func OpenArchive(path string) (*Archive, error) {
+	dsn, err := fileDSN(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %v", err)
+	}
+	a := &Archive{db: db, path: path}
+	if err := a.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// 检查文件格式并创建表结构
+func (a *Archive) init() error {
+	var tables int
+	if err := a.db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&tables); err != nil {
+		return fmt.Errorf("open archive: %v", err)
+	}
+	if tables > 0 {
+		format, err := archiveFormatOf(a.db)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, a.path)
+		}
+		if format > archiveFormat {
+			return fmt.Errorf("%w: %s has format version %d, only %d is supported", ErrNotArchive, a.path, format, archiveFormat)
+		}
+		return nil
+	}
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("create archive: %v", err)
+	}
+	defer tx.Rollback()
+	for _, stmt := range archiveSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("create archive: %v", err)
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO archive_info (key, value) VALUES ('format', ?)", strconv.Itoa(archiveFormat)); err != nil {
+		return fmt.Errorf("create archive: %v", err)
+	}
+	return tx.Commit()
+}
+
+// 读取归档的格式版本，不是归档时返回ErrNotArchive
+func archiveFormatOf(db *sql.DB) (int, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM archive_info WHERE key = 'format'").Scan(&value)
+	if err != nil {
+		return 0, ErrNotArchive
+	}
+	format, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, ErrNotArchive
+	}
+	return format, nil
+}
+
+// 判断文件是否为cursor2md的归档，文件不存在时返回包装了ErrDBNotFound的错误
+func IsArchive(path string) (bool, error) {
+	s, err := Open(path, Options{ReadOnly: true})
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+	_, err = archiveFormatOf(s.db)
+	return err == nil, nil
+}
+
+// 归档文件路径
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// 关闭归档
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// 归档中的一条原始记录
+type archiveRow struct {
+	table string
+	key   string
+	value any
+}
+
+// 将数据库中所有会话和共享记录的原始数据写入归档，所有写入在一个事务中完成
+// 数据库中已删除的会话不会从归档中删除
+func (a *Archive) Backup(ctx context.Context, s *Store, now time.Time) (BackupReport, error) {
+	reports, err := a.BackupMerged(ctx, &Merged{stores: []*Store{s}}, now)
+	if err != nil {
+		return BackupReport{Source: s.path}, err
+	}
+	return reports[0], nil
+}
+
+// 将合并的多个数据库写入归档，每个数据库返回一个报告，顺序与Stores相同
+// 同一会话只备份按合并规则选中的版本，计入该版本所在数据库的报告
+// 共享记录合并后作为一个版本归档，见mergeSharedRows，因此重复备份同样的数据库不会交替产生新版本
+func (a *Archive) BackupMerged(ctx context.Context, m *Merged, now time.Time) ([]BackupReport, error) {
+	reports := make([]BackupReport, len(m.stores))
+	index := make(map[*Store]int)
+	holders := make(map[string][]*Store)
+	var sessions []string
+	for i, s := range m.stores {
+		reports[i].Source = s.path
+		index[s] = i
+		units, err := s.archiveUnits(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, unit := range units {
+			if strings.Contains(unit, ":") {
+				continue
+			}
+			if len(holders[unit]) == 0 {
+				sessions = append(sessions, unit)
+			}
+			holders[unit] = append(holders[unit], s)
+		}
+	}
+	sort.Strings(sessions)
+
+	// 各会话选中的版本所在的数据库，无法解析的会话使用第一个包含它的数据库
+	winners := make(map[string]string)
+	if len(m.stores) > 1 {
+		for session, err := range m.Scan(ctx, Filter{}, nil) {
+			if err != nil {
+				return nil, err
+			}
+			winners[session.Hash] = session.Source
+		}
+	}
+	owner := func(unit string) *Store {
+		for _, s := range holders[unit] {
+			if s.path == winners[unit] {
+				return s
+			}
+		}
+		return holders[unit][0]
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("write archive: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, unit := range sessions {
+		s := owner(unit)
+		rows, err := s.archiveRows(ctx, unit)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		added, version, err := addVersion(ctx, tx, unit, rows, s.path, now)
+		if err != nil {
+			return nil, err
+		}
+		report := &reports[index[s]]
+		report.Sessions++
+		switch {
+		case !added:
+			report.Unchanged++
+		case version == 1:
+			report.Added++
+		default:
+			report.Updated++
+		}
+	}
+
+	for _, r := range sharedRecords {
+		unit := r.table + ":" + r.key
+		var sources []*Store
+		var values [][]archiveRow
+		for _, s := range m.stores {
+			rows, err := s.archiveRows(ctx, unit)
+			if err != nil {
+				return nil, err
+			}
+			if len(rows) > 0 {
+				sources = append(sources, s)
+				values = append(values, rows)
+			}
+		}
+		if len(sources) == 0 {
+			continue
+		}
+		rows, source := values[0], sources[0].path
+		if len(sources) > 1 {
+			rows, source = mergeSharedRows(r.key, sources, values, func(id string) string {
+				if len(holders[id]) == 0 {
+					return ""
+				}
+				return owner(id).path
+			})
+		}
+		added, _, err := addVersion(ctx, tx, unit, rows, source, now)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			reports[index[sources[0]]].Shared++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("write archive: %v", err)
+	}
+	return reports, nil
+}
+
+// 合并多个数据库中的同一条共享记录，返回归档的记录和记录的来源
+// inlineDiffsData中的每一项只保留会话选中版本所在数据库中的，owner返回该数据库，会话不存在时返回空字符串，
+// 此时保留第一个包含该会话的数据库中的项；其他记录和无法解析的inlineDiffsData使用第一个数据库中的记录
+func mergeSharedRows(key string, sources []*Store, values [][]archiveRow, owner func(id string) string) ([]archiveRow, string) {
+	first := values[0]
+	if key != "inlineDiffsData" {
+		return first, sources[0].path
+	}
+	var merged []json.RawMessage
+	var used []string
+	kept := make(map[string]string)
+	for i, s := range sources {
+		var items []json.RawMessage
+		if err := json.Unmarshal(rawBytes(values[i][0].value), &items); err != nil {
+			return first, sources[0].path
+		}
+		n := len(merged)
+		for _, item := range items {
+			var header struct {
+				ComposerId string `json:"composerId"`
+			}
+			json.Unmarshal(item, &header)
+			want := owner(header.ComposerId)
+			if want == "" {
+				if _, ok := kept[header.ComposerId]; !ok {
+					kept[header.ComposerId] = s.path
+				}
+				want = kept[header.ComposerId]
+			}
+			if want == s.path {
+				merged = append(merged, item)
+			}
+		}
+		if len(merged) > n {
+			used = append(used, s.path)
+		}
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return first, sources[0].path
+	}
+	row := first[0]
+	if _, ok := row.value.(string); ok {
+		row.value = string(data)
+	} else {
+		row.value = data
+	}
+	if len(used) == 0 {
+		used = append(used, sources[0].path)
+	}
+	return []archiveRow{row}, strings.Join(used, ", ")
+}
+
+// 内容与最新版本不同时添加新版本，返回是否添加和最新的版本号
+func addVersion(ctx context.Context, tx *sql.Tx, unit string, rows []archiveRow, source string, now time.Time) (bool, int, error) {
+	checksum := checksumRows(rows)
+	var latest int
+	var latestSum string
+	err := tx.QueryRowContext(ctx, "SELECT version, checksum FROM archive_versions WHERE unit = ? ORDER BY version DESC LIMIT 1", unit).Scan(&latest, &latestSum)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, fmt.Errorf("read archive: %v", err)
+	}
+	if latestSum == checksum {
+		return false, latest, nil
+	}
+
+	version := latest + 1
+	if _, err := tx.ExecContext(ctx, "INSERT INTO archive_versions (unit, version, archived_at, source, checksum, rows) VALUES (?, ?, ?, ?, ?, ?)",
+		unit, version, now.UnixMilli(), source, checksum, len(rows)); err != nil {
+		return false, 0, fmt.Errorf("write archive: %v", err)
+	}
+	for _, r := range rows {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO archive_rows (unit, version, tbl, key, value) VALUES (?, ?, ?, ?, ?)",
+			unit, version, r.table, r.key, r.value); err != nil {
+			return false, 0, fmt.Errorf("write archive: %v", err)
+		}
+	}
+	return true, version, nil
+}
+
+// 按表名和键排序后计算所有记录的SHA-256
+func checksumRows(rows []archiveRow) string {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].table != rows[j].table {
+			return rows[i].table < rows[j].table
+		}
+		return rows[i].key < rows[j].key
+	})
+	h := sha256.New()
+	for _, r := range rows {
+		var value []byte
+		switch v := r.value.(type) {
+		case []byte:
+			value = v
+		case string:
+			value = []byte(v)
+		case nil:
+		default:
+			value = fmt.Append(nil, v)
+		}
+		for _, field := range [][]byte{[]byte(r.table), []byte(r.key), value} {
+			binary.Write(h, binary.BigEndian, uint64(len(field)))
+			h.Write(field)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 列出数据库中的所有归档单元：有记录的会话ID和存在的共享记录
+func (s *Store) archiveUnits(ctx context.Context) ([]string, error) {
+	var units []string
+	seen := make(map[string]bool)
+	for _, prefix := range archivePrefixes {
+		rows, err := s.db.QueryContext(ctx, "SELECT key FROM cursorDiskKV WHERE key >= ? AND key < ?", prefix, prefix+"\xff")
+		if err != nil {
+			return nil, fmt.Errorf("query database: %v", err)
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("read rows: %v", err)
+			}
+			id, _ := splitChangeKey(key, prefix)
+			if id != "" && !seen[id] {
+				seen[id] = true
+				units = append(units, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("read rows: %v", err)
+		}
+	}
+	sort.Strings(units)
+	for _, r := range sharedRecords {
+		units = append(units, r.table+":"+r.key)
+	}
+	return units, nil
+}
+
+// 读取一个归档单元的所有原始记录，值保持数据库中的类型
+func (s *Store) archiveRows(ctx context.Context, unit string) ([]archiveRow, error) {
+	var result []archiveRow
+	query := func(table, where string, args ...any) error {
+		rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM "+table+" WHERE "+where, args...)
+		if err != nil {
+			// ItemTable不存在时没有共享记录
+			if table == "ItemTable" {
+				return nil
+			}
+			return fmt.Errorf("query database: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			r := archiveRow{table: table}
+			if err := rows.Scan(&r.key, &r.value); err != nil {
+				return fmt.Errorf("read rows: %v", err)
+			}
+			result = append(result, r)
+		}
+		return rows.Err()
+	}
+
+	if table, key, ok := strings.Cut(unit, ":"); ok {
+		return result, query(table, "key = ?", key)
+	}
+	for _, prefix := range archivePrefixes {
+		var err error
+		if prefix == composerKeyPrefix {
+			err = query("cursorDiskKV", "key = ?", prefix+unit)
+		} else {
+			err = query("cursorDiskKV", "key >= ? AND key < ?", prefix+unit+":", prefix+unit+";")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// 列出归档单元的所有版本，按版本号排序；unit为空时列出所有单元
+func (a *Archive) Versions(ctx context.Context, unit string) ([]ArchiveVersion, error) {
+	query := "SELECT unit, version, archived_at, source, checksum, rows FROM archive_versions"
+	var args []any
+	if unit != "" {
+		query += " WHERE unit = ?"
+		args = append(args, unit)
+	}
+	rows, err := a.db.QueryContext(ctx, query+" ORDER BY unit, version", args...)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %v", err)
+	}
+	defer rows.Close()
+	var versions []ArchiveVersion
+	for rows.Next() {
+		var v ArchiveVersion
+		var archivedAt int64
+		if err := rows.Scan(&v.Unit, &v.Version, &archivedAt, &v.Source, &v.Checksum, &v.Rows); err != nil {
+			return nil, fmt.Errorf("read archive: %v", err)
+		}
+		v.ArchivedAt = time.UnixMilli(archivedAt)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 内联保存消息的composerData，texts依次为用户和AI的消息
+func inlineComposer(id string, texts ...string) map[string]any {
+	var conversation []map[string]any
+	for i, text := range texts {
+		start := int64(1704186000000 + i*60000)
+		conversation = append(conversation, map[string]any{
+			"type":       1 + i%2,
+			"bubbleId":   id[:8] + "-" + string(rune('a'+i)),
+			"text":       text,
+			"timingInfo": map[string]any{"clientStartTime": start, "clientEndTime": start + 1000},
+		})
+	}
+	return map[string]any{"composerId": id, "name": "Merged session", "createdAt": 1704186000000, "conversation": conversation}
+}
+
+// 同一会话在两个数据库中内容不同时，无论备份多少次都只归档选中的版本，共享记录也不会交替产生新版本
+func TestBackupMerged(t *testing.T) {
+	ctx := context.Background()
+	other := "dddddddd-0000-4000-8000-000000000004"
+	laptop := newTestDB(t, true, map[string]any{
+		composerKeyPrefix + inlineID: inlineComposer(inlineID, "hello", "hi"),
+		composerKeyPrefix + other:    inlineComposer(other, "only on the laptop"),
+		"inlineDiffsData": []map[string]any{
+			{"composerId": inlineID, "diffId": "laptop"},
+			{"composerId": other, "diffId": "other"},
+		},
+	}, nil)
+	desktop := newTestDB(t, true, map[string]any{
+		composerKeyPrefix + inlineID: inlineComposer(inlineID, "hello", "hi", "and more"),
+		"inlineDiffsData": []map[string]any{
+			{"composerId": inlineID, "diffId": "desktop"},
+		},
+	}, nil)
+
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "archive.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	for run := 0; run < 3; run++ {
+		m, err := OpenMerged([]string{laptop, desktop}, Options{ReadOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports, err := archive.BackupMerged(ctx, m, time.UnixMilli(int64(run)))
+		m.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 2 || reports[0].Sessions != 1 || reports[1].Sessions != 1 {
+			t.Fatalf("run %d: reports = %+v, want one session from each database", run, reports)
+		}
+		if run > 0 && (reports[0].Unchanged != 1 || reports[1].Unchanged != 1 || reports[0].Shared != 0) {
+			t.Errorf("run %d: reports = %+v, want nothing new", run, reports)
+		}
+	}
+
+	versions, err := archive.Versions(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make(map[string]string)
+	for _, v := range versions {
+		if v.Version != 1 {
+			t.Errorf("%s has version %d, want a single version", v.Unit, v.Version)
+		}
+		sources[v.Unit] = v.Source
+	}
+	if sources[inlineID] != desktop || sources[other] != laptop {
+		t.Errorf("sources = %v, want %s from the desktop and %s from the laptop", sources, inlineID, other)
+	}
+
+	// 归档可以像数据库一样读取，doctor也能找到视图
+	s := openTestDB(t, archive.Path())
+	sessions, _ := scanAll(t, s)
+	if len(sessions) != 2 || sessions[0].Hash != inlineID || sessions[0].MessageCount != 3 {
+		t.Errorf("archived sessions = %+v, want the desktop version with 3 messages", sessions)
+	}
+	var value string
+	if err := s.db.QueryRow("SELECT value FROM cursorDiskKV WHERE key = 'inlineDiffsData'").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	var diffs []struct {
+		DiffId string `json:"diffId"`
+	}
+	if err := json.Unmarshal([]byte(value), &diffs); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].DiffId != "other" || diffs[1].DiffId != "desktop" {
+		t.Errorf("inlineDiffsData = %s, want the laptop entry of %s and the desktop entry of %s", value, other, inlineID)
+	}
+
+	d, err := s.Diagnose(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range d.Tables {
+		if !table.Exists {
+			t.Errorf("doctor reports %s missing in the archive", table.Name)
+		}
+	}
+}
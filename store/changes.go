@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Cursor记录的行范围修改：将原文件的[StartLineNumber, EndLineNumberExclusive)行替换为Modified
+type rawChangeHunk struct {
+	Original struct {
+		StartLineNumber        int `json:"startLineNumber"`
+		EndLineNumberExclusive int `json:"endLineNumberExclusive"`
+	} `json:"original"`
+	Modified []string `json:"modified"`
+}
+
+// 文件URI，不同版本的Cursor使用path或fsPath
+type rawChangeUri struct {
+	Path   string `json:"path"`
+	FsPath string `json:"fsPath"`
+}
+
+func (u rawChangeUri) path() string {
+	if u.FsPath != "" {
+		return u.FsPath
+	}
+	return u.Path
+}
+
+// 修改的状态字段，不同版本使用status字符串或布尔值
+type rawChangeStatus struct {
+	Status     string `json:"status"`
+	IsAccepted *bool  `json:"isAccepted"`
+	IsRejected *bool  `json:"isRejected"`
+	Accepted   *bool  `json:"accepted"`
+	Rejected   *bool  `json:"rejected"`
+}
+
+// 统一为accepted、rejected、pending，未知时为空
+func (s rawChangeStatus) status() string {
+	switch strings.ToLower(s.Status) {
+	case "accepted", "accept", "applied", "completed":
+		return "accepted"
+	case "rejected", "reject", "discarded", "cancelled":
+		return "rejected"
+	case "pending", "active", "generating":
+		return "pending"
+	}
+	for _, b := range []*bool{s.IsAccepted, s.Accepted} {
+		if b != nil && *b {
+			return "accepted"
+		}
+	}
+	for _, b := range []*bool{s.IsRejected, s.Rejected} {
+		if b != nil && *b {
+			return "rejected"
+		}
+	}
+	return ""
+}
+
+// inlineDiffsData中的一项
+type rawInlineDiff struct {
+	rawChangeStatus
+	ComposerId             string          `json:"composerId"`
+	DiffId                 string          `json:"diffId"`
+	Uri                    rawChangeUri    `json:"uri"`
+	OriginalTextLines      []string        `json:"originalTextLines"`
+	NewTextDiffWrtOriginal []rawChangeHunk `json:"newTextDiffWrtOriginal"`
+}
+
+// codeBlockDiff:<composerId>:<diffId>的值
+type rawCodeBlockDiff struct {
+	rawChangeStatus
+	Uri               rawChangeUri    `json:"uri"`
+	NewModelDiffWrtV0 []rawChangeHunk `json:"newModelDiffWrtV0"`
+}
+
+// checkpointId:<composerId>:<checkpointId>的值，记录修改前的文件内容，用于恢复
+type rawCheckpoint struct {
+	Files []struct {
+		Uri                    rawChangeUri    `json:"uri"`
+		OriginalModelDiffWrtV0 []rawChangeHunk `json:"originalModelDiffWrtV0"`
+		IsNewlyCreated         bool            `json:"isNewlyCreated"`
+	} `json:"files"`
+	NonExistentFiles []struct {
+		Uri rawChangeUri `json:"uri"`
+	} `json:"nonExistentFiles"`
+}
+
+// 转换行范围修改，originalLines不为空时填充被替换的原内容
+func convertChangeHunks(hunks []rawChangeHunk, originalLines []string) []ChangeHunk {
+	result := make([]ChangeHunk, 0, len(hunks))
+	for _, h := range hunks {
+		hunk := ChangeHunk{
+			OriginalStart: h.Original.StartLineNumber,
+			OriginalEnd:   h.Original.EndLineNumberExclusive,
+			Added:         h.Modified,
+			NewCount:      len(h.Modified),
+		}
+		if hunk.OriginalEnd < hunk.OriginalStart {
+			hunk.OriginalEnd = hunk.OriginalStart
+		}
+		if from, to := hunk.OriginalStart-1, hunk.OriginalEnd-1; from >= 0 && to <= len(originalLines) && len(originalLines) > 0 {
+			hunk.Removed = originalLines[from:to]
+		}
+		result = append(result, hunk)
+	}
+	return result
+}
+
+// 从形如 前缀:<composerId>:<id> 的键中取出会话和修改的ID
+func splitChangeKey(key string, prefix string) (string, string) {
+	composerId, id, _ := strings.Cut(strings.TrimPrefix(key, prefix), ":")
+	return composerId, id
+}
+
+// 读取所有会话的修改记录，按会话ID分组；无法解析或无法关联到会话的记录会被跳过
+func (s *Store) AppliedChanges(ctx context.Context) (map[string][]AppliedChange, error) {
+	return s.appliedChanges(ctx, nil)
+}
+
+// 读取所有会话的修改记录，跳过的记录交给onSkip
+func (s *Store) appliedChanges(ctx context.Context, onSkip SkipHandler) (map[string][]AppliedChange, error) {
+	changes := make(map[string][]AppliedChange)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM cursorDiskKV WHERE key LIKE 'codeBlockDiff:%' OR key LIKE 'checkpointId:%' OR key = 'inlineDiffsData' ORDER BY key")
+	if err != nil {
+		return nil, fmt.Errorf("query database: %v", err)
+	}
+	defer rows.Close()
+
+	// 键和值，inlineDiffsData可能同时出现在两个表中
+	var inlineValues [][2]string
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			onSkip.skip(key, SkipScanError, err)
+			continue
+		}
+		if !value.Valid {
+			onSkip.skip(key, SkipNullValue, nil)
+			continue
+		}
+		switch {
+		case key == "inlineDiffsData":
+			inlineValues = append(inlineValues, [2]string{key, value.String})
+
+		case strings.HasPrefix(key, "codeBlockDiff:"):
+			var diff rawCodeBlockDiff
+			if err := json.Unmarshal([]byte(value.String), &diff); err != nil {
+				onSkip.skip(key, SkipInvalidJSON, err)
+				continue
+			}
+			if len(diff.NewModelDiffWrtV0) == 0 {
+				continue
+			}
+			composerId, id := splitChangeKey(key, "codeBlockDiff:")
+			changes[composerId] = append(changes[composerId], AppliedChange{
+				Source: "codeBlockDiff",
+				ID:     id,
+				Path:   diff.Uri.path(),
+				Status: diff.status(),
+				Hunks:  convertChangeHunks(diff.NewModelDiffWrtV0, nil),
+			})
+
+		case strings.HasPrefix(key, "checkpointId:"):
+			var checkpoint rawCheckpoint
+			if err := json.Unmarshal([]byte(value.String), &checkpoint); err != nil {
+				onSkip.skip(key, SkipInvalidJSON, err)
+				continue
+			}
+			composerId, id := splitChangeKey(key, "checkpointId:")
+			for _, f := range checkpoint.Files {
+				change := AppliedChange{
+					Source: "checkpoint",
+					ID:     id,
+					Path:   f.Uri.path(),
+					Note:   NoteCheckpoint,
+					Hunks:  []ChangeHunk{},
+				}
+				if f.IsNewlyCreated {
+					change.Note = NoteNewFile
+				}
+				// 检查点保存的是从修改后恢复到修改前的差异：修改后文件的[start, end)行恢复为Modified
+				for _, h := range f.OriginalModelDiffWrtV0 {
+					start := h.Original.StartLineNumber
+					newCount := h.Original.EndLineNumberExclusive - start
+					if newCount < 0 {
+						newCount = 0
+					}
+					change.Hunks = append(change.Hunks, ChangeHunk{
+						OriginalStart: start,
+						OriginalEnd:   start + len(h.Modified),
+						Removed:       h.Modified,
+						NewCount:      newCount,
+					})
+				}
+				changes[composerId] = append(changes[composerId], change)
+			}
+			for _, f := range checkpoint.NonExistentFiles {
+				changes[composerId] = append(changes[composerId], AppliedChange{
+					Source: "checkpoint",
+					ID:     id,
+					Path:   f.Uri.path(),
+					Note:   NoteNotExisted,
+					Hunks:  []ChangeHunk{},
+				})
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read database: %v", err)
+	}
+
+	// inlineDiffsData也可能保存在ItemTable中
+	var itemValue string
+	if err := s.db.QueryRowContext(ctx, "SELECT value FROM ItemTable WHERE key = 'inlineDiffsData'").Scan(&itemValue); err == nil {
+		inlineValues = append(inlineValues, [2]string{"ItemTable:inlineDiffsData", itemValue})
+	}
+	for _, kv := range inlineValues {
+		var diffs []rawInlineDiff
+		if err := json.Unmarshal([]byte(kv[1]), &diffs); err != nil {
+			onSkip.skip(kv[0], SkipInvalidJSON, err)
+			continue
+		}
+		for _, d := range diffs {
+			if d.ComposerId == "" {
+				onSkip.skip(kv[0]+"#"+d.DiffId, SkipNoComposer, nil)
+				continue
+			}
+			changes[d.ComposerId] = append(changes[d.ComposerId], AppliedChange{
+				Source: "inlineDiff",
+				ID:     d.DiffId,
+				Path:   d.Uri.path(),
+				Status: d.status(),
+				Hunks:  convertChangeHunks(d.NewTextDiffWrtOriginal, d.OriginalTextLines),
+			})
+		}
+	}
+
+	for id := range changes {
+		sortAppliedChanges(changes[id])
+	}
+	return changes, nil
+}
+
+// 按文件路径、来源和ID排序，使输出稳定
+func sortAppliedChanges(changes []AppliedChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		if changes[i].Source != changes[j].Source {
+			return changes[i].Source < changes[j].Source
+		}
+		return changes[i].ID < changes[j].ID
+	})
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cursor数据库中应当存在的表
+var expectedTables = []string{"cursorDiskKV", "ItemTable"}
+
+// 表的检查结果
+type TableInfo struct {
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	Rows   int    `json:"rows"`
+}
+
+// 按键前缀统计的行数
+type PrefixCount struct {
+	Table  string `json:"table"`
+	Prefix string `json:"prefix"` // 第一个冒号之前的部分，没有冒号时为整个键
+	Rows   int    `json:"rows"`
+}
+
+// 数据库完整性的检查结果
+type Diagnosis struct {
+	Integrity []string      `json:"integrity"` // PRAGMA integrity_check的结果，正常时只有ok
+	Tables    []TableInfo   `json:"tables"`
+	Prefixes  []PrefixCount `json:"prefixes"`
+}
+
+// 数据库是否通过完整性检查
+func (d *Diagnosis) IntegrityOK() bool {
+	return len(d.Integrity) == 1 && d.Integrity[0] == "ok"
+}
+
+// 检查数据库的完整性、表是否存在以及各类记录的数量
+// 归档中的cursorDiskKV和ItemTable是视图，同样视为存在
+func (s *Store) Diagnose(ctx context.Context) (*Diagnosis, error) {
+	d := &Diagnosis{}
+	rows, err := s.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %v", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("integrity check: %v", err)
+		}
+		d.Integrity = append(d.Integrity, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check: %v", err)
+	}
+
+	for _, name := range expectedTables {
+		info := TableInfo{Name: name}
+		var n int
+		if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = ?", name).Scan(&n); err != nil {
+			return nil, fmt.Errorf("query database: %v", err)
+		}
+		if n > 0 {
+			info.Exists = true
+			if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+name).Scan(&info.Rows); err != nil {
+				return nil, fmt.Errorf("query database: %v", err)
+			}
+			prefixes, err := s.countPrefixes(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			d.Prefixes = append(d.Prefixes, prefixes...)
+		}
+		d.Tables = append(d.Tables, info)
+	}
+	return d, nil
+}
+
+// 按键的第一个冒号之前的部分统计行数
+func (s *Store) countPrefixes(ctx context.Context, table string) ([]PrefixCount, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT CASE WHEN instr(key, ':') > 0 THEN substr(key, 1, instr(key, ':') - 1) ELSE key END AS prefix, COUNT(*)
+		FROM `+table+` GROUP BY prefix ORDER BY COUNT(*) DESC, prefix`)
+	if err != nil {
+		return nil, fmt.Errorf("query database: %v", err)
+	}
+	defer rows.Close()
+	var counts []PrefixCount
+	for rows.Next() {
+		c := PrefixCount{Table: table}
+		if err := rows.Scan(&c.Prefix, &c.Rows); err != nil {
+			return nil, fmt.Errorf("read database: %v", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read database: %v", err)
+	}
+	return counts, nil
+}
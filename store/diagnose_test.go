@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 将索引的定义改为另一列，使索引内容与表不一致，完整性检查失败但仍然可以读取
+func corruptTestDB(t *testing.T, path string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE INDEX kv_value ON cursorDiskKV(value)",
+		"PRAGMA writable_schema = ON",
+		"UPDATE sqlite_master SET sql = 'CREATE INDEX kv_value ON cursorDiskKV(key)' WHERE name = 'kv_value'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	path := newTestDB(t, false, map[string]any{
+		composerKeyPrefix + inlineID:           inlineComposer(inlineID, "hello"),
+		composerKeyPrefix + headersID:          inlineComposer(headersID, "hi"),
+		bubbleKeyPrefix + headersID + ":b1":    map[string]any{"type": 1},
+		"inlineDiffsData":                      []any{},
+		"checkpointId:" + inlineID + ":1:x:y":  map[string]any{},
+		"checkpointId:" + headersID + ":1:x:y": map[string]any{},
+	}, nil)
+	d, err := openTestDB(t, path).Diagnose(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.IntegrityOK() || !slices.Equal(d.Integrity, []string{"ok"}) {
+		t.Errorf("integrity = %v, want ok", d.Integrity)
+	}
+	wantTables := []TableInfo{{Name: "cursorDiskKV", Exists: true, Rows: 6}, {Name: "ItemTable"}}
+	if !slices.Equal(d.Tables, wantTables) {
+		t.Errorf("tables = %+v, want %+v", d.Tables, wantTables)
+	}
+	// 按行数降序，行数相同时按前缀排序；没有冒号的键整体作为前缀
+	wantPrefixes := []PrefixCount{
+		{Table: "cursorDiskKV", Prefix: "checkpointId", Rows: 2},
+		{Table: "cursorDiskKV", Prefix: "composerData", Rows: 2},
+		{Table: "cursorDiskKV", Prefix: "bubbleId", Rows: 1},
+		{Table: "cursorDiskKV", Prefix: "inlineDiffsData", Rows: 1},
+	}
+	if !slices.Equal(d.Prefixes, wantPrefixes) {
+		t.Errorf("prefixes = %+v, want %+v", d.Prefixes, wantPrefixes)
+	}
+}
+
+func TestDiagnoseCorrupt(t *testing.T) {
+	path := newTestDB(t, true, map[string]any{composerKeyPrefix + inlineID: inlineComposer(inlineID, "hello")}, nil)
+	corruptTestDB(t, path)
+	d, err := openTestDB(t, path).Diagnose(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IntegrityOK() || len(d.Integrity) == 0 {
+		t.Errorf("integrity = %v, want errors", d.Integrity)
+	}
+	if !d.Tables[0].Exists || d.Tables[0].Rows != 1 {
+		t.Errorf("tables = %+v, want the rows counted despite the errors", d.Tables)
+	}
+
+	for _, integrity := range [][]string{nil, {}, {"ok", "row 1 missing from index"}, {"*** in database main ***"}} {
+		if (&Diagnosis{Integrity: integrity}).IntegrityOK() {
+			t.Errorf("IntegrityOK(%q) = true", integrity)
+		}
+	}
+}
+
+// 归档中的cursorDiskKV和ItemTable是视图，同样视为存在并统计行数
+func TestDiagnoseArchive(t *testing.T) {
+	ctx := context.Background()
+	source := newTestDB(t, true, map[string]any{
+		composerKeyPrefix + inlineID: inlineComposer(inlineID, "hello", "hi"),
+	}, map[string]any{"composer.composerData": map[string]any{}})
+
+	path := filepath.Join(t.TempDir(), "archive.db")
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Backup(ctx, openTestDB(t, source), time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+
+	d, err := openTestDB(t, path).Diagnose(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.IntegrityOK() {
+		t.Errorf("integrity = %v, want ok", d.Integrity)
+	}
+	if !d.Tables[0].Exists || d.Tables[0].Rows == 0 || !d.Tables[1].Exists {
+		t.Errorf("tables = %+v, want both archive views to exist", d.Tables)
+	}
+	found := false
+	for _, p := range d.Prefixes {
+		found = found || (p.Table == "cursorDiskKV" && p.Prefix == "composerData" && p.Rows == 1)
+	}
+	if !found {
+		t.Errorf("prefixes = %+v, want one composerData row in cursorDiskKV", d.Prefixes)
+	}
+}
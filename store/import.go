@@ -0,0 +1,387 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 数据库被其他进程锁定，通常是Cursor正在运行
+var ErrLocked = errors.New("database is locked by another process")
+
+// 导入时目标数据库中已有同一会话的处理方式
+type ConflictMode string
+
+const (
+	ConflictSkip      ConflictMode = "skip"      // 保留目标数据库中的会话
+	ConflictOverwrite ConflictMode = "overwrite" // 删除目标数据库中的会话后写入
+	ConflictRename    ConflictMode = "rename"    // 使用新的composer ID写入，两个会话都保留
+)
+
+// 支持的冲突处理方式
+var ConflictModes = []ConflictMode{ConflictSkip, ConflictOverwrite, ConflictRename}
+
+// 导入计划中对一个会话的操作
+const (
+	ImportAdd       = "add"       // 目标数据库中没有该会话
+	ImportOverwrite = "overwrite" // 覆盖目标数据库中的会话
+	ImportRename    = "rename"    // 使用新ID写入
+	ImportSkip      = "skip"      // 目标数据库中已有该会话，不写入
+	ImportSame      = "same"      // 目标数据库中的会话与导入的内容相同
+	ImportDuplicate = "duplicate" // 之前的来源中已有该会话
+	ImportNoID      = "no-id"     // 没有composerId（使用-redact或-anonymize的输出），不导入
+)
+
+// 一个待导入的会话及其原始记录
+type ImportSession struct {
+	Hash   string
+	Title  string
+	Source string
+	rows   []archiveRow
+}
+
+// 导入计划中的一项
+type ImportItem struct {
+	Hash    string `json:"hash"`
+	NewHash string `json:"newHash,omitempty"` // 重命名时写入的ID
+	Title   string `json:"title"`
+	Source  string `json:"source"`
+	Rows    int    `json:"rows"`
+	Action  string `json:"action"`
+}
+
+// 读取数据库或归档中所有会话的原始记录，用于导入其他数据库
+// 多个会话共享的记录（inlineDiffsData、旧版聊天面板）不会导入
+func (s *Store) ImportSessions(ctx context.Context) ([]ImportSession, error) {
+	units, err := s.archiveUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []ImportSession
+	for _, unit := range units {
+		if strings.Contains(unit, ":") {
+			continue
+		}
+		rows, err := s.archiveRows(ctx, unit)
+		if err != nil {
+			return nil, err
+		}
+		session := ImportSession{Hash: unit, Source: s.path, rows: rows}
+		for _, r := range rows {
+			if r.key == composerKeyPrefix+unit {
+				session.Title = composerTitle(r.value)
+			}
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// 从composerData中读取标题，无法解析时返回空字符串
+func composerTitle(value any) string {
+	var doc struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(rawBytes(value), &doc)
+	return doc.Name
+}
+
+// 以字节形式返回记录的值
+func rawBytes(value any) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+// 解析show -format json或-format raw输出的会话
+// raw格式原样写入composerData和气泡；json格式按较新版本的存储方式写入，消息保存为单独的气泡
+// 没有composerId时（使用-redact或-anonymize的输出）只返回标题和来源，PlanImport不会导入，
+// 否则每次导入都会以新ID写入同一会话的副本
+func ParseImportJSON(data []byte, source string) (ImportSession, error) {
+	var doc struct {
+		ComposerData json.RawMessage            `json:"composerData"`
+		Bubbles      map[string]json.RawMessage `json:"bubbles"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ImportSession{}, fmt.Errorf("parse JSON: %v", err)
+	}
+	if doc.ComposerData != nil {
+		return importRaw(doc.ComposerData, doc.Bubbles, source)
+	}
+	var record ChatRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return ImportSession{}, fmt.Errorf("parse JSON: %v", err)
+	}
+	return importRecord(record, source)
+}
+
+// 原始格式的composerData和气泡
+func importRaw(composerData json.RawMessage, bubbles map[string]json.RawMessage, source string) (ImportSession, error) {
+	var doc struct {
+		ComposerId string `json:"composerId"`
+		Name       string `json:"name"`
+	}
+	if err := json.Unmarshal(composerData, &doc); err != nil {
+		return ImportSession{}, fmt.Errorf("parse composerData: %v", err)
+	}
+	session := ImportSession{Hash: doc.ComposerId, Title: doc.Name, Source: source}
+	if session.Hash == "" {
+		return session, nil
+	}
+	session.rows = append(session.rows, archiveRow{"cursorDiskKV", composerKeyPrefix + session.Hash, string(composerData)})
+	for id, raw := range bubbles {
+		session.rows = append(session.rows, archiveRow{"cursorDiskKV", bubbleKeyPrefix + session.Hash + ":" + id, string(raw)})
+	}
+	return session, nil
+}
+
+// 规范化的会话记录，消息写入单独的气泡，composerData中只保存fullConversationHeadersOnly
+func importRecord(record ChatRecord, source string) (ImportSession, error) {
+	if len(record.Conversation) == 0 {
+		return ImportSession{}, fmt.Errorf("%w: no messages", ErrInvalidSession)
+	}
+	hash, _ := record.Field("composerId").(string)
+	session := ImportSession{Hash: hash, Title: record.Name, Source: source}
+	if hash == "" {
+		return session, nil
+	}
+
+	type header struct {
+		BubbleId string `json:"bubbleId"`
+		Type     int    `json:"type"`
+	}
+	var headers []header
+	for _, msg := range record.Conversation {
+		id, _ := msg.Field("bubbleId").(string)
+		if id == "" {
+			id = newComposerId()
+			if msg.Extra == nil {
+				msg.Extra = make(map[string]json.RawMessage)
+			}
+			msg.Extra["bubbleId"], _ = json.Marshal(id)
+		}
+		value, err := json.Marshal(msg)
+		if err != nil {
+			return ImportSession{}, fmt.Errorf("encode message: %v", err)
+		}
+		headers = append(headers, header{id, msg.Type})
+		session.rows = append(session.rows, archiveRow{"cursorDiskKV", bubbleKeyPrefix + hash + ":" + id, string(value)})
+	}
+
+	// 去掉加载时计算或导出时添加的字段
+	record.Conversation = []Message{}
+	record.AppliedChanges = nil
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ImportSession{}, fmt.Errorf("encode composerData: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ImportSession{}, fmt.Errorf("encode composerData: %v", err)
+	}
+	delete(fields, "EndedAt")
+	// 导出时可能附带的关联提交
+	delete(fields, "relatedCommits")
+	fields["composerId"], _ = json.Marshal(hash)
+	fields["fullConversationHeadersOnly"], _ = json.Marshal(headers)
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return ImportSession{}, fmt.Errorf("encode composerData: %v", err)
+	}
+	session.rows = append(session.rows, archiveRow{"cursorDiskKV", composerKeyPrefix + hash, string(data)})
+	return session, nil
+}
+
+// 生成随机的UUID（第4版），与Cursor的composer ID和气泡ID格式相同
+func newComposerId() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// 检查数据库文件旁是否有其他进程打开数据库时留下的文件，有时返回包装了ErrLocked的错误，必须在Open之前调用
+// WAL模式下连接会创建-shm文件，最后一个连接正常关闭时删除-wal和-shm；-journal表示有未完成的事务。
+// Cursor异常退出后也会留下这些文件，需要打开一次Cursor并正常退出
+func CheckInUse(path string) error {
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		info, err := os.Stat(path + suffix)
+		if err == nil && (suffix == "-shm" || info.Size() > 0) {
+			return fmt.Errorf("%w: %s exists", ErrLocked, path+suffix)
+		}
+	}
+	return nil
+}
+
+// 检查数据库是否被其他进程锁定，锁定时返回包装了ErrLocked的错误
+// 使用排他事务检查，只能发现其他进程正在进行的读写；空闲的Cursor不持有锁，需要同时使用CheckInUse
+func (s *Store) CheckLocked(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("open database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		return fmt.Errorf("open database: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		return lockError(err, "open database")
+	}
+	_, err = conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+// sqlite报告数据库被锁定时返回包装了ErrLocked的错误，其他错误加上操作的说明
+func lockError(err error, what string) error {
+	msg := err.Error()
+	if strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked") {
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	}
+	return fmt.Errorf("%s: %v", what, err)
+}
+
+// 生成导入计划，不修改数据库
+// 与目标数据库中已有的会话内容相同时总是跳过，其他冲突按mode处理；多个来源中的同一会话只导入第一个
+func (s *Store) PlanImport(ctx context.Context, sessions []ImportSession, mode ConflictMode) ([]ImportItem, error) {
+	items := make([]ImportItem, 0, len(sessions))
+	seen := make(map[string]bool)
+	for _, session := range sessions {
+		item := ImportItem{Hash: session.Hash, Title: session.Title, Source: session.Source, Rows: len(session.rows), Action: ImportAdd}
+		if session.Hash == "" {
+			item.Action = ImportNoID
+			items = append(items, item)
+			continue
+		}
+		if seen[session.Hash] {
+			item.Action = ImportDuplicate
+			items = append(items, item)
+			continue
+		}
+		seen[session.Hash] = true
+
+		existing, err := s.archiveRows(ctx, session.Hash)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(existing) == 0:
+		case checksumRows(existing) == checksumRows(session.rows):
+			item.Action = ImportSame
+		case mode == ConflictOverwrite:
+			item.Action = ImportOverwrite
+		case mode == ConflictRename:
+			item.Action = ImportRename
+			item.NewHash = newComposerId()
+		default:
+			item.Action = ImportSkip
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// 将数据库的一致副本写入path，path必须不存在
+func (s *Store) BackupTo(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return lockError(err, "back up database")
+	}
+	return nil
+}
+
+// 按导入计划写入会话，所有写入在一个排他事务中完成，失败时数据库不变
+// items必须是PlanImport对同样的sessions生成的计划
+func (s *Store) Import(ctx context.Context, sessions []ImportSession, items []ImportItem) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("open database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 0"); err != nil {
+		return fmt.Errorf("open database: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		return lockError(err, "open database")
+	}
+	if err := importRows(ctx, conn, sessions, items); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return lockError(err, "write database")
+	}
+	return nil
+}
+
+// 在已开始的事务中写入会话
+func importRows(ctx context.Context, conn *sql.Conn, sessions []ImportSession, items []ImportItem) error {
+	for i, item := range items {
+		hash := item.Hash
+		switch item.Action {
+		case ImportAdd:
+		case ImportRename:
+			hash = item.NewHash
+		case ImportOverwrite:
+			for _, prefix := range archivePrefixes {
+				var err error
+				if prefix == composerKeyPrefix {
+					_, err = conn.ExecContext(ctx, "DELETE FROM cursorDiskKV WHERE key = ?", prefix+hash)
+				} else {
+					_, err = conn.ExecContext(ctx, "DELETE FROM cursorDiskKV WHERE key >= ? AND key < ?", prefix+hash+":", prefix+hash+";")
+				}
+				if err != nil {
+					return lockError(err, "write database")
+				}
+			}
+		default:
+			continue
+		}
+		for _, r := range sessions[i].rows {
+			key, value := r.key, r.value
+			if hash != item.Hash {
+				key, value = renameRow(r, item.Hash, hash)
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT OR REPLACE INTO "+r.table+" (key, value) VALUES (?, ?)", key, value); err != nil {
+				return lockError(err, "write database")
+			}
+		}
+	}
+	return nil
+}
+
+// 将记录改为属于新的composer ID：替换键中的ID和值中的composerId字段
+// composerData总是写入新的composerId；气泡等其他记录只在带有旧ID时替换，避免按composerId查找时关联到原会话
+func renameRow(r archiveRow, from, to string) (string, any) {
+	for _, prefix := range archivePrefixes {
+		if strings.HasPrefix(r.key, prefix+from) {
+			key := prefix + to + strings.TrimPrefix(r.key, prefix+from)
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(rawBytes(r.value), &fields) != nil {
+				return key, r.value
+			}
+			if prefix != composerKeyPrefix {
+				var id string
+				if json.Unmarshal(fields["composerId"], &id) != nil || id != from {
+					return key, r.value
+				}
+			}
+			fields["composerId"], _ = json.Marshal(to)
+			data, err := json.Marshal(fields)
+			if err != nil {
+				return key, r.value
+			}
+			return key, string(data)
+		}
+	}
+	return r.key, r.value
+}
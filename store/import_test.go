@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// 使用-redact或-anonymize的输出没有composerId，导入计划中标记为no-id而不是以新ID写入
+func TestPlanImportWithoutComposerId(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(newTestDB(t, true, nil, nil), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var sessions []ImportSession
+	for _, data := range []string{
+		`{"name": "stripped", "conversation": [{"type": 1, "text": "hello"}]}`,
+		`{"composerData": {"name": "stripped raw"}, "bubbles": {}}`,
+		`{"composerId": "` + inlineID + `", "name": "kept", "conversation": [{"type": 1, "text": "hello"}]}`,
+	} {
+		session, err := ParseImportJSON([]byte(data), "chat.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	items, err := s.PlanImport(ctx, sessions, ConflictRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{ImportNoID, ImportNoID, ImportAdd}
+	for i, item := range items {
+		if item.Action != want[i] {
+			t.Errorf("item %d (%s) action = %s, want %s", i, item.Title, item.Action, want[i])
+		}
+	}
+	if err := s.Import(ctx, sessions, items); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := scanAll(t, s)
+	if len(found) != 1 || found[0].Hash != inlineID {
+		t.Errorf("imported %+v, want only %s", found, inlineID)
+	}
+}
+
+// 数据库旁有-wal或-shm文件时认为其他进程（通常是Cursor）正在使用数据库
+func TestCheckInUse(t *testing.T) {
+	path := newTestDB(t, true, nil, nil)
+	if err := CheckInUse(path); err != nil {
+		t.Fatalf("no other process: %v", err)
+	}
+	// 空的-wal文件是正常关闭后留下的
+	if err := os.WriteFile(path+"-wal", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckInUse(path); err != nil {
+		t.Errorf("empty -wal: %v", err)
+	}
+	if err := os.WriteFile(path+"-shm", []byte{0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckInUse(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("with -shm: got %v, want ErrLocked", err)
+	}
+}
+
+// 与已有会话冲突时以新ID导入，气泡记录中的composerId同样替换为新ID
+func TestImportRename(t *testing.T) {
+	ctx := context.Background()
+	path := newTestDB(t, true, map[string]any{
+		composerKeyPrefix + inlineID: inlineComposer(inlineID, "existing"),
+	}, nil)
+	s, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	data := `{
+		"composerData": {"composerId": "` + inlineID + `", "name": "imported", "createdAt": 1704186000000,
+			"fullConversationHeadersOnly": [{"bubbleId": "b1", "type": 1}, {"bubbleId": "b2", "type": 2}]},
+		"bubbles": {
+			"b1": {"bubbleId": "b1", "composerId": "` + inlineID + `", "type": 1, "text": "question"},
+			"b2": {"bubbleId": "b2", "type": 2, "text": "answer without composerId"}
+		}
+	}`
+	session, err := ParseImportJSON([]byte(data), "chat.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := s.PlanImport(ctx, []ImportSession{session}, ConflictRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Action != ImportRename || items[0].NewHash == "" || items[0].NewHash == inlineID {
+		t.Fatalf("plan = %+v, want a rename to a new ID", items)
+	}
+	if err := s.Import(ctx, []ImportSession{session}, items); err != nil {
+		t.Fatal(err)
+	}
+	newHash := items[0].NewHash
+
+	renamed, err := s.Session(ctx, newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Title != "imported" || len(renamed.Record.Conversation) != 2 || renamed.Record.Conversation[1].Text != "answer without composerId" {
+		t.Errorf("renamed session = %+v, want both imported messages", renamed.Record)
+	}
+	if id := renamed.Record.Field("composerId"); id != newHash {
+		t.Errorf("composerData composerId = %v, want %s", id, newHash)
+	}
+	bubbles, err := s.RawBubbles(ctx, newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b1, b2 map[string]any
+	json.Unmarshal(bubbles["b1"], &b1)
+	json.Unmarshal(bubbles["b2"], &b2)
+	if b1["composerId"] != newHash || b1["text"] != "question" {
+		t.Errorf("bubble b1 = %v, want composerId %s", b1, newHash)
+	}
+	if _, ok := b2["composerId"]; ok || b2["text"] != "answer without composerId" {
+		t.Errorf("bubble b2 = %v, want it unchanged", b2)
+	}
+
+	// 原有的会话不变
+	original, err := s.Session(ctx, inlineID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(original.Record.Conversation) != 1 || original.Record.Conversation[0].Text != "existing" {
+		t.Errorf("original session = %+v", original.Record)
+	}
+	if leftover, _ := s.RawBubbles(ctx, inlineID); len(leftover) != 0 {
+		t.Errorf("bubbles of the original session = %v, want none", leftover)
+	}
+}
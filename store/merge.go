@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// 合并多个数据库中的会话，例如不同机器上的state.vscdb副本
+// 同一会话（composer ID相同）出现在多个数据库中时只保留一个版本，选择规则见better
+type Merged struct {
+	stores []*Store
+}
+
+// 依次打开多个数据库，任何一个打开失败时关闭已打开的数据库并返回错误
+func OpenMerged(paths []string, opts Options) (*Merged, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: no database given", ErrDBNotFound)
+	}
+	m := &Merged{}
+	for _, path := range paths {
+		s, err := Open(path, opts)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.stores = append(m.stores, s)
+	}
+	return m, nil
+}
+
+// 合并的数据库，顺序与打开时相同
+func (m *Merged) Stores() []*Store {
+	return m.stores
+}
+
+// 关闭所有数据库，返回第一个错误
+func (m *Merged) Close() error {
+	var first error
+	for _, s := range m.stores {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// 与Store.Sessions相同，同一会话只产生选中的版本
+func (m *Merged) Sessions(ctx context.Context, filter Filter) iter.Seq2[Session, error] {
+	return m.Scan(ctx, filter, nil)
+}
+
+// 与Store.Scan相同，被跳过的记录带有所在的数据库
+// 没有选中的版本也作为跳过的记录交给onSkip，原因为SkipSuperseded或SkipDiverged
+// 需要读取所有数据库之后才能选择版本，因此第一个会话要等全部读取完成后才会产生
+func (m *Merged) Scan(ctx context.Context, filter Filter, onSkip SkipHandler) iter.Seq2[Session, error] {
+	if len(m.stores) == 1 {
+		return m.stores[0].Scan(ctx, filter, onSkip)
+	}
+	return func(yield func(Session, error) bool) {
+		var order []string
+		versions := make(map[string][]Session)
+		for _, s := range m.stores {
+			for session, err := range s.Scan(ctx, Filter{}, onSkip.withSource(s.path)) {
+				if err != nil {
+					yield(Session{}, err)
+					return
+				}
+				if _, ok := versions[session.Hash]; !ok {
+					order = append(order, session.Hash)
+				}
+				versions[session.Hash] = append(versions[session.Hash], session)
+			}
+		}
+
+		// 先选择版本再过滤，避免较旧的版本因为符合条件而被选中
+		for _, hash := range order {
+			session, dropped := mergeVersions(versions[hash])
+			if !filter.Match(*session.Record) {
+				continue
+			}
+			for _, d := range dropped {
+				onSkip.report(d)
+			}
+			if !yield(session, nil) {
+				return
+			}
+		}
+	}
+}
+
+// 读取指定ID的会话，多个数据库中都有时返回选中的版本
+// 某个数据库中的版本无法解析时使用其他数据库中的版本，都没有时返回第一个错误
+func (m *Merged) Session(ctx context.Context, hash string) (Session, error) {
+	return m.ScanSession(ctx, hash, nil)
+}
+
+// 与Session相同，无法解析的版本和没有选中的版本交给onSkip，与Scan报告的记录一致
+func (m *Merged) ScanSession(ctx context.Context, hash string, onSkip SkipHandler) (Session, error) {
+	var versions []Session
+	var firstErr error
+	for _, s := range m.stores {
+		session, err := s.Session(ctx, hash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			// 与Scan一致，只在合并多个数据库时记录所在的数据库
+			handler := onSkip
+			if len(m.stores) > 1 {
+				handler = onSkip.withSource(s.path)
+			}
+			handler.skip(composerKeyPrefix+hash, skipReason(err), err)
+			continue
+		}
+		versions = append(versions, session)
+	}
+	if len(versions) == 0 {
+		if firstErr != nil {
+			return Session{}, firstErr
+		}
+		return Session{}, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+	session, dropped := mergeVersions(versions)
+	for _, d := range dropped {
+		onSkip.report(d)
+	}
+	return session, nil
+}
+
+// 从会话所在的数据库读取气泡的原始JSON
+func (m *Merged) RawBubbles(ctx context.Context, session Session) (map[string]json.RawMessage, error) {
+	for _, s := range m.stores {
+		if s.path == session.Source {
+			return s.RawBubbles(ctx, session.Hash)
+		}
+	}
+	return map[string]json.RawMessage{}, nil
+}
+
+// 从同一会话的多个版本中选择一个，返回选中的版本和其他版本对应的跳过记录
+// versions按数据库的顺序排列，选择结果只取决于各版本的内容和数据库的顺序
+func mergeVersions(versions []Session) (Session, []Skipped) {
+	best := versions[0]
+	for _, v := range versions[1:] {
+		if better(v, best) {
+			best = v
+		}
+	}
+
+	var dropped []Skipped
+	for _, v := range versions {
+		if v.Source == best.Source {
+			continue
+		}
+		s := Skipped{Key: composerKeyPrefix + v.Hash, Source: v.Source, Reason: SkipSuperseded}
+		switch {
+		case !isPrefix(v.Record.Conversation, best.Record.Conversation):
+			s.Reason = SkipDiverged
+			s.Error = fmt.Sprintf("messages differ from the version in %s, using that version", best.Source)
+		case v.Title != best.Title:
+			s.Error = fmt.Sprintf("title is %q, using the version in %s titled %q", v.Title, best.Source, best.Title)
+		default:
+			s.Error = fmt.Sprintf("using the version in %s", best.Source)
+		}
+		dropped = append(dropped, s)
+	}
+	return best, dropped
+}
+
+// 判断版本a是否比b更完整或更新
+// 依次比较消息数量、最后更新时间和原始数据大小，都相同时保留先出现的版本
+func better(a, b Session) bool {
+	if a.MessageCount != b.MessageCount {
+		return a.MessageCount > b.MessageCount
+	}
+	if ua, ub := updatedAt(a), updatedAt(b); ua != ub {
+		return ua > ub
+	}
+	return a.Size > b.Size
+}
+
+// 会话的最后更新时间，单位为毫秒
+// 较新版本的Cursor在composerData中记录lastUpdatedAt，重命名会话时也会更新
+func updatedAt(s Session) int64 {
+	t := s.Record.EndedAt
+	if v, ok := s.Record.Field("lastUpdatedAt").(float64); ok && int64(v) > t {
+		t = int64(v)
+	}
+	return t
+}
+
+// 判断消息列表a是否为b的前缀，即b是在a的基础上继续的会话
+// 两条消息都有bubbleId时按bubbleId比较，否则比较类型和内容
+func isPrefix(a, b []Message) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		idA, okA := a[i].Field("bubbleId").(string)
+		idB, okB := b[i].Field("bubbleId").(string)
+		if okA && okB {
+			if idA != idB {
+				return false
+			}
+			continue
+		}
+		if a[i].Type != b[i].Type || a[i].Text != b[i].Text {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mergeID = "eeeeeeee-0000-4000-8000-000000000005"
+
+// 合并测试用的composerData，每个气泡ID对应一条消息，第i条消息在第i分钟结束
+func mergeComposer(name string, bubbles ...string) map[string]any {
+	var conversation []map[string]any
+	for i, id := range bubbles {
+		start := int64(1704186000000 + i*60000)
+		conversation = append(conversation, map[string]any{
+			"type":       1 + i%2,
+			"bubbleId":   id,
+			"text":       "message " + id,
+			"timingInfo": map[string]any{"clientStartTime": start, "clientEndTime": start + 1000},
+		})
+	}
+	return map[string]any{"composerId": mergeID, "name": name, "createdAt": 1704186000000, "conversation": conversation}
+}
+
+// 为每个composerData创建一个数据库，合并后读取所有会话和跳过的记录
+func scanMerged(t *testing.T, filter Filter, composers ...map[string]any) ([]string, []Session, []Skipped) {
+	t.Helper()
+	var paths []string
+	for _, c := range composers {
+		paths = append(paths, newTestDB(t, true, map[string]any{composerKeyPrefix + mergeID: c}, nil))
+	}
+	m, err := OpenMerged(paths, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	var sessions []Session
+	var skipped []Skipped
+	for session, err := range m.Scan(context.Background(), filter, func(s Skipped) { skipped = append(skipped, s) }) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return paths, sessions, skipped
+}
+
+// 返回修改了部分字段的副本
+func with(c map[string]any, fields map[string]any) map[string]any {
+	result := make(map[string]any, len(c)+len(fields))
+	for k, v := range c {
+		result[k] = v
+	}
+	for k, v := range fields {
+		result[k] = v
+	}
+	return result
+}
+
+func TestMergeVersions(t *testing.T) {
+	base := mergeComposer("Session", "a", "b")
+	tests := []struct {
+		name      string
+		composers []map[string]any
+		want      int      // 选中的数据库
+		reasons   []string // 其他数据库的跳过原因，按数据库顺序
+		message   string   // 第一个跳过记录的错误信息中应当包含的内容，%s为选中的数据库
+	}{
+		{
+			name:      "more messages",
+			composers: []map[string]any{base, mergeComposer("Session", "a", "b", "c"), base},
+			want:      1,
+			reasons:   []string{SkipSuperseded, SkipSuperseded},
+			message:   "using the version in %s",
+		},
+		{
+			name:      "more messages before a later update",
+			composers: []map[string]any{mergeComposer("Session", "a", "b", "c"), with(base, map[string]any{"lastUpdatedAt": 1804186000000})},
+			want:      0,
+			reasons:   []string{SkipSuperseded},
+		},
+		{
+			name:      "lastUpdatedAt",
+			composers: []map[string]any{with(base, map[string]any{"lastUpdatedAt": 1704186100000}), with(base, map[string]any{"lastUpdatedAt": 1704186200000})},
+			want:      1,
+			reasons:   []string{SkipSuperseded},
+		},
+		{
+			// lastUpdatedAt早于最后一条消息时使用消息的结束时间，两个版本的大小相同
+			name:      "lastUpdatedAt before the last message",
+			composers: []map[string]any{with(base, map[string]any{"lastUpdatedAt": 1704185000000}), with(base, map[string]any{"lastUpdatedAt": 1704186100000})},
+			want:      1,
+			reasons:   []string{SkipSuperseded},
+		},
+		{
+			name: "EndedAt",
+			composers: []map[string]any{base, func() map[string]any {
+				c := mergeComposer("Session", "a", "b")
+				c["conversation"].([]map[string]any)[1]["timingInfo"] = map[string]any{"clientStartTime": 1704186060000, "clientEndTime": 1704186090000}
+				return c
+			}()},
+			want:    1,
+			reasons: []string{SkipSuperseded},
+		},
+		{
+			name:      "size",
+			composers: []map[string]any{base, with(base, map[string]any{"unifiedMode": "agent"}), base},
+			want:      1,
+			reasons:   []string{SkipSuperseded, SkipSuperseded},
+		},
+		{
+			name:      "full tie",
+			composers: []map[string]any{base, base, base},
+			want:      0,
+			reasons:   []string{SkipSuperseded, SkipSuperseded},
+			message:   "using the version in %s",
+		},
+		{
+			name:      "rename",
+			composers: []map[string]any{with(base, map[string]any{"name": "Old"}), with(base, map[string]any{"name": "New", "lastUpdatedAt": 1804186000000})},
+			want:      1,
+			reasons:   []string{SkipSuperseded},
+			message:   `title is "Old", using the version in %s titled "New"`,
+		},
+		{
+			name:      "diverged",
+			composers: []map[string]any{base, mergeComposer("Session", "a", "c", "d")},
+			want:      1,
+			reasons:   []string{SkipDiverged},
+			message:   "messages differ from the version in %s",
+		},
+		{
+			// 相同位置的消息内容不同但气泡ID相同时视为同一条消息
+			name:      "edited message",
+			composers: []map[string]any{with(base, map[string]any{"conversation": []map[string]any{{"type": 1, "bubbleId": "a", "text": "edited"}}}), base},
+			want:      1,
+			reasons:   []string{SkipSuperseded},
+		},
+		{
+			// 没有气泡ID时比较类型和内容
+			name: "diverged without bubble IDs",
+			composers: []map[string]any{
+				with(base, map[string]any{"conversation": []map[string]any{{"type": 1, "text": "hello"}}}),
+				with(base, map[string]any{"conversation": []map[string]any{{"type": 1, "text": "hi"}, {"type": 2, "text": "there"}}}),
+			},
+			want:    1,
+			reasons: []string{SkipDiverged},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, sessions, skipped := scanMerged(t, Filter{}, tt.composers...)
+			if len(sessions) != 1 {
+				t.Fatalf("got %d sessions, want 1", len(sessions))
+			}
+			if sessions[0].Source != paths[tt.want] {
+				t.Errorf("selected %s, want database %d (%s)", sessions[0].Source, tt.want, paths[tt.want])
+			}
+			var reasons, sources []string
+			for _, s := range skipped {
+				reasons = append(reasons, s.Reason)
+				sources = append(sources, s.Source)
+				if s.Key != composerKeyPrefix+mergeID {
+					t.Errorf("skipped key = %q", s.Key)
+				}
+			}
+			if !slices.Equal(reasons, tt.reasons) {
+				t.Errorf("skipped reasons = %v, want %v", reasons, tt.reasons)
+			}
+			var others []string
+			for i, p := range paths {
+				if i != tt.want {
+					others = append(others, p)
+				}
+			}
+			if !slices.Equal(sources, others) {
+				t.Errorf("skipped sources = %v, want %v", sources, others)
+			}
+			if tt.message != "" && len(skipped) > 0 {
+				if want := strings.ReplaceAll(tt.message, "%s", paths[tt.want]); !strings.Contains(skipped[0].Error, want) {
+					t.Errorf("skipped error = %q, want %q", skipped[0].Error, want)
+				}
+			}
+		})
+	}
+}
+
+// 先选择版本再过滤: 较旧的版本符合条件而选中的版本不符合时不返回该会话
+func TestMergeFilterAfterSelection(t *testing.T) {
+	older := mergeComposer("Session", "a", "b")
+	newer := mergeComposer("Session", "a", "b", "c")
+	// 旧版本在第2分钟结束，新版本在第3分钟结束
+	filter := Filter{EndBefore: time.UnixMilli(1704186000000 + 90000)}
+
+	_, sessions, skipped := scanMerged(t, filter, older, newer)
+	if len(sessions) != 0 {
+		t.Errorf("got %+v, want no session because the selected version ends too late", sessions)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("skipped = %+v, want nothing reported for a filtered session", skipped)
+	}
+
+	_, sessions, _ = scanMerged(t, Filter{EndAfter: time.UnixMilli(1704186000000 + 90000)}, older, newer)
+	if len(sessions) != 1 || sessions[0].MessageCount != 3 {
+		t.Errorf("got %+v, want the newer version", sessions)
+	}
+}
+
+// 按ID读取时与Scan选择相同的版本并报告相同的跳过记录
+func TestMergedScanSession(t *testing.T) {
+	var paths []string
+	for _, c := range []map[string]any{mergeComposer("Session", "a"), mergeComposer("Session", "a", "b"), mergeComposer("Session", "x")} {
+		paths = append(paths, newTestDB(t, true, map[string]any{composerKeyPrefix + mergeID: c}, nil))
+	}
+	m, err := OpenMerged(paths, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var skipped []Skipped
+	session, err := m.ScanSession(context.Background(), mergeID, func(s Skipped) { skipped = append(skipped, s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Source != paths[1] {
+		t.Errorf("selected %s, want %s", session.Source, paths[1])
+	}
+	want := []Skipped{{Source: paths[0], Reason: SkipSuperseded}, {Source: paths[2], Reason: SkipDiverged}}
+	if len(skipped) != len(want) {
+		t.Fatalf("skipped = %+v, want %+v", skipped, want)
+	}
+	for i := range want {
+		if skipped[i].Source != want[i].Source || skipped[i].Reason != want[i].Reason {
+			t.Errorf("skipped[%d] = %+v, want %+v", i, skipped[i], want[i])
+		}
+	}
+}
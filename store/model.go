@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 文件URI
+type Uri struct {
+	Path string `json:"path"`
+}
+
+// 引用的文件
+type FileSelection struct {
+	Uri Uri `json:"uri"`
+}
+
+// 引用的代码片段
+type Selection struct {
+	Text string `json:"text"`
+	Uri  Uri    `json:"uri"`
+}
+
+// AI回复中的代码块
+type CodeBlock struct {
+	Uri        Uri    `json:"uri"`
+	Content    string `json:"content"`
+	LanguageId string `json:"languageId"`
+}
+
+// 消息的时间信息，单位为毫秒
+type TimingInfo struct {
+	ClientStartTime int64 `json:"clientStartTime"`
+	ClientEndTime   int64 `json:"clientEndTime"`
+}
+
+// 消息的上下文
+type MessageContext struct {
+	FileSelections []FileSelection `json:"fileSelections"`
+	Selections     []Selection     `json:"selections"`
+}
+
+// 会话中的一条消息，Type为1表示用户，2表示AI
+type Message struct {
+	Type       int            `json:"type"`
+	Text       string         `json:"text"`
+	Context    MessageContext `json:"context"`
+	TimingInfo TimingInfo     `json:"timingInfo"`
+	CodeBlocks []CodeBlock    `json:"codeBlocks"`
+	// 没有对应字段的其他数据，例如bubbleId、richText和工具调用结果，序列化时原样输出
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// 会话的上下文
+type RecordContext struct {
+	FileSelections []FileSelection `json:"fileSelections"`
+}
+
+// 会话使用的模型
+type ModelConfig struct {
+	ModelName string `json:"modelName"`
+}
+
+// composerData中保存的会话记录
+type ChatRecord struct {
+	Conversation []Message     `json:"conversation"`
+	Name         string        `json:"name"`
+	Status       string        `json:"status"`
+	Context      RecordContext `json:"context"`
+	CreatedAt    int64         `json:"createdAt"` // 毫秒
+	EndedAt      int64         // 最后一条消息的结束时间，毫秒，由加载时计算
+	ModelConfig  ModelConfig   `json:"modelConfig"`
+	// 从inlineDiffsData、codeBlockDiff和检查点记录中解析出的修改，不在composerData中
+	AppliedChanges []AppliedChange `json:"appliedChanges,omitempty"`
+
+	// composerData的原始JSON，不随脱敏和匿名化处理
+	Raw json.RawMessage `json:"-"`
+	// 没有对应字段的其他数据，序列化时原样输出
+	Extra map[string]json.RawMessage `json:"-"`
+	// 较新版本的Cursor单独保存的气泡原始JSON，按气泡ID索引，只在需要时通过Store.RawBubbles读取
+	RawBubbles map[string]json.RawMessage `json:"-"`
+}
+
+// 一处连续的修改，行号从1开始
+type ChangeHunk struct {
+	OriginalStart int      `json:"originalStart"`
+	OriginalEnd   int      `json:"originalEnd"`       // 不包含
+	Removed       []string `json:"removed,omitempty"` // 原内容，没有记录时为空
+	Added         []string `json:"added,omitempty"`   // 新内容，没有记录时为空
+	NewCount      int      `json:"newCount"`          // 修改后的行数
+}
+
+// Cursor实际应用到文件上的修改
+type AppliedChange struct {
+	Source string       `json:"source"` // inlineDiff、codeBlockDiff或checkpoint
+	ID     string       `json:"id,omitempty"`
+	Path   string       `json:"path,omitempty"`
+	Status string       `json:"status,omitempty"` // accepted、rejected、pending，未记录时为空
+	Note   string       `json:"note,omitempty"`   // 补充说明，见Note*常量
+	Hunks  []ChangeHunk `json:"hunks"`
+}
+
+// 修改记录的补充说明，由调用方翻译为显示文本
+const (
+	NoteCheckpoint = "checkpoint"  // 检查点记录的是修改前的内容
+	NoteNewFile    = "new-file"    // 修改创建了该文件
+	NoteNotExisted = "not-existed" // 修改之前文件不存在
+)
+
+// 会话及其摘要信息
+type Session struct {
+	Hash         string      // 会话ID，即composerData:后的部分
+	Title        string      // 会话标题
+	StartTime    time.Time   // 开始时间
+	EndTime      time.Time   // 结束时间
+	MessageCount int         // 消息数量
+	Size         int         // 原始数据字节数
+	Workspace    string      // 相关文件的公共目录
+	Format       string      // 转换该会话的适配器名称
+	Source       string      // 会话所在数据库的路径
+	Record       *ChatRecord // 解析后的完整记录
+}
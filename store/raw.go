@@ -0,0 +1,221 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 会话气泡在cursorDiskKV中的键前缀，完整的键为 bubbleId:<composerId>:<bubbleId>
+const bubbleKeyPrefix = "bubbleId:"
+
+// 各类型已解析的JSON字段名，按类型缓存
+var knownFieldCache sync.Map
+
+// 返回结构体类型中由json标签或字段名映射的JSON字段名
+func knownFields(t reflect.Type) map[string]bool {
+	if cached, ok := knownFieldCache.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	knownFieldCache.Store(t, fields)
+	return fields
+}
+
+// 取出JSON对象中没有对应结构体字段的部分，没有其他字段时返回nil
+// 与encoding/json一致，字段名按不区分大小写匹配
+func extraFields(data []byte, t reflect.Type) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := knownFields(t)
+	var extra map[string]json.RawMessage
+	for key, value := range all {
+		if known[key] {
+			continue
+		}
+		matched := false
+		for name := range known {
+			if strings.EqualFold(name, key) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[key] = value
+	}
+	return extra, nil
+}
+
+// 在已序列化的JSON对象末尾按键名顺序追加其他字段，已有的字段不会被覆盖
+func appendExtraFields(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+	known := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if _, ok := known[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return data, nil
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimSuffix(bytes.TrimSpace(data), []byte("}")))
+	for i, key := range keys {
+		if i > 0 || len(known) > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// 解码其他字段中的值，字段不存在或无法解析时返回nil
+func decodeExtraField(extra map[string]json.RawMessage, name string) interface{} {
+	raw, ok := extra[name]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal(raw, &v) != nil {
+		return nil
+	}
+	return v
+}
+
+// 解析会话记录，同时保留原始JSON和未解析的字段
+func (r *ChatRecord) UnmarshalJSON(data []byte) error {
+	type plain ChatRecord
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, reflect.TypeOf(p))
+	if err != nil {
+		return err
+	}
+	*r = ChatRecord(p)
+	r.Raw = append(json.RawMessage(nil), data...)
+	r.Extra = extra
+	return nil
+}
+
+// 序列化会话记录，未解析的字段追加在已知字段之后
+func (r ChatRecord) MarshalJSON() ([]byte, error) {
+	type plain ChatRecord
+	data, err := json.Marshal(plain(r))
+	if err != nil {
+		return nil, err
+	}
+	return appendExtraFields(data, r.Extra)
+}
+
+// 读取未解析的字段，供模板使用，例如 {{.Field "unifiedMode"}}
+func (r ChatRecord) Field(name string) interface{} {
+	return decodeExtraField(r.Extra, name)
+}
+
+// 解析消息，同时保留未解析的字段
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, reflect.TypeOf(p))
+	if err != nil {
+		return err
+	}
+	*m = Message(p)
+	m.Extra = extra
+	return nil
+}
+
+// 序列化消息，未解析的字段追加在已知字段之后
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	data, err := json.Marshal(plain(m))
+	if err != nil {
+		return nil, err
+	}
+	return appendExtraFields(data, m.Extra)
+}
+
+// 读取未解析的字段，供模板使用，例如 {{.Field "bubbleId"}}
+func (m Message) Field(name string) interface{} {
+	return decodeExtraField(m.Extra, name)
+}
+
+// 返回去掉原始JSON和未解析字段的副本
+// 脱敏和匿名化只处理已解析的字段，输出前需要丢弃原始数据，避免敏感信息通过它们泄露
+func (r ChatRecord) StripRaw() ChatRecord {
+	r.Raw = nil
+	r.Extra = nil
+	r.RawBubbles = nil
+	conversation := make([]Message, len(r.Conversation))
+	for i, msg := range r.Conversation {
+		msg.Extra = nil
+		conversation[i] = msg
+	}
+	r.Conversation = conversation
+	return r
+}
+
+// 读取会话的所有气泡的原始JSON，按气泡ID索引
+// 较新版本的Cursor将消息单独保存在bubbleId:<composerId>:<bubbleId>中，旧版本没有这些记录
+func (s *Store) RawBubbles(ctx context.Context, hash string) (map[string]json.RawMessage, error) {
+	prefix := bubbleKeyPrefix + hash + ":"
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM cursorDiskKV WHERE key >= ? AND key < ?", prefix, prefix+"\xff")
+	if err != nil {
+		return nil, fmt.Errorf("query database: %v", err)
+	}
+	defer rows.Close()
+
+	bubbles := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil || !json.Valid(value) {
+			continue
+		}
+		bubbles[strings.TrimPrefix(key, prefix)] = json.RawMessage(value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read database: %v", err)
+	}
+	return bubbles, nil
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 包含较新版本Cursor字段的composerData，Status和CreatedAt的大小写与结构体标签不同
+const extraComposer = `{
+	"composerId": "c1",
+	"name": "Agent session",
+	"Status": "completed",
+	"CreatedAt": 1704099600000,
+	"unifiedMode": "agent",
+	"forceMode": null,
+	"latestConversationSummary": {"summary": {"summary": "sé", "truncated": false}},
+	"big": 12345678901234567890,
+	"conversation": [
+		{"type": 1, "text": "hi", "bubbleId": "b1", "richText": "{\"root\":{}}", "TEXT_extra": [1, 2]},
+		{"type": 2, "text": "hello", "bubbleId": "b2", "toolFormerData": {"name": "read_file"}}
+	]
+}`
+
+// 返回JSON对象的键，按字母排序
+func objectKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compactJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	return buf.String()
+}
+
+// 解析后再序列化不能丢失未知字段，已知字段不区分大小写匹配，不能重复输出
+func TestChatRecordRoundTrip(t *testing.T) {
+	var record ChatRecord
+	if err := json.Unmarshal([]byte(extraComposer), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != "completed" || record.CreatedAt != 1704099600000 {
+		t.Errorf("status, createdAt = %q, %d; want the fields matched case-insensitively", record.Status, record.CreatedAt)
+	}
+	extraKeys := func(extra map[string]json.RawMessage) []string {
+		keys := make([]string, 0, len(extra))
+		for key := range extra {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	if got, want := extraKeys(record.Extra), []string{"big", "composerId", "forceMode", "latestConversationSummary", "unifiedMode"}; !slices.Equal(got, want) {
+		t.Errorf("record extra keys = %v, want %v", got, want)
+	}
+	if len(record.Conversation) != 2 {
+		t.Fatalf("parsed %d messages, want 2", len(record.Conversation))
+	}
+	if got, want := extraKeys(record.Conversation[0].Extra), []string{"TEXT_extra", "bubbleId", "richText"}; !slices.Equal(got, want) {
+		t.Errorf("message extra keys = %v, want %v", got, want)
+	}
+	if !bytes.Equal(record.Raw, []byte(extraComposer)) {
+		t.Error("Raw is not the original composerData")
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in, out map[string]json.RawMessage
+	json.Unmarshal([]byte(extraComposer), &in)
+	json.Unmarshal(data, &out)
+	keys := objectKeys(t, data)
+	for key := range in {
+		if key == "Status" || key == "CreatedAt" {
+			key = strings.ToLower(key[:1]) + key[1:]
+		}
+		if _, ok := out[key]; !ok {
+			t.Errorf("key %q was lost: %v", key, keys)
+		}
+	}
+	for _, key := range keys {
+		if key == "Status" || key == "CreatedAt" {
+			t.Errorf("key %q is output twice: %v", key, keys)
+		}
+	}
+	for key := range record.Extra {
+		if got, want := compactJSON(t, out[key]), compactJSON(t, in[key]); got != want {
+			t.Errorf("%s = %s, want %s", key, got, want)
+		}
+	}
+
+	var inMessages, outMessages []json.RawMessage
+	json.Unmarshal(in["conversation"], &inMessages)
+	json.Unmarshal(out["conversation"], &outMessages)
+	for i := range inMessages {
+		var inMsg, outMsg map[string]json.RawMessage
+		json.Unmarshal(inMessages[i], &inMsg)
+		json.Unmarshal(outMessages[i], &outMsg)
+		for key, value := range inMsg {
+			if got, want := compactJSON(t, outMsg[key]), compactJSON(t, value); got != want {
+				t.Errorf("message %d %s = %s, want %s", i, key, got, want)
+			}
+		}
+	}
+
+	// 再次解析得到相同的记录
+	var again ChatRecord
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Name != record.Name || again.Field("unifiedMode") != "agent" || again.Conversation[1].Field("bubbleId") != "b2" {
+		t.Errorf("record after a round trip = %+v", again)
+	}
+}
+
+func TestField(t *testing.T) {
+	var record ChatRecord
+	if err := json.Unmarshal([]byte(extraComposer), &record); err != nil {
+		t.Fatal(err)
+	}
+	if got := record.Field("unifiedMode"); got != "agent" {
+		t.Errorf(`Field("unifiedMode") = %v, want agent`, got)
+	}
+	summary, ok := record.Field("latestConversationSummary").(map[string]interface{})
+	if !ok || summary["summary"].(map[string]interface{})["summary"] != "sé" {
+		t.Errorf(`Field("latestConversationSummary") = %#v`, record.Field("latestConversationSummary"))
+	}
+	// 已解析的字段和不存在的字段返回nil，forceMode的值本身是null
+	for _, name := range []string{"name", "Status", "missing", "forceMode"} {
+		if got := record.Field(name); got != nil {
+			t.Errorf("Field(%q) = %v, want nil", name, got)
+		}
+	}
+	if got := record.Conversation[0].Field("bubbleId"); got != "b1" {
+		t.Errorf(`message Field("bubbleId") = %v, want b1`, got)
+	}
+	if got := record.Conversation[1].Field("toolFormerData"); got == nil {
+		t.Error(`message Field("toolFormerData") = nil`)
+	}
+}
+
+// 未解析的字段不能覆盖已知字段，没有已知字段时不输出多余的逗号
+func TestAppendExtraFields(t *testing.T) {
+	tests := []struct {
+		data  string
+		extra map[string]json.RawMessage
+		want  string
+	}{
+		{`{"a":1}`, nil, `{"a":1}`},
+		{`{"a":1}`, map[string]json.RawMessage{"c": json.RawMessage(`3`), "b": json.RawMessage(`"x"`)}, `{"a":1,"b":"x","c":3}`},
+		{`{"a":1}`, map[string]json.RawMessage{"a": json.RawMessage(`2`)}, `{"a":1}`},
+		{`{}`, map[string]json.RawMessage{"b": json.RawMessage(`[]`)}, `{"b":[]}`},
+	}
+	for _, tt := range tests {
+		got, err := appendExtraFields([]byte(tt.data), tt.extra)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("appendExtraFields(%s, %v) = %s, %v; want %s", tt.data, tt.extra, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package store
+
+// 记录被跳过的原因
+const (
+	SkipScanError     = "scan-error"     // 读取数据库行失败
+	SkipNullValue     = "null-value"     // 值为NULL
+	SkipEmptyValue    = "empty-value"    // 值为[]
+	SkipInvalidJSON   = "invalid-json"   // 值不是有效的JSON
+	SkipUnknownFormat = "unknown-format" // 没有适配器能处理该格式
+	SkipConvertError  = "convert-error"  // 适配器转换失败
+	SkipNoMessages    = "no-messages"    // 会话没有消息或标题无效
+	SkipLoadError     = "load-error"     // 适配器从其他位置读取会话失败
+	SkipNoComposer    = "no-composer"    // 修改记录无法关联到会话
+	SkipSuperseded    = "superseded"     // 合并多个数据库时，其他数据库中有同一会话更完整或更新的版本
+	SkipDiverged      = "diverged"       // 合并多个数据库时，同一会话的消息与选中的版本不一致
+)
+
+// 读取时被跳过的记录
+type Skipped struct {
+	Key    string `json:"key"`              // 数据库中的键，适配器读取失败时为适配器名称
+	Source string `json:"source,omitempty"` // 记录所在的数据库，只在合并多个数据库时记录
+	Reason string `json:"reason"`           // 跳过的原因，见Skip*常量
+	Error  string `json:"error,omitempty"`  // 具体的错误信息
+}
+
+// 记录被跳过时调用的函数，为nil时不记录
+type SkipHandler func(Skipped)
+
+func (h SkipHandler) skip(key string, reason string, err error) {
+	if h == nil {
+		return
+	}
+	s := Skipped{Key: key, Reason: reason}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	h(s)
+}
+
+func (h SkipHandler) report(s Skipped) {
+	if h != nil {
+		h(s)
+	}
+}
+
+// 返回为每条记录加上所在数据库的处理函数
+func (h SkipHandler) withSource(source string) SkipHandler {
+	if h == nil {
+		return nil
+	}
+	return func(s Skipped) {
+		s.Source = source
+		h(s)
+	}
+}
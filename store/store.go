@@ -0,0 +1,388 @@
+// Package store 读取Cursor的state.vscdb数据库，解析其中保存的Composer会话记录。
+//
+//	st, err := store.Open(path, store.Options{ReadOnly: true})
+//	if err != nil {
+//		return err
+//	}
+//	defer st.Close()
+//	for session, err := range st.Sessions(ctx, store.Filter{}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(session.Hash, session.Title)
+//	}
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	// 数据库文件不存在
+	ErrDBNotFound = errors.New("database file does not exist")
+	// 没有找到指定的会话
+	ErrNotFound = errors.New("session not found")
+	// 会话记录为空或不是有效的会话
+	ErrInvalidSession = errors.New("invalid session")
+)
+
+// 会话数据在cursorDiskKV中的键前缀
+const composerKeyPrefix = "composerData:"
+
+// 打开数据库的选项
+type Options struct {
+	ReadOnly bool // 以只读模式打开，Cursor运行时读取数据库应使用只读模式
+}
+
+// 会话存储，对应一个state.vscdb数据库
+type Store struct {
+	db   *sql.DB
+	path string
+}
+
+// 打开数据库，文件不存在时返回包装了ErrDBNotFound的错误
+func Open(path string, opts Options) (*Store, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrDBNotFound, path)
+	}
+	query := ""
+	if opts.ReadOnly {
+		query = "mode=ro"
+	}
+	dsn, err := fileDSN(path, query)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	return &Store{db: db, path: path}, nil
+}
+
+// 生成SQLite的URI文件名，路径中的?、#和%等字符经过转义，不会被当作参数
+func fileDSN(path string, query string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	// Windows的C:/...路径需要以/开头
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	u := url.URL{Scheme: "file", Path: abs, RawQuery: query}
+	return u.String(), nil
+}
+
+// 数据库文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// 会话的时间过滤条件，为零值的字段不过滤
+type Filter struct {
+	StartAfter  time.Time // 开始时间下限
+	StartBefore time.Time // 开始时间上限
+	EndAfter    time.Time // 结束时间下限
+	EndBefore   time.Time // 结束时间上限
+}
+
+// 检查会话是否符合过滤条件，没有结束时间的会话不按结束时间过滤
+func (f Filter) Match(record ChatRecord) bool {
+	startTime := time.Unix(record.CreatedAt/1000, 0)
+	if len(record.Conversation) > 0 {
+		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
+	}
+
+	if !f.StartAfter.IsZero() && startTime.Before(f.StartAfter) {
+		return false
+	}
+	if !f.StartBefore.IsZero() && startTime.After(f.StartBefore) {
+		return false
+	}
+	if record.EndedAt > 0 {
+		endTime := time.Unix(record.EndedAt/1000, 0)
+		if !f.EndAfter.IsZero() && endTime.Before(f.EndAfter) {
+			return false
+		}
+		if !f.EndBefore.IsZero() && endTime.After(f.EndBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// 检查记录是否包含有效内容
+func HasValidContent(record ChatRecord) bool {
+	if strings.HasPrefix(record.Name, composerKeyPrefix) {
+		return false
+	}
+	return len(record.Conversation) > 0
+}
+
+// 会话中引用的所有文件路径，包括引用文件、引用的代码片段和代码块，可能包含空字符串和重复项
+func RecordPaths(record ChatRecord) []string {
+	var paths []string
+	for _, file := range record.Context.FileSelections {
+		paths = append(paths, file.Uri.Path)
+	}
+	for _, msg := range record.Conversation {
+		for _, file := range msg.Context.FileSelections {
+			paths = append(paths, file.Uri.Path)
+		}
+		for _, sel := range msg.Context.Selections {
+			paths = append(paths, sel.Uri.Path)
+		}
+		for _, block := range msg.CodeBlocks {
+			paths = append(paths, block.Uri.Path)
+		}
+	}
+	return paths
+}
+
+// 计算会话中引用文件的公共目录
+func Workspace(record ChatRecord) string {
+	common := ""
+	for _, p := range RecordPaths(record) {
+		if p == "" {
+			continue
+		}
+		dir := filepath.Dir(filepath.ToSlash(p))
+		if common == "" {
+			common = dir
+			continue
+		}
+		for common != "/" && common != "." && dir != common && !strings.HasPrefix(dir, common+"/") {
+			common = filepath.Dir(common)
+		}
+	}
+	return common
+}
+
+// 由转换后的记录生成会话，没有消息的会话返回ErrInvalidSession
+func newSession(hash string, record ChatRecord, size int, format string) (Session, error) {
+	if !HasValidContent(record) {
+		return Session{}, ErrInvalidSession
+	}
+	if len(record.Conversation) > 0 {
+		record.EndedAt = record.Conversation[len(record.Conversation)-1].TimingInfo.ClientEndTime
+	}
+	return Session{
+		Hash:         hash,
+		Title:        record.Name,
+		StartTime:    time.Unix(record.CreatedAt/1000, 0),
+		EndTime:      time.Unix(record.EndedAt/1000, 0),
+		MessageCount: len(record.Conversation),
+		Size:         size,
+		Workspace:    Workspace(record),
+		Format:       format,
+		Record:       &record,
+	}, nil
+}
+
+// 使用适配器转换composerData
+func (s *Store) convertComposer(ctx context.Context, a Adapter, hash string, value []byte) (Session, error) {
+	record, err := a.Convert(ctx, s, hash, value)
+	if err != nil {
+		return Session{}, err
+	}
+	if record.Raw == nil {
+		record.Raw = append(json.RawMessage(nil), value...)
+	}
+	return newSession(hash, record, len(value), a.Name)
+}
+
+// 检测composerData的格式并转换为会话，无效的会话返回ErrInvalidSession
+func (s *Store) parseComposer(ctx context.Context, hash string, value []byte) (Session, error) {
+	header, err := detectHeader(value)
+	if err != nil {
+		return Session{}, &skipError{SkipInvalidJSON, err}
+	}
+	a, ok := matchAdapter(header)
+	if !ok {
+		return Session{}, &skipError{SkipUnknownFormat, fmt.Errorf("unrecognized session format")}
+	}
+	session, err := s.convertComposer(ctx, a, hash, value)
+	if err != nil && err != ErrInvalidSession {
+		return Session{}, &skipError{SkipConvertError, err}
+	}
+	return session, err
+}
+
+// 带有跳过原因的错误
+type skipError struct {
+	reason string
+	err    error
+}
+
+func (e *skipError) Error() string { return e.err.Error() }
+func (e *skipError) Unwrap() error { return e.err }
+
+// 无法解析的会话同样视为无效的会话
+func (e *skipError) Is(target error) bool { return target == ErrInvalidSession }
+
+// 错误对应的跳过原因
+func skipReason(err error) string {
+	var se *skipError
+	if errors.As(err, &se) {
+		return se.reason
+	}
+	if errors.Is(err, ErrInvalidSession) {
+		return SkipNoMessages
+	}
+	return SkipConvertError
+}
+
+// 读取所有适配器从composerData以外的位置加载的会话，无法解析的数据会被跳过
+func (s *Store) loadOtherSessions(ctx context.Context, onSkip SkipHandler) ([]Session, error) {
+	var sessions []Session
+	for _, a := range adapters {
+		if a.Load == nil {
+			continue
+		}
+		loaded, err := a.Load(ctx, s)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			onSkip.skip(a.Name, SkipLoadError, err)
+			continue
+		}
+		sessions = append(sessions, loaded...)
+	}
+	return sessions, nil
+}
+
+// 按数据库中的顺序遍历符合条件的会话，之后是旧格式的会话；无法解析或内容无效的会话会被跳过
+// 查询失败或ctx被取消时产生一个错误并结束遍历
+func (s *Store) Sessions(ctx context.Context, filter Filter) iter.Seq2[Session, error] {
+	return s.Scan(ctx, filter, nil)
+}
+
+// 与Sessions相同，每条被跳过的记录都会交给onSkip，用于诊断数据问题
+// 不符合过滤条件的会话不算跳过
+func (s *Store) Scan(ctx context.Context, filter Filter, onSkip SkipHandler) iter.Seq2[Session, error] {
+	return func(yield func(Session, error) bool) {
+		changes, err := s.appliedChanges(ctx, onSkip)
+		if err != nil {
+			yield(Session{}, err)
+			return
+		}
+
+		rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM cursorDiskKV WHERE key LIKE 'composerData:%'")
+		if err != nil {
+			yield(Session{}, fmt.Errorf("query database: %v", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				yield(Session{}, err)
+				return
+			}
+			var key string
+			var value sql.NullString
+			if err := rows.Scan(&key, &value); err != nil {
+				onSkip.skip(key, SkipScanError, err)
+				continue
+			}
+			if !value.Valid {
+				onSkip.skip(key, SkipNullValue, nil)
+				continue
+			}
+			if value.String == "[]" {
+				onSkip.skip(key, SkipEmptyValue, nil)
+				continue
+			}
+			session, err := s.parseComposer(ctx, strings.TrimPrefix(key, composerKeyPrefix), []byte(value.String))
+			if err != nil {
+				onSkip.skip(key, skipReason(err), err)
+				continue
+			}
+			if !filter.Match(*session.Record) {
+				continue
+			}
+			session.Record.AppliedChanges = changes[session.Hash]
+			session.Source = s.path
+			if !yield(session, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(Session{}, fmt.Errorf("read database: %v", err))
+			return
+		}
+
+		others, err := s.loadOtherSessions(ctx, onSkip)
+		if err != nil {
+			yield(Session{}, err)
+			return
+		}
+		for _, session := range others {
+			if !filter.Match(*session.Record) {
+				continue
+			}
+			session.Source = s.path
+			if !yield(session, nil) {
+				return
+			}
+		}
+	}
+}
+
+// 读取指定ID的会话，不存在时返回包装了ErrNotFound的错误
+func (s *Store) Session(ctx context.Context, hash string) (Session, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM cursorDiskKV WHERE key = ?", composerKeyPrefix+hash).Scan(&value)
+	if err == sql.ErrNoRows {
+		others, err := s.loadOtherSessions(ctx, nil)
+		if err != nil {
+			return Session{}, err
+		}
+		for _, session := range others {
+			if session.Hash == hash {
+				session.Source = s.path
+				return session, nil
+			}
+		}
+		return Session{}, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("query database: %v", err)
+	}
+	session, err := s.parseComposer(ctx, hash, []byte(value))
+	if err != nil {
+		return Session{}, err
+	}
+
+	changes, err := s.AppliedChanges(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	session.Record.AppliedChanges = changes[hash]
+	session.Source = s.path
+	return session, nil
+}
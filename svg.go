@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 默认的热力图颜色，从无活动到最活跃
+var defaultHeatmapColors = []string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"}
+
+// SVG图表的布局参数
+const (
+	svgCellSize   = 11  // 热力图方格大小
+	svgCellStep   = 13  // 方格间距
+	svgMarginLeft = 36  // 左侧星期标签宽度
+	svgMarginTop  = 40  // 顶部标题和月份标签高度
+	svgBarHeight  = 16  // 条形图每行高度
+	svgBarLabel   = 260 // 条形图标签宽度
+	svgBarWidth   = 400 // 条形图最长条的宽度
+	svgBarTop     = 8   // 条形图行的上间距
+	svgFontFamily = "-apple-system, Segoe UI, Helvetica, Arial, sans-serif"
+)
+
+// 热力图接受的颜色: #rgb、#rrggbb或颜色名称，颜色直接写入SVG属性，不能包含其他字符
+var heatmapColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
+
+// 解析逗号分隔的颜色列表，至少需要两个颜色
+func parseHeatmapColors(colorsStr string) ([]string, error) {
+	if strings.TrimSpace(colorsStr) == "" {
+		return defaultHeatmapColors, nil
+	}
+	var colors []string
+	for _, c := range strings.Split(colorsStr, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			if !heatmapColorPattern.MatchString(c) {
+				return nil, errors.New(tr("svg.color", c))
+			}
+			colors = append(colors, c)
+		}
+	}
+	if len(colors) < 2 {
+		return nil, errors.New(tr("svg.colors", colorsStr))
+	}
+	return colors, nil
+}
+
+// 根据数量选择颜色等级，0使用第一个颜色，其余按最大值等分
+func heatmapColor(count int, max int, colors []string) string {
+	if count <= 0 || max <= 0 {
+		return colors[0]
+	}
+	levels := len(colors) - 1
+	level := int(math.Ceil(float64(count) / float64(max) * float64(levels)))
+	if level < 1 {
+		level = 1
+	}
+	if level > levels {
+		level = levels
+	}
+	return colors[level]
+}
+
+// 截断过长的标签，保留末尾部分（文件路径的末尾更有辨识度）
+func truncateLabel(label string, maxRunes int) string {
+	runes := []rune(label)
+	if len(runes) <= maxRunes {
+		return label
+	}
+	return "…" + string(runes[len(runes)-maxRunes+1:])
+}
+
+// 两个日期之间相差的天数，按日历日期计算，不受夏令时切换时一天不是24小时的影响
+func calendarDays(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// 渲染日历热力图，返回SVG片段和占用的高度
+func renderHeatmapSVG(sb *strings.Builder, daily map[string]int, from, to time.Time, colors []string, top int) (int, int) {
+	// 从起始日期所在周的周日开始，与GitHub的贡献图一致
+	start := from.AddDate(0, 0, -int(from.Weekday()))
+	weeks := calendarDays(start, to)/7 + 1
+
+	max := 0
+	total := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		n := daily[day.Format("2006-01-02")]
+		total += n
+		if n > max {
+			max = n
+		}
+	}
+
+	fmt.Fprintf(sb, `<text x="0" y="%d" font-size="14" font-weight="600">%s</text>`+"\n", top+14,
+		html.EscapeString(tr("svg.heatmap", from.Format("2006-01-02"), to.Format("2006-01-02"), total)))
+
+	// 月份标签
+	lastMonth := -1
+	for w := 0; w < weeks; w++ {
+		day := start.AddDate(0, 0, w*7)
+		if int(day.Month()) != lastMonth {
+			lastMonth = int(day.Month())
+			fmt.Fprintf(sb, `<text x="%d" y="%d" font-size="10" fill="#57606a">%s</text>`+"\n",
+				svgMarginLeft+w*svgCellStep, top+svgMarginTop-6, day.Format("Jan"))
+		}
+	}
+	// 星期标签
+	for _, wd := range []time.Weekday{time.Monday, time.Wednesday, time.Friday} {
+		fmt.Fprintf(sb, `<text x="0" y="%d" font-size="10" fill="#57606a">%s</text>`+"\n",
+			top+svgMarginTop+int(wd)*svgCellStep+9, wd.String()[:3])
+	}
+
+	for w := 0; w < weeks; w++ {
+		for d := 0; d < 7; d++ {
+			day := start.AddDate(0, 0, w*7+d)
+			if day.Before(from) || day.After(to) {
+				continue
+			}
+			key := day.Format("2006-01-02")
+			n := daily[key]
+			fmt.Fprintf(sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s: %d</title></rect>`+"\n",
+				svgMarginLeft+w*svgCellStep, top+svgMarginTop+d*svgCellStep, svgCellSize, svgCellSize,
+				heatmapColor(n, max, colors), key, n)
+		}
+	}
+
+	// 图例
+	legendY := top + svgMarginTop + 7*svgCellStep + 8
+	legendX := svgMarginLeft + weeks*svgCellStep - len(colors)*svgCellStep - 60
+	if legendX < svgMarginLeft {
+		legendX = svgMarginLeft
+	}
+	fmt.Fprintf(sb, `<text x="%d" y="%d" font-size="10" fill="#57606a">Less</text>`+"\n", legendX, legendY+9)
+	for i, c := range colors {
+		fmt.Fprintf(sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"/>`+"\n",
+			legendX+28+i*svgCellStep, legendY, svgCellSize, svgCellSize, c)
+	}
+	fmt.Fprintf(sb, `<text x="%d" y="%d" font-size="10" fill="#57606a">More</text>`+"\n",
+		legendX+28+len(colors)*svgCellStep+4, legendY+9)
+
+	width := svgMarginLeft + weeks*svgCellStep
+	height := svgMarginTop + 7*svgCellStep + 8 + svgCellSize + 12
+	return width, height
+}
+
+// 渲染水平条形图，返回占用的高度
+func renderBarChartSVG(sb *strings.Builder, title string, counts []NamedCount, color string, top int) int {
+	if len(counts) == 0 {
+		return 0
+	}
+	fmt.Fprintf(sb, `<text x="0" y="%d" font-size="14" font-weight="600">%s</text>`+"\n", top+14, html.EscapeString(title))
+
+	max := counts[0].Count
+	for _, c := range counts {
+		if c.Count > max {
+			max = c.Count
+		}
+	}
+	y := top + 24
+	for _, c := range counts {
+		barWidth := 1
+		if max > 0 {
+			barWidth = int(math.Max(1, float64(c.Count)/float64(max)*svgBarWidth))
+		}
+		fmt.Fprintf(sb, `<text x="0" y="%d" font-size="11" fill="#24292f"><title>%s</title>%s</text>`+"\n",
+			y+12, html.EscapeString(c.Name), html.EscapeString(truncateLabel(c.Name, 40)))
+		fmt.Fprintf(sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"/>`+"\n",
+			svgBarLabel, y+2, barWidth, svgBarHeight-4, color)
+		fmt.Fprintf(sb, `<text x="%d" y="%d" font-size="11" fill="#57606a">%d</text>`+"\n",
+			svgBarLabel+barWidth+6, y+12, c.Count)
+		y += svgBarHeight + 2
+	}
+	return y - top + svgBarTop
+}
+
+// 生成包含活动热力图、语言和文件使用条形图的独立SVG
+func renderStatsSVG(stats *UsageStats, from, to time.Time, colors []string) string {
+	daily := make(map[string]int, len(stats.Daily))
+	for _, d := range stats.Daily {
+		daily[d.Period] = d.Messages
+	}
+
+	var body strings.Builder
+	heatmapWidth, height := renderHeatmapSVG(&body, daily, from, to, colors, 0)
+	height += 16
+
+	barColor := colors[len(colors)-1]
+	height += renderBarChartSVG(&body, tr("stats.languages"), stats.Languages, barColor, height)
+	height += renderBarChartSVG(&body, tr("stats.topFiles"), stats.TopFiles, barColor, height)
+
+	width := heatmapWidth
+	if w := svgBarLabel + svgBarWidth + 60; w > width {
+		width = w
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s">`+"\n",
+		width, height, width, height, svgFontFamily)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	sb.WriteString(body.String())
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// 确定热力图的日期范围：结束日期默认为最后一个会话的日期（没有会话时为今天），开始日期默认为结束日期之前的一年
+func heatmapRange(fromStr, toStr string, last time.Time) (time.Time, time.Time, error) {
+	to, err := parseTimeArg(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(tr("err.parseFlag", "svg-to", err))
+	}
+	if to.IsZero() {
+		if last.IsZero() {
+			last = time.Now()
+		}
+		last = last.Local()
+		to = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.Local)
+	}
+	from, err := parseTimeArg(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(tr("err.parseFlag", "svg-from", err))
+	}
+	if from.IsZero() {
+		from = to.AddDate(-1, 0, 1)
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New(tr("svg.range"))
+	}
+	return from, to, nil
+}
+
+// 将统计结果写入SVG文件
+func writeStatsSVG(path string, stats *UsageStats, fromStr, toStr, colorsStr string) error {
+	from, to, err := heatmapRange(fromStr, toStr, stats.LastSession)
+	if err != nil {
+		return withCode(codeInvalidArg, err)
+	}
+	colors, err := parseHeatmapColors(colorsStr)
+	if err != nil {
+		return withCode(codeInvalidArg, err)
+	}
+	if err := os.WriteFile(path, []byte(renderStatsSVG(stats, from, to, colors)), 0644); err != nil {
+		return withCode(codeWriteError, errors.New(tr("svg.write", err)))
+	}
+	return nil
+}
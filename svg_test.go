@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseHeatmapColors(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", defaultHeatmapColors, false},
+		{"#fff, #000000", []string{"#fff", "#000000"}, false},
+		{"white,DarkGreen,#1A2b3C", []string{"white", "DarkGreen", "#1A2b3C"}, false},
+		{"#fff", nil, true},
+		{`#fff,red" onload="alert(1)`, nil, true},
+		{"#ffff,#000", nil, true},
+		{"rgb(0,0,0),#000", nil, true},
+		{"#fff,url(#x)", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHeatmapColors(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHeatmapColors(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseHeatmapColors(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHeatmapRange(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+	last := time.Date(2024, 6, 15, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		from, to string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		// 默认为最后一个会话的日期之前的一年
+		{"", "", day(2023, 6, 16), day(2024, 6, 15), false},
+		{"2024-01-01", "2024-01-31", day(2024, 1, 1), day(2024, 1, 31), false},
+		{"2024-06-01", "", day(2024, 6, 1), day(2024, 6, 15), false},
+		{"", "2024-03-01", day(2023, 3, 2), day(2024, 3, 1), false},
+		{"2024-01-01", "2024-01-01", day(2024, 1, 1), day(2024, 1, 1), false},
+		{"2024-02-01", "2024-01-01", time.Time{}, time.Time{}, true},
+		{"yesterday", "", time.Time{}, time.Time{}, true},
+		{"", "2024/01/01", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		from, to, err := heatmapRange(tt.from, tt.to, last)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("heatmapRange(%q, %q) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+		if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+			t.Errorf("heatmapRange(%q, %q) = %v ~ %v, want %v ~ %v", tt.from, tt.to, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+// 返回SVG中每个热力图方格的日期，同时检查SVG是格式正确的XML
+func heatmapDays(t *testing.T, svg string) []string {
+	t.Helper()
+	var days []string
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	inTitle := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, svg)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			inTitle = tok.Name.Local == "title"
+		case xml.CharData:
+			if inTitle {
+				if date, _, ok := strings.Cut(string(tok), ": "); ok {
+					days = append(days, date)
+				}
+			}
+		case xml.EndElement:
+			inTitle = false
+		}
+	}
+	return days
+}
+
+func TestRenderStatsSVG(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.Local) }
+	stats := &UsageStats{
+		Daily:     []PeriodCount{{Period: "2024-03-04", Messages: 4}, {Period: "2024-03-05", Messages: 1}},
+		Languages: []NamedCount{{"go", 3}},
+		TopFiles:  []NamedCount{{`/ws/<a href="x">.go`, 2}},
+	}
+	colors := []string{"#eee", "#111"}
+	svg := renderStatsSVG(stats, day(3, 3), day(3, 9), colors)
+
+	days := heatmapDays(t, svg)
+	if len(days) != 7 || days[0] != "2024-03-03" || days[6] != "2024-03-09" {
+		t.Errorf("heatmap days = %v, want 2024-03-03 to 2024-03-09", days)
+	}
+	for _, want := range []string{
+		`fill="#111"><title>2024-03-04: 4</title>`,
+		`fill="#111"><title>2024-03-05: 1</title>`,
+		`fill="#eee"><title>2024-03-06: 0</title>`,
+		`&lt;a href=&#34;x&#34;&gt;`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %s", want)
+		}
+	}
+}
+
+// 夏令时开始的那一周少一个小时，最后一列不能因此丢失
+func TestRenderHeatmapAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-03-10切换到夏令时，03-03和03-17都是周日
+	from := time.Date(2024, 3, 3, 0, 0, 0, 0, ny)
+	to := time.Date(2024, 3, 17, 0, 0, 0, 0, ny)
+	var sb strings.Builder
+	width, _ := renderHeatmapSVG(&sb, nil, from, to, defaultHeatmapColors, 0)
+	days := heatmapDays(t, "<svg>"+sb.String()+"</svg>")
+	if len(days) != 15 || days[len(days)-1] != "2024-03-17" {
+		t.Errorf("heatmap days = %v, want 15 days ending with 2024-03-17", days)
+	}
+	if want := svgMarginLeft + 3*svgCellStep; width != want {
+		t.Errorf("width = %d, want %d (3 weeks)", width, want)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 分词器，估算文本的token数量
+type tokenizer interface {
+	countTokens(text string) int
+}
+
+// 支持的分词器，可以通过-tokenizer参数选择
+var tokenizers = map[string]tokenizer{
+	"approx": approxBPETokenizer{},
+	"chars":  charTokenizer{},
+}
+
+// 返回所有支持的分词器名称
+func tokenizerNames() []string {
+	names := make([]string, 0, len(tokenizers))
+	for name := range tokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 按每4个字符一个token粗略估算
+type charTokenizer struct{}
+
+func (charTokenizer) countTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// 近似BPE分词器：按GPT系列分词器的预分词规则切分文本，再按片段类型估算每段的token数
+// 不需要词表，离线可用，英文和代码的误差通常在10%~20%之间
+type approxBPETokenizer struct{}
+
+// 与cl100k的预分词规则相近：英文缩写、单词（可带一个前导符号）、最多3位的数字、标点和空白
+var bpePretokenPattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+func (approxBPETokenizer) countTokens(text string) int {
+	total := 0
+	for _, piece := range bpePretokenPattern.FindAllString(text, -1) {
+		total += approxPieceTokens(piece)
+	}
+	return total
+}
+
+// 估算单个预分词片段的token数
+func approxPieceTokens(piece string) int {
+	var ascii, han, other, punct int
+	for _, r := range piece {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			han++
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			ascii++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			other++
+		default:
+			punct++
+		}
+	}
+
+	tokens := 0
+	// 常见英文单词通常是一个token，较长的单词大约每5个字母一个token
+	if ascii > 0 {
+		if ascii <= 8 {
+			tokens++
+		} else {
+			tokens += (ascii + 4) / 5
+		}
+	}
+	// 中日韩文字大约每个字一个token
+	tokens += han
+	// 其他非ASCII文字大约每2个字符一个token
+	tokens += (other + 1) / 2
+	// 连续的标点通常会合并，前导符号已经计入单词
+	if punct > 0 && ascii == 0 && han == 0 && other == 0 {
+		tokens += (punct + 1) / 2
+	}
+	// 纯空白片段（缩进、换行）算一个token
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
+
+// 模型价格，单位为美元/百万token
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// 价格表文件的格式，文件中的价格会覆盖内置价格
+//
+//	{
+//	  "default": "gpt-4o",
+//	  "models": {
+//	    "gpt-4o": {"input": 2.5, "output": 10},
+//	    "my-local-model": {"input": 0, "output": 0}
+//	  }
+//	}
+type PriceTable struct {
+	Default string                `json:"default"` // 会话没有记录模型时使用的模型
+	Models  map[string]ModelPrice `json:"models"`
+}
+
+// 内置价格表，价格可能过时，需要准确结果时请使用-prices指定
+func builtinPriceTable() PriceTable {
+	return PriceTable{
+		Default: "claude-3.5-sonnet",
+		Models: map[string]ModelPrice{
+			"gpt-4o":            {Input: 2.5, Output: 10},
+			"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+			"gpt-4.1":           {Input: 2, Output: 8},
+			"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+			"o1":                {Input: 15, Output: 60},
+			"o3-mini":           {Input: 1.1, Output: 4.4},
+			"claude-3.5-sonnet": {Input: 3, Output: 15},
+			"claude-3.7-sonnet": {Input: 3, Output: 15},
+			"claude-3.5-haiku":  {Input: 0.8, Output: 4},
+			"claude-3-opus":     {Input: 15, Output: 75},
+			"claude-sonnet-4":   {Input: 3, Output: 15},
+			"claude-opus-4":     {Input: 15, Output: 75},
+			"gemini-2.5-pro":    {Input: 1.25, Output: 10},
+			"gemini-2.5-flash":  {Input: 0.3, Output: 2.5},
+			"cursor-small":      {Input: 0, Output: 0},
+		},
+	}
+}
+
+// 统一模型名称的写法，例如 claude-3.5-sonnet 和 claude-3-5-sonnet-20241022
+func normalizeModelName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), ".", "-")
+}
+
+// 查找模型的价格：先完全匹配，再匹配最长的前缀
+func (p PriceTable) lookup(model string) (ModelPrice, bool) {
+	model = normalizeModelName(model)
+	best, bestLen, found := ModelPrice{}, 0, false
+	for name, price := range p.Models {
+		name = normalizeModelName(name)
+		if name == model {
+			return price, true
+		}
+		if strings.HasPrefix(model, name) && len(name) > bestLen {
+			best, bestLen, found = price, len(name), true
+		}
+	}
+	return best, found
+}
+
+// token数量和估算费用
+type TokenUsage struct {
+	Input  int     `json:"input"`           // 用户消息和引用的代码片段
+	Output int     `json:"output"`          // AI回复和代码块
+	Total  int     `json:"total"`           // 输入和输出之和
+	Cost   float64 `json:"cost"`            // 估算费用（美元），模型没有价格时为0
+	Model  string  `json:"model,omitempty"` // 计算费用使用的模型，仅会话级别输出
+}
+
+func (u *TokenUsage) add(o TokenUsage) {
+	u.Input += o.Input
+	u.Output += o.Output
+	u.Total += o.Total
+	u.Cost += o.Cost
+}
+
+// 格式化估算费用
+func formatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// token估算器，组合分词器和价格表
+type tokenEstimator struct {
+	tokenizer tokenizer
+	prices    PriceTable
+	priced    bool // 指定了-model或-prices，没有记录模型的会话也按默认模型估算费用
+}
+
+// 默认的估算器；命令行参数会替换它，Markdown中的估算也使用这里的设置
+var defaultTokenEstimator = &tokenEstimator{tokenizer: approxBPETokenizer{}, prices: builtinPriceTable()}
+
+// 根据分词器名称、价格表文件和默认模型创建估算器
+func newTokenEstimator(tokenizerName string, pricesPath string, model string) (*tokenEstimator, error) {
+	tok, ok := tokenizers[tokenizerName]
+	if !ok {
+		return nil, errors.New(tr("tokens.tokenizer", tokenizerName, strings.Join(tokenizerNames(), ", ")))
+	}
+	prices := builtinPriceTable()
+	if pricesPath != "" {
+		data, err := os.ReadFile(pricesPath)
+		if err != nil {
+			return nil, errors.New(tr("tokens.readPrices", err))
+		}
+		var custom PriceTable
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, errors.New(tr("tokens.parsePrices", err))
+		}
+		if custom.Default != "" {
+			prices.Default = custom.Default
+		}
+		for name, price := range custom.Models {
+			prices.Models[name] = price
+		}
+	}
+	if model != "" {
+		prices.Default = model
+	}
+	return &tokenEstimator{tokenizer: tok, prices: prices, priced: pricesPath != "" || model != ""}, nil
+}
+
+// 会话使用的模型，没有记录时使用价格表的默认模型
+func (e *tokenEstimator) sessionModel(record ChatRecord) string {
+	if model := record.ModelConfig.ModelName; model != "" && model != "default" {
+		return model
+	}
+	return e.prices.Default
+}
+
+// 会话记录了模型或用户指定了价格时才输出模型和费用，避免在Markdown中给出按猜测的模型计算的费用
+func (e *tokenEstimator) hasCost(record ChatRecord) bool {
+	model := record.ModelConfig.ModelName
+	return e.priced || model != "" && model != "default"
+}
+
+// 估算单条消息的token数和费用：用户消息计为输入，AI回复计为输出
+func (e *tokenEstimator) messageUsage(msg Message, model string) TokenUsage {
+	var usage TokenUsage
+	price, _ := e.prices.lookup(model)
+	switch msg.Type {
+	case 1:
+		usage.Input = e.tokenizer.countTokens(msg.Text)
+		for _, sel := range msg.Context.Selections {
+			usage.Input += e.tokenizer.countTokens(sel.Text)
+		}
+	case 2:
+		usage.Output = e.tokenizer.countTokens(msg.Text)
+		for _, block := range msg.CodeBlocks {
+			usage.Output += e.tokenizer.countTokens(block.Content)
+		}
+	}
+	usage.Total = usage.Input + usage.Output
+	usage.Cost = (float64(usage.Input)*price.Input + float64(usage.Output)*price.Output) / 1e6
+	return usage
+}
+
+// 估算整个会话的token数和费用
+func (e *tokenEstimator) recordUsage(record ChatRecord) TokenUsage {
+	model := e.sessionModel(record)
+	usage := TokenUsage{Model: model}
+	for _, msg := range record.Conversation {
+		usage.add(e.messageUsage(msg, model))
+	}
+	// 避免浮点累加误差在JSON中输出很长的小数
+	usage.Cost = math.Round(usage.Cost*1e6) / 1e6
+	return usage
+}
+
+// 为会话列表填充token估算
+func (e *tokenEstimator) estimateSessions(sessions []SessionInfo) {
+	for i := range sessions {
+		usage := e.recordUsage(*sessions[i].Record)
+		sessions[i].Tokens = &usage
+	}
+}
+
+// 注册token估算相关的命令行参数，返回的函数在解析参数后调用，用于替换默认的估算器
+func registerTokenFlags(fs *flag.FlagSet) func() error {
+	tokenizerName := fs.String("tokenizer", "approx", tr("flag.tokenizer", strings.Join(tokenizerNames(), ", ")))
+	pricesPath := fs.String("prices", "", tr("flag.prices"))
+	model := fs.String("model", "", tr("flag.model", builtinPriceTable().Default))
+	return func() error {
+		e, err := newTokenEstimator(*tokenizerName, *pricesPath, *model)
+		if err != nil {
+			return err
+		}
+		defaultTokenEstimator = e
+		return nil
+	}
+}
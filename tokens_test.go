@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownFrontMatter(t *testing.T) {
+	sessions := fixtureSessions(t)
+	record := *sessions[len(sessions)-1].Record
+	if md := convertToMarkdown(record); strings.HasPrefix(md, "---") || strings.Contains(md, "$") {
+		t.Errorf("markdown without -front-matter contains an estimate:\n%s", md)
+	}
+
+	markdownFrontMatter = true
+	defer func() { markdownFrontMatter = false }()
+	md := convertToMarkdown(record)
+	head, body, ok := strings.Cut(strings.TrimPrefix(md, "---\n"), "---\n\n")
+	if !strings.HasPrefix(md, "---\n") || !ok || !strings.HasPrefix(body, "# Fix login bug\n") {
+		t.Fatalf("missing front matter:\n%s", md)
+	}
+	for _, want := range []string{"title: \"Fix login bug\"\n", "messages: 2\n", "tokens:\n  input: "} {
+		if !strings.Contains(head, want) {
+			t.Errorf("front matter is missing %q:\n%s", want, head)
+		}
+	}
+	// 会话没有记录模型，也没有指定价格时不输出费用
+	if strings.Contains(head, "cost:") || strings.Contains(head, "model:") {
+		t.Errorf("front matter guesses a model:\n%s", head)
+	}
+
+	estimator := defaultTokenEstimator
+	defer func() { defaultTokenEstimator = estimator }()
+	defaultTokenEstimator, _ = newTokenEstimator("approx", "", "gpt-4o")
+	if head := convertToMarkdown(record); !strings.Contains(head, "model: \"gpt-4o\"\ncost: ") {
+		t.Errorf("front matter is missing the requested cost:\n%s", head)
+	}
+}